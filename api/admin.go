@@ -0,0 +1,291 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/NlightN22/OTPSMSProvider/apikey"
+	"github.com/NlightN22/OTPSMSProvider/policy"
+)
+
+// BlocklistRequest represents request for the /admin/blocklist endpoints.
+type BlocklistRequest struct {
+	Entry string `json:"entry" binding:"required" example:"+7999*"` // exact E.164 number or prefix ending with "*"
+}
+
+// MintKeyRequest represents request for POST /admin/keys.
+type MintKeyRequest struct {
+	Name          string   `json:"name" binding:"required,max=64" example:"shop-backend"`
+	Scopes        []string `json:"scopes" binding:"required,min=1,dive,oneof=send verify admin client_ip" example:"send,verify"`
+	PerMinute     float64  `json:"per_minute" binding:"gte=0" example:"60"` // 0 disables the per-key limit
+	Burst         int      `json:"burst" binding:"gte=0" example:"10"`
+	PhonePrefixes []string `json:"phone_prefixes" binding:"dive,e164_prefix" example:"+7"` // empty allows every number
+}
+
+// MintKeyResponse carries the new token. It is shown only once.
+type MintKeyResponse struct {
+	Token string     `json:"token" example:"otp_3f9a1c0b7d2e4a56_9c1e..."`
+	Key   apikey.Key `json:"key"`
+}
+
+// RevokeSecretsResponse reports how many secrets were deleted.
+type RevokeSecretsResponse struct {
+	Revoked int `json:"revoked" example:"1"`
+}
+
+// registerAdminRoutes attaches operator endpoints of the enabled components.
+func (a *API) registerAdminRoutes(g *gin.RouterGroup) {
+	if a.budget != nil {
+		g.GET("/budget", a.budgetStatus)
+	}
+	if a.policy != nil {
+		g.GET("/blocklist", a.listBlocklist)
+		g.POST("/blocklist", a.addBlocklist)
+		g.DELETE("/blocklist", a.removeBlocklist)
+	}
+	if a.keys != nil {
+		g.GET("/keys", a.listKeys)
+		g.POST("/keys", a.mintKey)
+		g.DELETE("/keys/:id", a.revokeKey)
+	}
+	if a.webhooks != nil {
+		g.GET("/webhooks/deliveries", a.webhookDeliveries)
+	}
+	if a.phoneAdm != nil {
+		g.GET("/phones/:phone", a.phoneState)
+		g.DELETE("/phones/:phone/limits", a.resetPhoneLimits)
+		g.DELETE("/phones/:phone/secrets", a.revokePhoneSecrets)
+	}
+	if a.health != nil {
+		g.GET("/health", a.healthReport)
+	}
+	if a.dashboard != nil {
+		g.GET("/dashboard/stats", a.dashboardStats)
+		g.GET("/dashboard/events", a.dashboardEvents)
+	}
+}
+
+// budgetStatus reports SMS spend against the caps.
+// @Summary SMS budget status
+// @Description Returns spend and remaining budget for the current day and month
+// @Produce json
+// @Success 200 {object} budget.Status
+// @Security ApiKeyAuth
+// @Router /admin/budget [get]
+func (a *API) budgetStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, a.budget.Status())
+}
+
+// listBlocklist returns all number restrictions in effect.
+// @Summary List blocklist and country allowlist
+// @Produce json
+// @Success 200 {object} policy.Entries
+// @Security ApiKeyAuth
+// @Router /admin/blocklist [get]
+func (a *API) listBlocklist(c *gin.Context) {
+	c.JSON(http.StatusOK, a.policy.Entries())
+}
+
+// addBlocklist blocks a number or prefix at runtime.
+// @Summary Add blocklist entry
+// @Accept json
+// @Param data body BlocklistRequest true "Entry"
+// @Success 204
+// @Failure 400 {object} ErrorResponse "invalid_request"
+// @Security ApiKeyAuth
+// @Router /admin/blocklist [post]
+func (a *API) addBlocklist(c *gin.Context) {
+	var req BlocklistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: CodeInvalidRequest, Message: "Invalid request"})
+		return
+	}
+	if err := a.policy.Block(req.Entry); err != nil {
+		if errors.Is(err, policy.ErrInvalidEntry) {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: CodeInvalidRequest, Message: "Invalid entry"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: CodeInternal, Message: "Blocklist update error"})
+		return
+	}
+	a.auditAdmin(c, "blocklist.add", req.Entry)
+	c.Status(http.StatusNoContent)
+}
+
+// removeBlocklist removes a runtime blocklist entry.
+// @Summary Remove blocklist entry
+// @Param entry query string true "Entry to remove"
+// @Success 204
+// @Failure 404 {object} ErrorResponse "not_found"
+// @Security ApiKeyAuth
+// @Router /admin/blocklist [delete]
+func (a *API) removeBlocklist(c *gin.Context) {
+	entry := c.Query("entry")
+	if entry == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: CodeInvalidRequest, Message: "Invalid request"})
+		return
+	}
+	if !a.policy.Unblock(entry) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: CodeNotFound, Message: "Entry not found"})
+		return
+	}
+	a.auditAdmin(c, "blocklist.remove", entry)
+	c.Status(http.StatusNoContent)
+}
+
+// listKeys returns config and minted API keys without their hashes.
+// @Summary List API keys
+// @Produce json
+// @Success 200 {array} apikey.Key
+// @Security ApiKeyAuth
+// @Router /admin/keys [get]
+func (a *API) listKeys(c *gin.Context) {
+	c.JSON(http.StatusOK, a.keys.List())
+}
+
+// mintKey creates an API key in storage.
+// @Summary Mint API key
+// @Description The token is returned only in this response; store it securely.
+// @Accept json
+// @Produce json
+// @Param data body MintKeyRequest true "Key settings"
+// @Success 201 {object} MintKeyResponse
+// @Failure 400 {object} ErrorResponse "invalid_request"
+// @Security ApiKeyAuth
+// @Router /admin/keys [post]
+func (a *API) mintKey(c *gin.Context) {
+	var req MintKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: CodeInvalidRequest, Message: "Invalid request"})
+		return
+	}
+	token, key, err := a.keys.Mint(apikey.Key{
+		Name:          req.Name,
+		Scopes:        req.Scopes,
+		PerMinute:     req.PerMinute,
+		Burst:         req.Burst,
+		PhonePrefixes: req.PhonePrefixes,
+	})
+	if err != nil {
+		if errors.Is(err, apikey.ErrInvalidScope) {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: CodeInvalidRequest, Message: "Invalid scope"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: CodeInternal, Message: "Key generation error"})
+		return
+	}
+	a.auditAdmin(c, "key.mint", key.ID)
+	c.JSON(http.StatusCreated, MintKeyResponse{Token: token, Key: key})
+}
+
+// revokeKey disables an API key.
+// @Summary Revoke API key
+// @Param id path string true "Key ID"
+// @Success 204
+// @Failure 404 {object} ErrorResponse "not_found"
+// @Security ApiKeyAuth
+// @Router /admin/keys/{id} [delete]
+func (a *API) revokeKey(c *gin.Context) {
+	if !a.keys.Revoke(c.Param("id")) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: CodeNotFound, Message: "Key not found"})
+		return
+	}
+	a.auditAdmin(c, "key.revoke", c.Param("id"))
+	c.Status(http.StatusNoContent)
+}
+
+// webhookDeliveries returns the log of recent webhook deliveries.
+// @Summary Recent webhook deliveries
+// @Description Finished deliveries, newest first, including failures after the last retry.
+// @Produce json
+// @Param limit query int false "Maximum entries" default(100)
+// @Success 200 {array} webhook.Delivery
+// @Failure 400 {object} ErrorResponse "invalid_request"
+// @Security ApiKeyAuth
+// @Router /admin/webhooks/deliveries [get]
+func (a *API) webhookDeliveries(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: CodeInvalidRequest, Message: "Invalid limit"})
+		return
+	}
+	c.JSON(http.StatusOK, a.webhooks.Deliveries(limit))
+}
+
+// adminPhone normalizes the :phone parameter, writing the error reply when it is invalid.
+func (a *API) adminPhone(c *gin.Context) (string, bool) {
+	p := c.Param("phone")
+	if err := a.normalizePhone(&p); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: CodeInvalidPhone, Message: "Invalid phone number"})
+		return "", false
+	}
+	return p, true
+}
+
+// phoneState shows what the service keeps about a phone.
+// @Summary Inspect phone state
+// @Description Last send, remaining interval, lockout, purposes with a secret and recent deliveries.
+// @Produce json
+// @Param phone path string true "Phone number"
+// @Success 200 {object} service.PhoneState
+// @Failure 400 {object} ErrorResponse "invalid_phone"
+// @Security ApiKeyAuth
+// @Router /admin/phones/{phone} [get]
+func (a *API) phoneState(c *gin.Context) {
+	p, ok := a.adminPhone(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, a.phoneAdm.State(c.Request.Context(), p))
+}
+
+// resetPhoneLimits lets a phone receive and verify codes again right away.
+// @Summary Reset phone rate limit and lockout
+// @Description Clears the send interval and the verification lockout of the phone.
+// @Param phone path string true "Phone number"
+// @Success 204
+// @Failure 400 {object} ErrorResponse "invalid_phone"
+// @Security ApiKeyAuth
+// @Router /admin/phones/{phone}/limits [delete]
+func (a *API) resetPhoneLimits(c *gin.Context) {
+	p, ok := a.adminPhone(c)
+	if !ok {
+		return
+	}
+	a.phoneAdm.ResetLimits(c.Request.Context(), p)
+	a.auditPhone(c, "phone.reset_limits", p)
+	c.Status(http.StatusNoContent)
+}
+
+// revokePhoneSecrets invalidates every code sent to a phone.
+// @Summary Revoke phone secrets
+// @Description Deletes the secrets of the phone for every purpose; the next send creates new ones.
+// @Produce json
+// @Param phone path string true "Phone number"
+// @Success 200 {object} RevokeSecretsResponse
+// @Failure 400 {object} ErrorResponse "invalid_phone"
+// @Security ApiKeyAuth
+// @Router /admin/phones/{phone}/secrets [delete]
+func (a *API) revokePhoneSecrets(c *gin.Context) {
+	p, ok := a.adminPhone(c)
+	if !ok {
+		return
+	}
+	n := a.phoneAdm.RevokeSecrets(c.Request.Context(), p)
+	a.auditPhone(c, "phone.revoke_secrets", p)
+	c.JSON(http.StatusOK, RevokeSecretsResponse{Revoked: n})
+}
+
+// healthReport runs the readiness checks for operators.
+// @Summary Dependency health
+// @Description Same checks as /readyz, the SMS provider balance included, always answered with 200.
+// @Produce json
+// @Success 200 {object} health.Report
+// @Security ApiKeyAuth
+// @Router /admin/health [get]
+func (a *API) healthReport(c *gin.Context) {
+	c.JSON(http.StatusOK, a.health.Ready(c.Request.Context()))
+}
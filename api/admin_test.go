@@ -0,0 +1,126 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/NlightN22/OTPSMSProvider/health"
+	"github.com/NlightN22/OTPSMSProvider/policy"
+	service "github.com/NlightN22/OTPSMSProvider/service"
+	storage "github.com/NlightN22/OTPSMSProvider/storage"
+)
+
+func TestAdminBlocklist(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	pol, err := policy.New(storage.NewMemoryStorage(), policy.Config{})
+	if err != nil {
+		t.Fatalf("policy.New error: %v", err)
+	}
+	a := NewAPI(&stubService{canSend: true}, WithPolicy(pol), WithAdminAuth(func(c *gin.Context) { c.Next() }))
+	router := gin.New()
+	a.RegisterRoutes(router)
+
+	w := performRequest(router, "POST", "/admin/blocklist", `{"entry":"+7999*"}`)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("POST status = %d; want %d", w.Code, http.StatusNoContent)
+	}
+	w = performRequest(router, "POST", "/admin/blocklist", `{"entry":"abc"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("POST invalid status = %d; want %d", w.Code, http.StatusBadRequest)
+	}
+
+	w = performRequest(router, "GET", "/admin/blocklist", "")
+	if !strings.Contains(w.Body.String(), `"runtime":["+7999*"]`) {
+		t.Errorf("GET body = %s; want runtime entry", w.Body.String())
+	}
+	w = performRequest(router, "POST", "/send", `{"phone":"+79991234567"}`)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("send status = %d; want %d", w.Code, http.StatusForbidden)
+	}
+
+	path := "/admin/blocklist?entry=" + url.QueryEscape("+7999*")
+	if w = performRequest(router, "DELETE", path, ""); w.Code != http.StatusNoContent {
+		t.Errorf("DELETE status = %d; want %d", w.Code, http.StatusNoContent)
+	}
+	if w = performRequest(router, "DELETE", path, ""); w.Code != http.StatusNotFound {
+		t.Errorf("second DELETE status = %d; want %d", w.Code, http.StatusNotFound)
+	}
+
+	router = gin.New()
+	NewAPI(&stubService{canSend: true}, WithPolicy(pol)).RegisterRoutes(router)
+	if w = performRequest(router, "POST", "/admin/blocklist", `{"entry":"+7999*"}`); w.Code != http.StatusNotFound {
+		t.Errorf("POST without admin auth = %d; want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+type stubPhoneAdmin struct {
+	reset, revoked string
+}
+
+func (p *stubPhoneAdmin) State(ctx context.Context, phone string) service.PhoneState {
+	return service.PhoneState{Phone: phone, Failures: 2}
+}
+func (p *stubPhoneAdmin) ResetLimits(ctx context.Context, phone string) bool {
+	p.reset = phone
+	return true
+}
+func (p *stubPhoneAdmin) RevokeSecrets(ctx context.Context, phone string) int {
+	p.revoked = phone
+	return 3
+}
+
+type stubHealth struct{}
+
+func (stubHealth) Ready(ctx context.Context) health.Report {
+	return health.Report{Status: health.StatusDegraded, Checks: map[string]health.CheckResult{"provider": {Status: "failing"}}}
+}
+
+func TestAdminPhones(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	adm := &stubPhoneAdmin{}
+	a := NewAPI(&stubService{}, WithPhoneAdmin(adm), WithHealthReport(stubHealth{}), WithAuth(allowAll{}))
+	router := gin.New()
+	a.RegisterRoutes(router)
+
+	w := performRequest(router, "GET", "/admin/phones/+79991234567", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"failures":2`) {
+		t.Errorf("GET = %d %s; want the phone state", w.Code, w.Body.String())
+	}
+	if w = performRequest(router, "GET", "/admin/phones/abc", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("GET invalid phone status = %d; want %d", w.Code, http.StatusBadRequest)
+	}
+	if w = performRequest(router, "DELETE", "/admin/phones/+79991234567/limits", ""); w.Code != http.StatusNoContent || adm.reset != "+79991234567" {
+		t.Errorf("DELETE limits = %d, reset %q; want 204 for the phone", w.Code, adm.reset)
+	}
+	w = performRequest(router, "DELETE", "/admin/phones/+79991234567/secrets", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"revoked":3`) || adm.revoked != "+79991234567" {
+		t.Errorf("DELETE secrets = %d %s; want 3 revoked", w.Code, w.Body.String())
+	}
+	w = performRequest(router, "GET", "/admin/health", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"provider"`) {
+		t.Errorf("GET health = %d %s; want the report with 200", w.Code, w.Body.String())
+	}
+}
+
+func TestAdminRoutes_RequireAuthenticator(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	a := NewAPI(&stubService{}, WithPhoneAdmin(&stubPhoneAdmin{}), WithHealthReport(stubHealth{}))
+	router := gin.New()
+	a.RegisterRoutes(router)
+
+	for _, r := range []struct{ method, path string }{
+		{"GET", "/admin/phones/+79991234567"},
+		{"DELETE", "/admin/phones/+79991234567/secrets"},
+		{"DELETE", "/admin/phones/+79991234567/limits"},
+		{"GET", "/admin/health"},
+	} {
+		if w := performRequest(router, r.method, r.path, ""); w.Code != http.StatusNotFound {
+			t.Errorf("%s %s without authenticator = %d; want 404", r.method, r.path, w.Code)
+		}
+	}
+}
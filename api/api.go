@@ -1,34 +1,245 @@
 package api
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/netip"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
+	"github.com/NlightN22/OTPSMSProvider/apikey"
+	"github.com/NlightN22/OTPSMSProvider/audit"
+	"github.com/NlightN22/OTPSMSProvider/budget"
+	"github.com/NlightN22/OTPSMSProvider/dashboard"
+	"github.com/NlightN22/OTPSMSProvider/health"
+	"github.com/NlightN22/OTPSMSProvider/message"
+	"github.com/NlightN22/OTPSMSProvider/middleware"
+	"github.com/NlightN22/OTPSMSProvider/phone"
+	"github.com/NlightN22/OTPSMSProvider/policy"
 	service "github.com/NlightN22/OTPSMSProvider/service"
+	"github.com/NlightN22/OTPSMSProvider/token"
+	"github.com/NlightN22/OTPSMSProvider/validator"
+	"github.com/NlightN22/OTPSMSProvider/webhook"
 )
 
-// SendRequest represents request for /send endpoint.
+var tracer = otel.Tracer("github.com/NlightN22/OTPSMSProvider/api")
+
+// SendRequest represents request for the legacy /send endpoint.
 type SendRequest struct {
-	Phone string `json:"phone" binding:"required,e164"`
+	Phone    string `json:"phone" binding:"required" example:"+79991234567"`        // E.164 or national format of the default region
+	Locale   string `json:"locale" example:"ru-RU"`                                 // message language, falls back to Accept-Language and then the default
+	ClientIP string `json:"client_ip" binding:"omitempty,ip" example:"203.0.113.7"` // end-user IP for rate limiting, honoured from trusted proxies and keys with the client_ip scope
 }
 
-// VerifyRequest represents request for /verify endpoint.
+// VerifyRequest represents request for the legacy /verify endpoint.
 type VerifyRequest struct {
 	Phone string `json:"phone" binding:"required"`
 	Code  string `json:"code" binding:"required"`
 }
 
+// RateLimiter decides whether a send from ip to phone is allowed.
+// On rejection it returns the exceeded limit and the time until the next attempt may succeed.
+type RateLimiter interface {
+	Allow(ip, phone string) (ok bool, reason string, wait time.Duration)
+}
+
+// Budget refuses sends once the SMS spend cap is reached and reports current spend.
+type Budget interface {
+	Allow(phone string) error
+	Status() budget.Status
+}
+
+// Policy decides whether a number may receive codes and manages the runtime blocklist.
+type Policy interface {
+	Check(phone string) error
+	Block(entry string) error
+	Unblock(entry string) bool
+	Entries() policy.Entries
+}
+
+// PhoneNormalizer turns user input into a canonical number.
+type PhoneNormalizer interface {
+	Normalize(raw string) (phone.Number, error)
+}
+
+// Authenticator guards routes by scope.
+type Authenticator interface {
+	Require(scope string) gin.HandlerFunc
+}
+
+// SignatureChecker rejects requests without a valid HMAC signature.
+type SignatureChecker interface {
+	Handler() gin.HandlerFunc
+}
+
+// TokenIssuer signs a JWT for a successful verification and publishes its keys.
+type TokenIssuer interface {
+	Issue(v token.Verification) (token.Issued, error)
+	JWKS() token.JWKS
+}
+
+// KeyManager mints, revokes and lists API keys.
+type KeyManager interface {
+	Mint(k apikey.Key) (token string, key apikey.Key, err error)
+	Revoke(id string) bool
+	List() []apikey.Key
+}
+
+// PhoneAdmin inspects and resets the state the service keeps per phone.
+type PhoneAdmin interface {
+	State(ctx context.Context, phone string) service.PhoneState
+	ResetLimits(ctx context.Context, phone string) bool
+	RevokeSecrets(ctx context.Context, phone string) int
+}
+
+// HealthReporter runs the readiness checks, the provider's included.
+type HealthReporter interface {
+	Ready(ctx context.Context) health.Report
+}
+
+// WebhookLog lists recent webhook deliveries, newest first.
+type WebhookLog interface {
+	Deliveries(limit int) []webhook.Delivery
+}
+
 // API groups TOTP handlers. Comments in English.
 type API struct {
-	svc service.OTPService
+	svc       service.OTPService
+	limiter   RateLimiter
+	proxies   []netip.Prefix
+	budget    Budget
+	policy    Policy
+	phones    PhoneNormalizer
+	plainText bool
+	auth      Authenticator
+	keys      KeyManager
+	signature SignatureChecker
+	tokens    TokenIssuer
+	audit     Auditor
+	webhooks  WebhookLog
+	phoneAdm  PhoneAdmin
+	health    HealthReporter
+	dashboard Dashboard
+
+	deliveries     DeliveryReceiver
+	callbackSecret string
+	admin          gin.HandlerFunc
+}
+
+// Option configures optional API dependencies.
+type Option func(*API)
+
+// WithLimiter enables abuse rate limits on /send.
+func WithLimiter(l RateLimiter) Option {
+	return func(a *API) {
+		a.limiter = l
+	}
+}
+
+// WithTrustedProxies lists the addresses whose client_ip is believed. Keys
+// with the client_ip scope may set it from anywhere.
+func WithTrustedProxies(prefixes []netip.Prefix) Option {
+	return func(a *API) {
+		a.proxies = prefixes
+	}
+}
+
+// WithBudget enables spend caps on /send and the /admin/budget endpoint.
+func WithBudget(b Budget) Option {
+	return func(a *API) {
+		a.budget = b
+	}
+}
+
+// WithPolicy enables number and country restrictions on /send and the /admin/blocklist endpoints.
+func WithPolicy(p Policy) Option {
+	return func(a *API) {
+		a.policy = p
+	}
+}
+
+// WithPhoneNormalizer accepts national and formatted numbers and passes their
+// E.164 form to the service. Without it only strict E.164 input is accepted.
+func WithPhoneNormalizer(n PhoneNormalizer) Option {
+	return func(a *API) {
+		a.phones = n
+	}
+}
+
+// WithAuth requires an API key with the matching scope on every route except Swagger.
+func WithAuth(auth Authenticator) Option {
+	return func(a *API) {
+		a.auth = auth
+	}
+}
+
+// WithSignature requires HMAC-signed requests on /send and /verify, legacy and /v1.
+func WithSignature(s SignatureChecker) Option {
+	return func(a *API) {
+		a.signature = s
+	}
+}
+
+// WithTokenIssuer adds a signed JWT to successful verify responses and serves
+// the public keys at /.well-known/jwks.json.
+func WithTokenIssuer(t TokenIssuer) Option {
+	return func(a *API) {
+		a.tokens = t
+	}
+}
+
+// WithPhoneAdmin enables the /admin/phones endpoints.
+func WithPhoneAdmin(p PhoneAdmin) Option {
+	return func(a *API) {
+		a.phoneAdm = p
+	}
+}
+
+// WithHealthReport enables the /admin/health endpoint.
+func WithHealthReport(h HealthReporter) Option {
+	return func(a *API) {
+		a.health = h
+	}
+}
+
+// WithWebhookLog enables the /admin/webhooks/deliveries endpoint.
+func WithWebhookLog(l WebhookLog) Option {
+	return func(a *API) {
+		a.webhooks = l
+	}
+}
+
+// WithKeyManager enables the /admin/keys endpoints.
+func WithKeyManager(m KeyManager) Option {
+	return func(a *API) {
+		a.keys = m
+	}
+}
+
+// WithAdminAuth guards the /admin endpoints with h unless WithAuth is set.
+// Without either they are not registered.
+func WithAdminAuth(h gin.HandlerFunc) Option {
+	return func(a *API) {
+		a.admin = h
+	}
 }
 
 // NewAPI creates a new API instance.
-func NewAPI(svc service.OTPService) *API {
-	return &API{svc: svc}
+func NewAPI(svc service.OTPService, opts ...Option) *API {
+	a := &API{svc: svc}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
 }
 
 // RegisterRoutes attaches routes and Swagger UI to the router.
@@ -37,59 +248,314 @@ func (a *API) RegisterRoutes(r *gin.Engine) {
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	// send TOTP code
-	r.POST("/send", a.send)
+	r.POST("/send", a.protect(apikey.ScopeSend, a.send)...)
 
 	// verify TOTP code
-	r.POST("/verify", a.verify)
+	r.POST("/verify", a.protect(apikey.ScopeVerify, a.verify)...)
+
+	a.registerV1Routes(r.Group("/v1"))
+	a.registerCallbackRoutes(r.Group("/callbacks"))
+
+	if a.dashboard != nil && a.auth != nil {
+		r.StaticFS("/dashboard", dashboard.Assets())
+	}
+
+	if a.tokens != nil {
+		r.GET("/.well-known/jwks.json", a.jwks)
+	}
+
+	// Operator endpoints change state, so they exist only behind an admin guard.
+	if guard := a.adminGuard(); guard != nil {
+		admin := r.Group("/admin", guard)
+		if a.audit != nil {
+			admin.Use(a.origin)
+		}
+		a.registerAdminRoutes(admin)
+	}
+}
+
+// adminGuard requires a key with the admin scope when keys are configured and
+// falls back to the admin token otherwise. nil disables the /admin endpoints.
+func (a *API) adminGuard() gin.HandlerFunc {
+	if a.auth != nil {
+		return a.auth.Require(apikey.ScopeAdmin)
+	}
+	return a.admin
+}
+
+// protect prepends the signature and scope checks to a client-facing handler.
+func (a *API) protect(scope string, h gin.HandlerFunc) []gin.HandlerFunc {
+	chain := make([]gin.HandlerFunc, 0, 4)
+	if a.audit != nil {
+		chain = append(chain, a.auditRequest(auditActions[scope]))
+	}
+	if a.signature != nil {
+		chain = append(chain, a.signature.Handler())
+	}
+	return append(chain, a.require(scope), h)
+}
+
+// auditActions maps the scope of a protected route to its audit action.
+var auditActions = map[string]string{
+	apikey.ScopeSend:   audit.ActionSend,
+	apikey.ScopeVerify: audit.ActionVerify,
+}
+
+// require returns the scope check of the configured Authenticator, or a no-op without one.
+func (a *API) require(scope string) gin.HandlerFunc {
+	if a.auth == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return a.auth.Require(scope)
 }
 
 // send handles code generation and SMS dispatch.
 // @Summary Generate and send TOTP code via SMS
-// @Description Generates TOTP for given phone and records send time
+// @Description Generates TOTP for given phone and records send time.
+// @Description Errors carry a stable code in "error"; rate-limited replies set Retry-After.
+// @Description Send "Accept: text/plain" to get the legacy plain-text replies.
+// @Description Kept for existing clients; new integrations should use /v1/send.
 // @Accept json
-// @Produce json
+// @Produce json,plain
 // @Param data body SendRequest true "Phone"
-// @Success 200 {string} string "Code sent"
-// @Failure 400 {string} string "Invalid request"
-// @Failure 429 {string} string "Too many requests"
+// @Success 200 {object} SendResponse
+// @Failure 400 {object} ErrorResponse "invalid_request, invalid_phone, unsupported_line_type, phone_unreachable"
+// @Failure 401 {object} ErrorResponse "unauthorized, invalid_signature, replayed_request"
+// @Failure 403 {object} ErrorResponse "ip_not_allowed, insufficient_scope, phone_not_allowed, phone_blocked, country_not_allowed"
+// @Failure 429 {object} ErrorResponse "rate_limited"
+// @Failure 500 {object} ErrorResponse "provider_error, internal_error"
+// @Failure 503 {object} ErrorResponse "provider_unavailable, budget_exceeded"
+// @Header 429 {integer} Retry-After "Seconds until the next attempt may succeed"
+// @Security ApiKeyAuth
 // @Router /send [post]
 func (a *API) send(c *gin.Context) {
 	var req SendRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.String(http.StatusBadRequest, "Invalid request")
+		a.fail(c, http.StatusBadRequest, ErrorResponse{Error: CodeInvalidRequest, Message: "Invalid request"})
 		return
 	}
-	ok, wait := a.svc.CanSend(req.Phone)
-	if !ok {
-		c.String(http.StatusTooManyRequests, "Please wait %s", wait)
+	if !a.sendCode(c, SendRequestV1{Phone: req.Phone, Locale: req.Locale, ClientIP: req.ClientIP}) {
 		return
 	}
-	if _, err := a.svc.GenerateCode(req.Phone); err != nil {
-		c.String(http.StatusInternalServerError, "Code generation error")
-		return
+	a.reply(c, SendResponse{Status: "sent"}, "Code sent")
+}
+
+// clientIP returns the end-user IP claimed in the request when the caller may
+// set it, and the connection's client IP otherwise.
+func (a *API) clientIP(c *gin.Context, claimed string) string {
+	if claimed == "" {
+		return c.ClientIP()
+	}
+	if key, ok := middleware.APIKeyFrom(c); ok && key.HasScope(apikey.ScopeClientIP) {
+		return claimed
+	}
+	if remote, err := netip.ParseAddr(c.RemoteIP()); err == nil {
+		remote = remote.Unmap()
+		for _, p := range a.proxies {
+			if p.Contains(remote) {
+				return claimed
+			}
+		}
+	}
+	return c.ClientIP()
+}
+
+// sendCode runs the checks of a send and dispatches the code. On failure it
+// writes the error reply and returns false.
+func (a *API) sendCode(c *gin.Context, req SendRequestV1) bool {
+	ctx, span := tracer.Start(c.Request.Context(), "API.send", trace.WithAttributes(
+		attribute.String("otp.purpose", req.Purpose),
+		attribute.String("otp.channel", req.Channel),
+		attribute.String("otp.client_reference", req.ClientReference),
+	))
+	defer span.End()
+	c.Request = c.Request.WithContext(ctx)
+
+	auditSubject(c, req.Phone, req.Purpose)
+	if req.Channel != "" && req.Channel != service.ChannelSMS {
+		a.fail(c, http.StatusBadRequest, ErrorResponse{Error: CodeUnsupportedChannel, Message: "Delivery channel is not supported"})
+		return false
+	}
+	if err := a.normalizePhone(&req.Phone); err != nil {
+		if errors.Is(err, phone.ErrLineType) {
+			a.fail(c, http.StatusBadRequest, ErrorResponse{Error: CodeUnsupportedLineType, Message: "Phone number type is not supported"})
+			return false
+		}
+		a.fail(c, http.StatusBadRequest, ErrorResponse{Error: CodeInvalidPhone, Message: "Invalid phone number"})
+		return false
+	}
+	auditSubject(c, req.Phone, req.Purpose)
+	span.SetAttributes(attribute.String("otp.country", phone.RegionOf(req.Phone)))
+	if key, ok := middleware.APIKeyFrom(c); ok && !key.AllowsPhone(req.Phone) {
+		a.fail(c, http.StatusForbidden, ErrorResponse{Error: CodePhoneNotAllowed, Message: "Phone number is not allowed for this API key"})
+		return false
+	}
+	if a.policy != nil {
+		switch err := a.policy.Check(req.Phone); {
+		case errors.Is(err, policy.ErrBlocked):
+			a.fail(c, http.StatusForbidden, ErrorResponse{Error: CodePhoneBlocked, Message: "Phone number is blocked"})
+			return false
+		case errors.Is(err, policy.ErrCountryNotAllowed):
+			a.fail(c, http.StatusForbidden, ErrorResponse{Error: CodeCountryNotAllowed, Message: "Destination country is not allowed"})
+			return false
+		}
+	}
+	ok, wait := a.svc.CanSend(c.Request.Context(), req.Phone)
+	if !ok {
+		a.fail(c, http.StatusTooManyRequests, ErrorResponse{
+			Error:             CodeRateLimited,
+			Message:           fmt.Sprintf("Please wait %s", wait),
+			Reason:            "interval",
+			RetryAfterSeconds: retryAfter(wait),
+		})
+		return false
+	}
+	if a.budget != nil {
+		if err := a.budget.Allow(req.Phone); err != nil {
+			a.fail(c, http.StatusServiceUnavailable, ErrorResponse{Error: CodeBudgetExceeded, Message: "SMS budget exceeded"})
+			return false
+		}
+	}
+	// The limiter goes last: it takes tokens, and a resend refused above must
+	// not drain the caller's buckets.
+	if a.limiter != nil {
+		if ok, reason, wait := a.limiter.Allow(a.clientIP(c, req.ClientIP), req.Phone); !ok {
+			a.fail(c, http.StatusTooManyRequests, ErrorResponse{
+				Error:             CodeRateLimited,
+				Message:           fmt.Sprintf("Rate limit exceeded (%s), retry in %s", reason, wait.Round(time.Second)),
+				Reason:            reason,
+				RetryAfterSeconds: retryAfter(wait),
+			})
+			return false
+		}
 	}
-	c.String(http.StatusOK, "Code sent")
+	locale := req.Locale
+	if locale == "" {
+		locale = acceptLanguage(c.GetHeader("Accept-Language"))
+	}
+	opts := service.SendOptions{
+		Locale:          locale,
+		Channel:         req.Channel,
+		Purpose:         req.Purpose,
+		TemplateID:      req.TemplateID,
+		ClientReference: req.ClientReference,
+		Metadata:        req.Metadata,
+		Payload:         req.Payload,
+	}
+	if _, err := a.svc.GenerateCode(c.Request.Context(), req.Phone, opts); err != nil {
+		switch {
+		case errors.Is(err, service.ErrUnknownPurpose):
+			a.fail(c, http.StatusBadRequest, ErrorResponse{Error: CodeUnknownPurpose, Message: "Unknown code purpose"})
+		case errors.Is(err, message.ErrUnknownTemplate):
+			a.fail(c, http.StatusBadRequest, ErrorResponse{Error: CodeUnknownTemplate, Message: "Unknown message template"})
+		case errors.Is(err, service.ErrInvalidPhone), errors.Is(err, service.ErrUndeliverable):
+			a.fail(c, http.StatusBadRequest, ErrorResponse{Error: CodePhoneUnreachable, Message: "Phone number is not reachable"})
+		case service.IsRetryable(err):
+			a.fail(c, http.StatusServiceUnavailable, ErrorResponse{Error: CodeProviderUnavailable, Message: "SMS provider temporarily unavailable"})
+		case errors.Is(err, service.ErrProviderPermanent):
+			a.fail(c, http.StatusInternalServerError, ErrorResponse{Error: CodeProviderError, Message: "Code generation error"})
+		default:
+			a.fail(c, http.StatusInternalServerError, ErrorResponse{Error: CodeInternal, Message: "Code generation error"})
+		}
+		return false
+	}
+	return true
 }
 
 // verify handles code validation.
 // @Summary Validate TOTP code
-// @Description Checks provided TOTP code for validity
+// @Description Checks provided TOTP code for validity.
+// @Description Kept for existing clients; new integrations should use /v1/verify.
 // @Accept json
-// @Produce json
+// @Produce json,plain
 // @Param data body VerifyRequest true "Code"
-// @Success 200 {string} string "Code valid"
-// @Failure 400 {string} string "Bad request"
-// @Failure 401 {string} string "Invalid code"
+// @Success 200 {object} VerifyResponse
+// @Failure 400 {object} ErrorResponse "invalid_request, invalid_phone"
+// @Failure 401 {object} ErrorResponse "unauthorized, invalid_signature, replayed_request, invalid_code"
+// @Failure 403 {object} ErrorResponse "ip_not_allowed, insufficient_scope"
+// @Security ApiKeyAuth
 // @Router /verify [post]
 func (a *API) verify(c *gin.Context) {
 	var req VerifyRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.String(http.StatusBadRequest, "Bad request")
+		a.fail(c, http.StatusBadRequest, ErrorResponse{Error: CodeInvalidRequest, Message: "Bad request"})
 		return
 	}
-	if a.svc.ValidateCode(req.Phone, req.Code) {
-		c.String(http.StatusOK, "Code valid")
-	} else {
-		c.String(http.StatusUnauthorized, "Invalid code")
+	issued, ok := a.verifyCode(c, VerifyRequestV1{Phone: req.Phone, Code: req.Code})
+	if !ok {
+		return
+	}
+	a.reply(c, VerifyResponse{Valid: true, TokenResponse: issued}, "Code valid")
+}
+
+// verifyCode checks a code and issues a token when enabled. On failure it
+// writes the error reply and returns false.
+func (a *API) verifyCode(c *gin.Context, req VerifyRequestV1) (TokenResponse, bool) {
+	ctx, span := tracer.Start(c.Request.Context(), "API.verify",
+		trace.WithAttributes(attribute.String("otp.purpose", req.Purpose)))
+	defer span.End()
+	c.Request = c.Request.WithContext(ctx)
+
+	auditSubject(c, req.Phone, req.Purpose)
+	if err := a.normalizePhone(&req.Phone); err != nil {
+		a.fail(c, http.StatusBadRequest, ErrorResponse{Error: CodeInvalidPhone, Message: "Invalid phone number"})
+		return TokenResponse{}, false
+	}
+	auditSubject(c, req.Phone, req.Purpose)
+	if !a.svc.ValidateCode(c.Request.Context(), req.Phone, req.Code, service.VerifyOptions{Purpose: req.Purpose, Payload: req.Payload}) {
+		a.fail(c, http.StatusUnauthorized, ErrorResponse{Error: CodeInvalidCode, Message: "Invalid code"})
+		return TokenResponse{}, false
+	}
+	if a.tokens == nil {
+		return TokenResponse{}, true
+	}
+	issued, err := a.tokens.Issue(token.Verification{Phone: req.Phone, Purpose: req.Purpose})
+	if err != nil {
+		a.fail(c, http.StatusInternalServerError, ErrorResponse{Error: CodeInternal, Message: "Token issuance error"})
+		return TokenResponse{}, false
+	}
+	return TokenResponse{
+		Token:          issued.Token,
+		TokenType:      "Bearer",
+		ExpiresIn:      int(time.Until(issued.ExpiresAt).Round(time.Second).Seconds()),
+		VerificationID: issued.VerificationID,
+	}, true
+}
+
+// jwks publishes the public keys that verify issued tokens.
+// @Summary JSON Web Key Set
+// @Description Public keys for offline validation of tokens returned by /verify. Cache for a few minutes; keys rotate.
+// @Produce json
+// @Success 200 {object} token.JWKS
+// @Router /.well-known/jwks.json [get]
+func (a *API) jwks(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, a.tokens.JWKS())
+}
+
+// normalizePhone replaces raw with its canonical E.164 form.
+func (a *API) normalizePhone(raw *string) error {
+	if a.phones == nil {
+		if !validator.IsE164(*raw) {
+			return phone.ErrInvalid
+		}
+		return nil
+	}
+	num, err := a.phones.Normalize(*raw)
+	if err != nil {
+		return err
+	}
+	*raw = num.E164
+	return nil
+}
+
+// acceptLanguage returns the first language tag of an Accept-Language header.
+func acceptLanguage(header string) string {
+	first, _, _ := strings.Cut(header, ",")
+	tag, _, _ := strings.Cut(first, ";")
+	tag = strings.TrimSpace(tag)
+	if tag == "*" {
+		return ""
 	}
+	return tag
 }
@@ -1,13 +1,20 @@
 package api
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/NlightN22/OTPSMSProvider/budget"
+	"github.com/NlightN22/OTPSMSProvider/phone"
+	"github.com/NlightN22/OTPSMSProvider/policy"
+	service "github.com/NlightN22/OTPSMSProvider/service"
+	storage "github.com/NlightN22/OTPSMSProvider/storage"
 	"github.com/NlightN22/OTPSMSProvider/validator"
 	"github.com/gin-gonic/gin"
 )
@@ -17,16 +24,35 @@ func init() {
 }
 
 type stubService struct {
-	canSend bool
-	wait    time.Duration
-	genErr  error
-	valid   bool
-	code    string
+	canSend    bool
+	wait       time.Duration
+	genErr     error
+	valid      bool
+	code       string
+	opts       service.SendOptions
+	verifyOpts service.VerifyOptions
+	key        string
 }
 
-func (s *stubService) CanSend(key string) (bool, time.Duration) { return s.canSend, s.wait }
-func (s *stubService) GenerateCode(key string) (string, error)  { return s.code, s.genErr }
-func (s *stubService) ValidateCode(key, code string) bool       { return s.valid && code == s.code }
+func (s *stubService) CanSend(ctx context.Context, key string) (bool, time.Duration) {
+	s.key = key
+	return s.canSend, s.wait
+}
+func (s *stubService) GenerateCode(ctx context.Context, key string, opts service.SendOptions) (string, error) {
+	s.opts = opts
+	return s.code, s.genErr
+}
+func (s *stubService) ValidateCode(ctx context.Context, key, code string, opts service.VerifyOptions) bool {
+	s.verifyOpts = opts
+	return s.valid && code == s.code
+}
+
+// allowAll is an Authenticator accepting every request, for tests of admin handlers.
+type allowAll struct{}
+
+func (allowAll) Require(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) { c.Next() }
+}
 
 func performRequest(r http.Handler, method, path, body string) *httptest.ResponseRecorder {
 	req := httptest.NewRequest(method, path, strings.NewReader(body))
@@ -100,3 +126,203 @@ func TestVerifyEndpoint_Invalid(t *testing.T) {
 		t.Errorf("status = %d; want %d", w.Code, http.StatusUnauthorized)
 	}
 }
+
+func TestSendEndpoint_ProviderErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cases := []struct {
+		err  error
+		want int
+	}{
+		{&service.ProviderError{Provider: "smsc", Code: 7, Kind: service.ErrInvalidPhone}, http.StatusBadRequest},
+		{&service.ProviderError{Provider: "smsc", Code: 9, Kind: service.ErrProviderThrottled, Retryable: true}, http.StatusServiceUnavailable},
+		{&service.ProviderError{Provider: "smsc", Code: 3, Kind: service.ErrInsufficientFunds}, http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		a := NewAPI(&stubService{canSend: true, genErr: tc.err})
+		router := gin.New()
+		a.RegisterRoutes(router)
+
+		w := performRequest(router, "POST", "/send", `{"phone":"+1234567890"}`)
+		if w.Code != tc.want {
+			t.Errorf("%v: status = %d; want %d", tc.err, w.Code, tc.want)
+		}
+	}
+}
+
+func TestSendEndpoint_Locale(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	stub := &stubService{canSend: true, code: "123456"}
+	a := NewAPI(stub)
+	router := gin.New()
+	a.RegisterRoutes(router)
+
+	performRequest(router, "POST", "/send", `{"phone":"+1234567890","locale":"ru"}`)
+	if stub.opts.Locale != "ru" {
+		t.Errorf("Locale = %q; want \"ru\"", stub.opts.Locale)
+	}
+
+	req := httptest.NewRequest("POST", "/send", strings.NewReader(`{"phone":"+1234567890"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Language", "ru-RU,ru;q=0.9,en;q=0.8")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	if stub.opts.Locale != "ru-RU" {
+		t.Errorf("Locale = %q; want \"ru-RU\"", stub.opts.Locale)
+	}
+}
+
+type stubLimiter struct {
+	ip, phone string
+	ok        bool
+}
+
+func (l *stubLimiter) Allow(ip, phone string) (bool, string, time.Duration) {
+	l.ip, l.phone = ip, phone
+	return l.ok, "ip", 10 * time.Second
+}
+
+func TestSendEndpoint_Limiter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	stub := &stubService{canSend: true, code: "123456"}
+	limiter := &stubLimiter{ok: false}
+	a := NewAPI(stub, WithLimiter(limiter), WithTrustedProxies([]netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")}))
+	router := gin.New()
+	a.RegisterRoutes(router)
+
+	w := performRequest(router, "POST", "/send", `{"phone":"+1234567890","client_ip":"203.0.113.7"}`)
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("status = %d; want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if !strings.Contains(w.Body.String(), "ip") {
+		t.Errorf("body = %q; want reason", w.Body.String())
+	}
+	if limiter.ip != "203.0.113.7" || limiter.phone != "+1234567890" {
+		t.Errorf("limiter got %q,%q; want request client_ip and phone", limiter.ip, limiter.phone)
+	}
+
+	limiter.ok = true
+	w = performRequest(router, "POST", "/send", `{"phone":"+1234567890"}`)
+	if w.Code != http.StatusOK {
+		t.Errorf("status = %d; want %d", w.Code, http.StatusOK)
+	}
+	if limiter.ip != "192.0.2.1" {
+		t.Errorf("limiter ip = %q; want request remote address", limiter.ip)
+	}
+}
+
+func TestSendEndpoint_LimiterAfterLocalChecks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limiter := &stubLimiter{ok: true}
+	router := gin.New()
+	NewAPI(&stubService{canSend: false, wait: time.Second}, WithLimiter(limiter)).RegisterRoutes(router)
+	budgeted := gin.New()
+	NewAPI(&stubService{canSend: true}, WithLimiter(limiter), WithBudget(&stubBudget{err: budget.ErrExceeded})).RegisterRoutes(budgeted)
+
+	if w := performRequest(router, "POST", "/send", `{"phone":"+1234567890"}`); w.Code != http.StatusTooManyRequests {
+		t.Errorf("early resend = %d; want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if w := performRequest(budgeted, "POST", "/send", `{"phone":"+1234567890"}`); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("over budget = %d; want %d", w.Code, http.StatusServiceUnavailable)
+	}
+	if limiter.ip != "" {
+		t.Errorf("limiter consulted for %q; want refused sends to leave the buckets alone", limiter.ip)
+	}
+}
+
+type stubBudget struct {
+	err error
+}
+
+func (b *stubBudget) Allow(phone string) error { return b.err }
+func (b *stubBudget) Status() budget.Status {
+	return budget.Status{Currency: "RUB", Day: budget.PeriodStatus{Spent: 12, Limit: 10, Exceeded: true}}
+}
+
+func TestSendEndpoint_BudgetExceeded(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	a := NewAPI(&stubService{canSend: true}, WithBudget(&stubBudget{err: budget.ErrExceeded}))
+	router := gin.New()
+	a.RegisterRoutes(router)
+
+	w := performRequest(router, "POST", "/send", `{"phone":"+1234567890"}`)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d; want %d", w.Code, http.StatusServiceUnavailable)
+	}
+
+	if w = performRequest(router, "GET", "/admin/budget", ""); w.Code != http.StatusNotFound {
+		t.Errorf("GET /admin/budget without admin auth = %d; want 404", w.Code)
+	}
+}
+
+func TestAdminBudget(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deny := func(c *gin.Context) { c.AbortWithStatus(http.StatusUnauthorized) }
+	router := gin.New()
+	NewAPI(&stubService{}, WithBudget(&stubBudget{}), WithAdminAuth(deny)).RegisterRoutes(router)
+	if w := performRequest(router, "GET", "/admin/budget", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("GET /admin/budget = %d; want 401 from the admin guard", w.Code)
+	}
+
+	router = gin.New()
+	NewAPI(&stubService{}, WithBudget(&stubBudget{}), WithAdminAuth(func(c *gin.Context) { c.Next() })).RegisterRoutes(router)
+	w := performRequest(router, "GET", "/admin/budget", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"exceeded":true`) {
+		t.Errorf("GET /admin/budget = %d %s; want 200 with status", w.Code, w.Body.String())
+	}
+}
+
+func TestSendEndpoint_Policy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	pol, err := policy.New(storage.NewMemoryStorage(), policy.Config{
+		Blocklist:      []string{"+79990000000"},
+		AllowCountries: []string{"7"},
+	})
+	if err != nil {
+		t.Fatalf("policy.New error: %v", err)
+	}
+	a := NewAPI(&stubService{canSend: true}, WithPolicy(pol))
+	router := gin.New()
+	a.RegisterRoutes(router)
+
+	w := performRequest(router, "POST", "/send", `{"phone":"+79990000000"}`)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "blocked") {
+		t.Errorf("blocked number = %d %q; want 403 blocked", w.Code, w.Body.String())
+	}
+	w = performRequest(router, "POST", "/send", `{"phone":"+15550000000"}`)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "country") {
+		t.Errorf("other country = %d %q; want 403 country", w.Code, w.Body.String())
+	}
+	w = performRequest(router, "POST", "/send", `{"phone":"+79991234567"}`)
+	if w.Code != http.StatusOK {
+		t.Errorf("allowed number = %d; want 200", w.Code)
+	}
+}
+
+func TestSendEndpoint_PhoneNormalization(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	normalizer, err := phone.NewNormalizer("RU", []string{"mobile"})
+	if err != nil {
+		t.Fatalf("NewNormalizer error: %v", err)
+	}
+	stub := &stubService{canSend: true, valid: true, code: "123456"}
+	a := NewAPI(stub, WithPhoneNormalizer(normalizer))
+	router := gin.New()
+	a.RegisterRoutes(router)
+
+	w := performRequest(router, "POST", "/send", `{"phone":"8 (999) 123-45-67"}`)
+	if w.Code != http.StatusOK {
+		t.Errorf("national format status = %d; want %d", w.Code, http.StatusOK)
+	}
+	if stub.key != "+79991234567" {
+		t.Errorf("service key = %q; want +79991234567", stub.key)
+	}
+
+	w = performRequest(router, "POST", "/send", `{"phone":"+7 809 123 45 67"}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "type") {
+		t.Errorf("premium status = %d %q; want 400 type not supported", w.Code, w.Body.String())
+	}
+
+	w = performRequest(router, "POST", "/verify", `{"phone":"+7 999 123-45-67","code":"123456"}`)
+	if w.Code != http.StatusOK || stub.key != "+79991234567" {
+		t.Errorf("verify = %d key %q; want 200 with canonical key", w.Code, stub.key)
+	}
+}
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/NlightN22/OTPSMSProvider/audit"
+	"github.com/NlightN22/OTPSMSProvider/middleware"
+)
+
+// Auditor records audit events.
+type Auditor interface {
+	Record(ctx context.Context, e audit.Event) error
+}
+
+// Context keys for the audit trail.
+const (
+	errorCodeKey    = "api.error"         // error code written by fail
+	auditPhoneKey   = "api.audit.phone"   // phone of a send or verification
+	auditPurposeKey = "api.audit.purpose" // its purpose
+)
+
+// WithAudit records admin actions and the final reply to every send and
+// verification, rejections by the authentication and signature checks
+// included. Service events of those requests are merged into the API record;
+// deliveries and lockouts are recorded by the auditor's own event handler,
+// which relies on the origin attached here.
+func WithAudit(au Auditor) Option {
+	return func(a *API) {
+		a.audit = au
+	}
+}
+
+// origin attaches the caller's API key ID and IP to the request context.
+func (a *API) origin(c *gin.Context) {
+	o := audit.Origin{IP: c.ClientIP()}
+	if key, ok := middleware.APIKeyFrom(c); ok {
+		o.Actor = key.ID
+	}
+	c.Request = c.Request.WithContext(audit.WithOrigin(c.Request.Context(), o))
+	c.Next()
+}
+
+// auditRequest records the outcome of a send or verification once the reply
+// is written. It runs before the signature and API key checks, so their
+// rejections are recorded too.
+func (a *API) auditRequest(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		d := &audit.Deferred{}
+		ctx := audit.WithOrigin(c.Request.Context(), audit.Origin{IP: c.ClientIP()})
+		ctx = audit.WithDeferred(ctx, d)
+		c.Request = c.Request.WithContext(ctx)
+		w := &errorCapture{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+
+		e, _ := d.Event()
+		e.Action = action
+		e.Phone, e.Purpose = c.GetString(auditPhoneKey), c.GetString(auditPurposeKey)
+		if key, ok := middleware.APIKeyFrom(c); ok {
+			e.Actor = key.ID
+		}
+		if w.Status() < http.StatusBadRequest {
+			e.Outcome = successOutcomes[action]
+		} else {
+			code := c.GetString(errorCodeKey)
+			if code == "" {
+				code = w.errorCode()
+			}
+			// The service outcome, such as "locked", explains the API's code.
+			if e.Outcome != "" && e.Detail == "" {
+				e.Detail = e.Outcome
+			}
+			e.Outcome = code
+		}
+		a.audit.Record(ctx, e)
+	}
+}
+
+// successOutcomes is the outcome of a send or verification answered with success.
+var successOutcomes = map[string]string{
+	audit.ActionSend:   audit.OutcomeSent,
+	audit.ActionVerify: audit.OutcomeValid,
+}
+
+// auditSubject notes the phone and purpose of a send or verification.
+func auditSubject(c *gin.Context, phone, purpose string) {
+	c.Set(auditPhoneKey, phone)
+	c.Set(auditPurposeKey, purpose)
+}
+
+// maxErrorBody caps how much of an error reply errorCapture keeps.
+const maxErrorBody = 1 << 10
+
+// errorCapture keeps the start of error replies written by middlewares, whose
+// error code is only in the body.
+type errorCapture struct {
+	gin.ResponseWriter
+	body []byte
+}
+
+func (w *errorCapture) Write(b []byte) (int, error) {
+	w.keep(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *errorCapture) WriteString(s string) (int, error) {
+	w.keep([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *errorCapture) keep(b []byte) {
+	if w.Status() >= http.StatusBadRequest && len(w.body) < maxErrorBody {
+		w.body = append(w.body, b[:min(len(b), maxErrorBody-len(w.body))]...)
+	}
+}
+
+// errorCode returns the "error" field of the captured reply, or the HTTP
+// status text when there is none.
+func (w *errorCapture) errorCode() string {
+	var resp ErrorResponse
+	if json.Unmarshal(w.body, &resp) == nil && resp.Error != "" {
+		return resp.Error
+	}
+	return http.StatusText(w.Status())
+}
+
+// auditAdmin records a successful admin operation on target.
+func (a *API) auditAdmin(c *gin.Context, op, target string) {
+	if a.audit == nil {
+		return
+	}
+	a.audit.Record(c.Request.Context(), audit.Event{
+		Action:  audit.ActionAdminPrefix + op,
+		Outcome: audit.OutcomeOK,
+		Target:  target,
+	})
+}
+
+// auditPhone records a successful admin operation on a phone.
+func (a *API) auditPhone(c *gin.Context, op, phone string) {
+	if a.audit == nil {
+		return
+	}
+	a.audit.Record(c.Request.Context(), audit.Event{
+		Action:  audit.ActionAdminPrefix + op,
+		Outcome: audit.OutcomeOK,
+		Phone:   phone,
+	})
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/NlightN22/OTPSMSProvider/audit"
+	"github.com/NlightN22/OTPSMSProvider/policy"
+	storage "github.com/NlightN22/OTPSMSProvider/storage"
+	"github.com/NlightN22/OTPSMSProvider/token"
+)
+
+type recordingAuditor struct {
+	events  []audit.Event
+	origins []audit.Origin
+}
+
+func (r *recordingAuditor) Record(ctx context.Context, e audit.Event) error {
+	r.events = append(r.events, e)
+	r.origins = append(r.origins, audit.OriginFrom(ctx))
+	return nil
+}
+
+// denyAll is an Authenticator rejecting every request like the API key middleware.
+type denyAll struct{}
+
+func (denyAll) Require(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Error: CodeUnauthorized, Message: "API key required"})
+	}
+}
+
+// failingIssuer cannot issue tokens.
+type failingIssuer struct{}
+
+func (failingIssuer) Issue(token.Verification) (token.Issued, error) {
+	return token.Issued{}, errors.New("no signing key")
+}
+func (failingIssuer) JWKS() token.JWKS { return token.JWKS{} }
+
+func TestAudit_RecordsFinalOutcomes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	pol, err := policy.New(storage.NewMemoryStorage(), policy.Config{})
+	if err != nil {
+		t.Fatalf("policy.New error: %v", err)
+	}
+	rec := &recordingAuditor{}
+	a := NewAPI(&stubService{canSend: true, valid: true}, WithPolicy(pol), WithAudit(rec), WithAdminAuth(func(c *gin.Context) { c.Next() }))
+	router := gin.New()
+	a.RegisterRoutes(router)
+
+	if w := performRequest(router, "POST", "/admin/blocklist", `{"entry":"+7999*"}`); w.Code != http.StatusNoContent {
+		t.Fatalf("POST blocklist status = %d", w.Code)
+	}
+	performRequest(router, "POST", "/v1/send", `{"phone":"+79991234567","purpose":"login"}`)
+	performRequest(router, "POST", "/v1/send", `{"phone":"+15551234567"}`)
+	performRequest(router, "POST", "/v1/verify", `{"phone":"bad","code":"123456"}`)
+	performRequest(router, "POST", "/send", `{"phone":`)
+
+	denied := gin.New()
+	NewAPI(&stubService{canSend: true}, WithAudit(rec), WithAuth(denyAll{})).RegisterRoutes(denied)
+	performRequest(denied, "POST", "/v1/send", `{"phone":"+15551234567"}`)
+
+	failing := gin.New()
+	NewAPI(&stubService{valid: true, code: "123456"}, WithAudit(rec), WithTokenIssuer(failingIssuer{})).RegisterRoutes(failing)
+	if w := performRequest(failing, "POST", "/v1/verify", `{"phone":"+15551234567","code":"123456"}`); w.Code != http.StatusInternalServerError {
+		t.Fatalf("verify with failing issuer = %d; want 500", w.Code)
+	}
+
+	want := []audit.Event{
+		{Action: "admin.blocklist.add", Outcome: audit.OutcomeOK, Target: "+7999*"},
+		{Action: audit.ActionSend, Outcome: CodePhoneBlocked, Phone: "+79991234567", Purpose: "login"},
+		{Action: audit.ActionSend, Outcome: audit.OutcomeSent, Phone: "+15551234567"},
+		{Action: audit.ActionVerify, Outcome: CodeInvalidPhone, Phone: "bad"},
+		{Action: audit.ActionSend, Outcome: CodeInvalidRequest},
+		{Action: audit.ActionSend, Outcome: CodeUnauthorized},
+		{Action: audit.ActionVerify, Outcome: CodeInternal, Phone: "+15551234567"},
+	}
+	if len(rec.events) != len(want) {
+		t.Fatalf("events = %+v; want %d", rec.events, len(want))
+	}
+	for i, w := range want {
+		if rec.events[i] != w {
+			t.Errorf("event %d = %+v; want %+v", i, rec.events[i], w)
+		}
+		if rec.origins[i].IP == "" {
+			t.Errorf("event %d has no origin IP", i)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/NlightN22/OTPSMSProvider/apikey"
+	"github.com/NlightN22/OTPSMSProvider/middleware"
+	"github.com/NlightN22/OTPSMSProvider/signing"
+	storage "github.com/NlightN22/OTPSMSProvider/storage"
+)
+
+func performKeyRequest(r http.Handler, method, path, body, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestAPIKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	keys, err := apikey.NewManager(storage.NewMemoryStorage(), nil)
+	if err != nil {
+		t.Fatalf("NewManager error: %v", err)
+	}
+	admin, _, err := keys.Mint(apikey.Key{Name: "ops", Scopes: []string{apikey.ScopeAdmin}})
+	if err != nil {
+		t.Fatalf("Mint error: %v", err)
+	}
+	a := NewAPI(&stubService{canSend: true, code: "123456"},
+		WithAuth(middleware.NewAPIKeyMiddleware(keys, nil)), WithKeyManager(keys))
+	router := gin.New()
+	a.RegisterRoutes(router)
+
+	if w := performKeyRequest(router, "POST", "/v1/send", `{"phone":"+79991234567"}`, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("send without key = %d; want %d", w.Code, http.StatusUnauthorized)
+	}
+	if w := performKeyRequest(router, "POST", "/v1/send", `{"phone":"+79991234567"}`, admin); w.Code != http.StatusForbidden {
+		t.Errorf("send with admin key = %d; want %d", w.Code, http.StatusForbidden)
+	}
+
+	w := performKeyRequest(router, "POST", "/admin/keys", `{"name":"shop","scopes":["send"],"phone_prefixes":["+7"]}`, admin)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("mint = %d %s; want %d", w.Code, w.Body, http.StatusCreated)
+	}
+	var minted MintKeyResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &minted); err != nil || minted.Token == "" {
+		t.Fatalf("mint body = %s", w.Body)
+	}
+
+	if w := performKeyRequest(router, "POST", "/v1/send", `{"phone":"+79991234567"}`, minted.Token); w.Code != http.StatusOK {
+		t.Errorf("send with key = %d %s; want %d", w.Code, w.Body, http.StatusOK)
+	}
+	w = performKeyRequest(router, "POST", "/send", `{"phone":"+15551234567"}`, minted.Token)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), CodePhoneNotAllowed) {
+		t.Errorf("send outside prefixes = %d %s; want 403 %s", w.Code, w.Body, CodePhoneNotAllowed)
+	}
+	if w := performKeyRequest(router, "POST", "/admin/keys", `{"name":"x","scopes":["root"]}`, admin); w.Code != http.StatusBadRequest {
+		t.Errorf("mint with bad scope = %d; want %d", w.Code, http.StatusBadRequest)
+	}
+
+	if w := performKeyRequest(router, "DELETE", "/admin/keys/"+minted.Key.ID, "", admin); w.Code != http.StatusNoContent {
+		t.Errorf("revoke = %d; want %d", w.Code, http.StatusNoContent)
+	}
+	if w := performKeyRequest(router, "POST", "/v1/send", `{"phone":"+79991234567"}`, minted.Token); w.Code != http.StatusUnauthorized {
+		t.Errorf("send with revoked key = %d; want %d", w.Code, http.StatusUnauthorized)
+	}
+	if w := performKeyRequest(router, "DELETE", "/admin/keys/"+minted.Key.ID, "", admin); w.Code != http.StatusNotFound {
+		t.Errorf("second revoke = %d; want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestAPIKeys_ReplaceAdminToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	keys, err := apikey.NewManager(storage.NewMemoryStorage(), nil)
+	if err != nil {
+		t.Fatalf("NewManager error: %v", err)
+	}
+	a := NewAPI(&stubService{}, WithKeyManager(keys),
+		WithAuth(middleware.NewAPIKeyMiddleware(keys, nil)),
+		WithAdminAuth(middleware.NewAdminTokenMiddleware("ops-token").Handler()))
+	router := gin.New()
+	a.RegisterRoutes(router)
+
+	if w := performKeyRequest(router, "GET", "/admin/keys", "", "ops-token"); w.Code != http.StatusUnauthorized {
+		t.Errorf("admin token with API keys = %d; want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestSignedRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	secret := strings.Repeat("k", 32)
+	v, err := signing.NewVerifier(storage.NewMemoryStorage(), map[string]string{"shop": secret}, time.Minute)
+	if err != nil {
+		t.Fatalf("NewVerifier error: %v", err)
+	}
+	a := NewAPI(&stubService{canSend: true, valid: true, code: "123456"}, WithSignature(middleware.NewSignatureMiddleware(v)))
+	router := gin.New()
+	a.RegisterRoutes(router)
+
+	for _, path := range []string{"/send", "/v1/send"} {
+		if w := performRequest(router, "POST", path, `{"phone":"+79991234567"}`); w.Code != http.StatusUnauthorized {
+			t.Errorf("unsigned %s = %d; want %d", path, w.Code, http.StatusUnauthorized)
+		}
+		req := httptest.NewRequest("POST", path, strings.NewReader(`{"phone":"+79991234567"}`))
+		req.Header.Set("Content-Type", "application/json")
+		if err := signing.NewSigner("shop", secret).Sign(req); err != nil {
+			t.Fatalf("Sign error: %v", err)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Errorf("signed %s = %d %s; want %d", path, w.Code, w.Body, http.StatusOK)
+		}
+	}
+}
+
+func TestSendEndpoint_ClientIPTrust(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	keys, err := apikey.NewManager(storage.NewMemoryStorage(), nil)
+	if err != nil {
+		t.Fatalf("NewManager error: %v", err)
+	}
+	plain, _, err := keys.Mint(apikey.Key{Name: "shop", Scopes: []string{apikey.ScopeSend}})
+	if err != nil {
+		t.Fatalf("Mint error: %v", err)
+	}
+	backend, _, err := keys.Mint(apikey.Key{Name: "backend", Scopes: []string{apikey.ScopeSend, apikey.ScopeClientIP}})
+	if err != nil {
+		t.Fatalf("Mint error: %v", err)
+	}
+	limiter := &stubLimiter{ok: true}
+	a := NewAPI(&stubService{canSend: true, code: "123456"}, WithLimiter(limiter),
+		WithAuth(middleware.NewAPIKeyMiddleware(keys, nil)))
+	router := gin.New()
+	a.RegisterRoutes(router)
+
+	body := `{"phone":"+79991234567","client_ip":"203.0.113.7"}`
+	if w := performKeyRequest(router, "POST", "/send", body, plain); w.Code != http.StatusOK || limiter.ip != "192.0.2.1" {
+		t.Errorf("client_ip from a key without the scope: status %d, limiter ip %q; want the remote address", w.Code, limiter.ip)
+	}
+	if w := performKeyRequest(router, "POST", "/send", body, backend); w.Code != http.StatusOK || limiter.ip != "203.0.113.7" {
+		t.Errorf("client_ip from a key with the scope: status %d, limiter ip %q; want the claimed address", w.Code, limiter.ip)
+	}
+}
+
+func TestSendEndpoint_ClientIPIgnoredFromUntrustedCaller(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limiter := &stubLimiter{ok: true}
+	a := NewAPI(&stubService{canSend: true, code: "123456"}, WithLimiter(limiter),
+		WithTrustedProxies([]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}))
+	router := gin.New()
+	a.RegisterRoutes(router)
+
+	for _, path := range []string{"/send", "/v1/send"} {
+		limiter.ip = ""
+		w := performRequest(router, "POST", path, `{"phone":"+79991234567","client_ip":"203.0.113.7"}`)
+		if w.Code != http.StatusOK || limiter.ip != "192.0.2.1" {
+			t.Errorf("%s: status %d, limiter ip %q; want the remote address", path, w.Code, limiter.ip)
+		}
+	}
+}
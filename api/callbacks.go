@@ -0,0 +1,60 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	service "github.com/NlightN22/OTPSMSProvider/service"
+)
+
+// DeliveryReceiver accepts delivery reports from the SMS provider.
+type DeliveryReceiver interface {
+	ReportDelivery(ctx context.Context, r service.DeliveryReport)
+}
+
+// WithDeliveryReports enables the SMSC status callback at /callbacks/smsc.
+// SMSC cannot send an API key, so the callback URL carries secret in the
+// "secret" query parameter; with an empty secret the route is not registered.
+func WithDeliveryReports(r DeliveryReceiver, secret string) Option {
+	return func(a *API) {
+		a.deliveries = r
+		a.callbackSecret = secret
+	}
+}
+
+func (a *API) registerCallbackRoutes(g *gin.RouterGroup) {
+	if a.deliveries != nil && a.callbackSecret != "" {
+		g.POST("/smsc", a.smscStatus)
+	}
+}
+
+// smscStatus receives SMSC delivery statuses.
+// @Summary SMSC delivery status callback
+// @Description Set "<base URL>/callbacks/smsc?secret=..." as the status URL in the SMSC account.
+// @Description Final statuses raise code.delivered or code.delivery_failed events; others are acknowledged and ignored.
+// @Accept x-www-form-urlencoded
+// @Produce plain
+// @Param secret query string true "Callback secret"
+// @Param id formData string true "Message ID"
+// @Param phone formData string false "Phone without the plus"
+// @Param status formData string true "SMSC status code"
+// @Success 200 {string} string "OK"
+// @Failure 401 {object} ErrorResponse "unauthorized"
+// @Router /callbacks/smsc [post]
+func (a *API) smscStatus(c *gin.Context) {
+	if subtle.ConstantTimeCompare([]byte(c.Query("secret")), []byte(a.callbackSecret)) != 1 {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: CodeUnauthorized, Message: "Invalid callback secret"})
+		return
+	}
+	if err := c.Request.ParseForm(); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: CodeInvalidRequest, Message: "Invalid request"})
+		return
+	}
+	if r, ok := service.ParseSMSCStatus(c.Request.PostForm); ok {
+		a.deliveries.ReportDelivery(c.Request.Context(), r)
+	}
+	c.String(http.StatusOK, "OK")
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	service "github.com/NlightN22/OTPSMSProvider/service"
+	"github.com/NlightN22/OTPSMSProvider/webhook"
+)
+
+type recordingReceiver struct {
+	reports []service.DeliveryReport
+}
+
+func (r *recordingReceiver) ReportDelivery(ctx context.Context, d service.DeliveryReport) {
+	r.reports = append(r.reports, d)
+}
+
+type stubWebhookLog []webhook.Delivery
+
+func (l stubWebhookLog) Deliveries(limit int) []webhook.Delivery {
+	return l[:min(limit, len(l))]
+}
+
+func TestSMSCStatusCallback(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rec := &recordingReceiver{}
+	a := NewAPI(&stubService{}, WithDeliveryReports(rec, "cb-secret"))
+	router := gin.New()
+	a.RegisterRoutes(router)
+
+	post := func(path, form string) int {
+		req := httptest.NewRequest("POST", path, strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	if code := post("/callbacks/smsc?secret=wrong", "id=1&phone=79991234567&status=1"); code != http.StatusUnauthorized {
+		t.Errorf("wrong secret status = %d; want %d", code, http.StatusUnauthorized)
+	}
+	if code := post("/callbacks/smsc?secret=cb-secret", "id=1&phone=79991234567&status=1"); code != http.StatusOK {
+		t.Errorf("status = %d; want %d", code, http.StatusOK)
+	}
+	post("/callbacks/smsc?secret=cb-secret", "id=2&phone=79991234567&status=0")
+	if len(rec.reports) != 1 || !rec.reports[0].Delivered || rec.reports[0].MessageID != "1" {
+		t.Errorf("reports = %+v; want one delivered report for message 1", rec.reports)
+	}
+}
+
+func TestSMSCStatusCallback_RequiresSecret(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rec := &recordingReceiver{}
+	a := NewAPI(&stubService{}, WithDeliveryReports(rec, ""))
+	router := gin.New()
+	a.RegisterRoutes(router)
+
+	req := httptest.NewRequest("POST", "/callbacks/smsc", strings.NewReader("id=1&phone=79991234567&status=1"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound || len(rec.reports) != 0 {
+		t.Errorf("callback without a secret = %d, reports %+v; want 404 and none", w.Code, rec.reports)
+	}
+}
+
+func TestAdminWebhookDeliveries(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	a := NewAPI(&stubService{}, WithWebhookLog(stubWebhookLog{{ID: "a", Delivered: true}, {ID: "b"}}), WithAdminAuth(func(c *gin.Context) { c.Next() }))
+	router := gin.New()
+	a.RegisterRoutes(router)
+
+	w := performRequest(router, "GET", "/admin/webhooks/deliveries?limit=1", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"id":"a"`) || strings.Contains(w.Body.String(), `"id":"b"`) {
+		t.Errorf("GET = %d %s; want only the newest delivery", w.Code, w.Body.String())
+	}
+	if w := performRequest(router, "GET", "/admin/webhooks/deliveries?limit=x", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid limit status = %d; want %d", w.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/NlightN22/OTPSMSProvider/dashboard"
+)
+
+// Dashboard provides the data of the operator UI.
+type Dashboard interface {
+	Stats() dashboard.Stats
+	Events(q dashboard.Query) []dashboard.Event
+}
+
+// WithDashboard serves the operator UI at /dashboard/ and its data under
+// /admin/dashboard. The UI files are public; every request for data needs the
+// admin scope, so nothing is served without WithAuth.
+func WithDashboard(d Dashboard) Option {
+	return func(a *API) {
+		a.dashboard = d
+	}
+}
+
+// dashboardStats returns event counts for the operator UI.
+// @Summary Dashboard statistics
+// @Description Event counts since start and per minute over the last hour.
+// @Produce json
+// @Success 200 {object} dashboard.Stats
+// @Security ApiKeyAuth
+// @Router /admin/dashboard/stats [get]
+func (a *API) dashboardStats(c *gin.Context) {
+	c.JSON(http.StatusOK, a.dashboard.Stats())
+}
+
+// dashboardEvents returns recent service events with masked phones.
+// @Summary Recent events
+// @Description Newest first. A full phone number matches its masked form; shorter input matches part of a masked phone.
+// @Produce json
+// @Param phone query string false "Full number or part of a masked one"
+// @Param failures query bool false "Only failures"
+// @Param limit query int false "Maximum entries" default(50)
+// @Success 200 {array} dashboard.Event
+// @Failure 400 {object} ErrorResponse "invalid_request"
+// @Security ApiKeyAuth
+// @Router /admin/dashboard/events [get]
+func (a *API) dashboardEvents(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: CodeInvalidRequest, Message: "Invalid limit"})
+		return
+	}
+	failures, _ := strconv.ParseBool(c.Query("failures"))
+	c.JSON(http.StatusOK, a.dashboard.Events(dashboard.Query{Phone: c.Query("phone"), Failures: failures, Limit: limit}))
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/NlightN22/OTPSMSProvider/dashboard"
+	service "github.com/NlightN22/OTPSMSProvider/service"
+)
+
+func TestDashboard(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rec, err := dashboard.New(10)
+	if err != nil {
+		t.Fatalf("dashboard.New error: %v", err)
+	}
+	rec.Handle(context.Background(), service.Event{Type: service.EventCodeSent, Phone: "+79991234567"})
+	rec.Handle(context.Background(), service.Event{Type: service.EventLockout, Phone: "+15550001234"})
+	a := NewAPI(&stubService{}, WithDashboard(rec), WithAuth(allowAll{}))
+	router := gin.New()
+	a.RegisterRoutes(router)
+
+	w := performRequest(router, "GET", "/dashboard/", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "<script src=\"app.js\">") {
+		t.Errorf("GET /dashboard/ = %d; want the UI page", w.Code)
+	}
+	w = performRequest(router, "GET", "/admin/dashboard/stats", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"code.sent":1`) {
+		t.Errorf("GET stats = %d %s; want one send", w.Code, w.Body.String())
+	}
+	w = performRequest(router, "GET", "/admin/dashboard/events?failures=true", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"lockout"`) || strings.Contains(w.Body.String(), `"code.sent"`) {
+		t.Errorf("GET failures = %s; want only the lockout", w.Body.String())
+	}
+	w = performRequest(router, "GET", "/admin/dashboard/events?phone=%2B79991234567", "")
+	if !strings.Contains(w.Body.String(), `"+799******67"`) || strings.Contains(w.Body.String(), "1234567") {
+		t.Errorf("GET search = %s; want the masked phone only", w.Body.String())
+	}
+}
+
+func TestDashboard_RequiresAuthenticator(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rec, err := dashboard.New(10)
+	if err != nil {
+		t.Fatalf("dashboard.New error: %v", err)
+	}
+	a := NewAPI(&stubService{}, WithDashboard(rec))
+	router := gin.New()
+	a.RegisterRoutes(router)
+
+	for _, path := range []string{"/dashboard/", "/admin/dashboard/stats", "/admin/dashboard/events"} {
+		if w := performRequest(router, "GET", path, ""); w.Code != http.StatusNotFound {
+			t.Errorf("GET %s without authenticator = %d; want 404", path, w.Code)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Stable error codes returned in ErrorResponse.Error.
+const (
+	CodeInvalidRequest      = "invalid_request"
+	CodeUnauthorized        = "unauthorized"       // written by the API key middleware
+	CodeInsufficientScope   = "insufficient_scope" // written by the API key middleware
+	CodeInvalidSignature    = "invalid_signature"  // written by the signature middleware
+	CodeReplayedRequest     = "replayed_request"   // written by the signature middleware
+	CodeIPNotAllowed        = "ip_not_allowed"     // written by the whitelist middleware
+	CodeInvalidPhone        = "invalid_phone"
+	CodeUnsupportedLineType = "unsupported_line_type"
+	CodePhoneBlocked        = "phone_blocked"
+	CodeCountryNotAllowed   = "country_not_allowed"
+	CodePhoneNotAllowed     = "phone_not_allowed"
+	CodeUnsupportedChannel  = "unsupported_channel"
+	CodeUnknownTemplate     = "unknown_template"
+	CodeUnknownPurpose      = "unknown_purpose"
+	CodeRateLimited         = "rate_limited"
+	CodeBudgetExceeded      = "budget_exceeded"
+	CodePhoneUnreachable    = "phone_unreachable"
+	CodeProviderUnavailable = "provider_unavailable"
+	CodeProviderError       = "provider_error"
+	CodeInvalidCode         = "invalid_code"
+	CodeNotFound            = "not_found"
+	CodeInternal            = "internal_error"
+)
+
+// ErrorResponse is the body of every failed request.
+type ErrorResponse struct {
+	Error             string `json:"error" example:"rate_limited"`               // stable machine-readable code
+	Message           string `json:"message" example:"Please wait 12s"`          // human-readable description, may change
+	Reason            string `json:"reason,omitempty" example:"ip"`              // detail for the code, e.g. the exceeded rate limit
+	RetryAfterSeconds int    `json:"retry_after_seconds,omitempty" example:"12"` // set for rate_limited, also sent as Retry-After
+}
+
+// SendResponse is the body of a successful /send.
+type SendResponse struct {
+	Status string `json:"status" example:"sent"`
+}
+
+// VerifyResponse is the body of a successful /verify.
+type VerifyResponse struct {
+	Valid bool `json:"valid" example:"true"`
+	TokenResponse
+}
+
+// TokenResponse carries the signed JWT issued on verification, when enabled.
+type TokenResponse struct {
+	Token          string `json:"token,omitempty" example:"eyJhbGciOiJFZERTQSIsImtpZCI6IjIwMjYtMTAiLCJ0eXAiOiJKV1QifQ..."`
+	TokenType      string `json:"token_type,omitempty" example:"Bearer"`
+	ExpiresIn      int    `json:"expires_in,omitempty" example:"300"` // seconds
+	VerificationID string `json:"verification_id,omitempty" example:"9f86d081884c7d659a2feaa0c55ad015"`
+}
+
+// WithPlainText makes /send and /verify answer with the plain-text bodies of
+// earlier releases instead of JSON, for clients that still parse them.
+func WithPlainText() Option {
+	return func(a *API) {
+		a.plainText = true
+	}
+}
+
+// plain reports whether the reply to c must use the legacy plain-text format.
+// Versioned routes always answer with JSON.
+func (a *API) plain(c *gin.Context) bool {
+	if strings.HasPrefix(c.FullPath(), "/v1/") {
+		return false
+	}
+	return a.plainText || c.NegotiateFormat(gin.MIMEJSON, gin.MIMEPlain) == gin.MIMEPlain
+}
+
+// fail aborts the request with an ErrorResponse, or its Message in plain-text mode.
+func (a *API) fail(c *gin.Context, status int, resp ErrorResponse) {
+	// Tag the innermost span, so a trace shows why a send or verify stopped.
+	span := trace.SpanFromContext(c.Request.Context())
+	span.SetAttributes(attribute.String("otp.error", resp.Error))
+	if status >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, resp.Error)
+	}
+	c.Set(errorCodeKey, resp.Error)
+	if resp.RetryAfterSeconds > 0 {
+		c.Header("Retry-After", strconv.Itoa(resp.RetryAfterSeconds))
+	}
+	if a.plain(c) {
+		c.String(status, resp.Message)
+		return
+	}
+	c.JSON(status, resp)
+}
+
+// reply writes a successful body, or text in plain-text mode.
+func (a *API) reply(c *gin.Context, body any, text string) {
+	if a.plain(c) {
+		c.String(http.StatusOK, text)
+		return
+	}
+	c.JSON(http.StatusOK, body)
+}
+
+// retryAfter rounds a wait up to whole seconds, at least one.
+func retryAfter(wait time.Duration) int {
+	return int(math.Max(1, math.Ceil(wait.Seconds())))
+}
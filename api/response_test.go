@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSendEndpoint_JSONErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	a := NewAPI(&stubService{canSend: false, wait: 11500 * time.Millisecond})
+	router := gin.New()
+	a.RegisterRoutes(router)
+
+	w := performRequest(router, "POST", "/send", `{"phone":"+1234567890"}`)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d; want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if got := w.Header().Get("Retry-After"); got != "12" {
+		t.Errorf("Retry-After = %q; want \"12\"", got)
+	}
+	var resp ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("body %q is not JSON: %v", w.Body.String(), err)
+	}
+	if resp.Error != CodeRateLimited || resp.RetryAfterSeconds != 12 {
+		t.Errorf("response = %+v; want rate_limited with 12s", resp)
+	}
+}
+
+func TestVerifyEndpoint_JSON(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	a := NewAPI(&stubService{valid: true, code: "123456"})
+	router := gin.New()
+	a.RegisterRoutes(router)
+
+	w := performRequest(router, "POST", "/verify", `{"phone":"+1234567890","code":"123456"}`)
+	if w.Body.String() != `{"valid":true}` {
+		t.Errorf("body = %s; want {\"valid\":true}", w.Body.String())
+	}
+	w = performRequest(router, "POST", "/verify", `{"phone":"+1234567890","code":"000000"}`)
+	if !strings.Contains(w.Body.String(), `"error":"invalid_code"`) {
+		t.Errorf("body = %s; want invalid_code", w.Body.String())
+	}
+}
+
+func TestPlainTextCompatibility(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	stub := &stubService{canSend: false, wait: 5 * time.Second}
+
+	a := NewAPI(stub, WithPlainText())
+	router := gin.New()
+	a.RegisterRoutes(router)
+	w := performRequest(router, "POST", "/send", `{"phone":"+1234567890"}`)
+	if w.Body.String() != "Please wait 5s" {
+		t.Errorf("plain mode body = %q; want \"Please wait 5s\"", w.Body.String())
+	}
+
+	a = NewAPI(stub)
+	router = gin.New()
+	a.RegisterRoutes(router)
+	req := httptest.NewRequest("POST", "/send", strings.NewReader(`{"phone":"+1234567890"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/plain")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Body.String() != "Please wait 5s" {
+		t.Errorf("Accept: text/plain body = %q; want \"Please wait 5s\"", w.Body.String())
+	}
+}
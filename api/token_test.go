@@ -0,0 +1,85 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pquerna/otp"
+
+	"github.com/NlightN22/OTPSMSProvider/service"
+	"github.com/NlightN22/OTPSMSProvider/storage"
+	"github.com/NlightN22/OTPSMSProvider/token"
+)
+
+func TestVerify_IssuesToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	key, err := token.GenerateKey("k1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer, err := token.NewIssuer(token.Config{}, key)
+	if err != nil {
+		t.Fatalf("NewIssuer error: %v", err)
+	}
+	router := gin.New()
+	NewAPI(&stubService{valid: true, code: "123456"}, WithTokenIssuer(issuer)).RegisterRoutes(router)
+
+	for _, path := range []string{"/verify", "/v1/verify"} {
+		w := performRequest(router, "POST", path, `{"phone":"+79991234567","code":"123456"}`)
+		var resp VerifyResponseV1
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("%s = %d %s", path, w.Code, w.Body)
+		}
+		if resp.Token == "" || resp.TokenType != "Bearer" || resp.ExpiresIn <= 0 || resp.VerificationID == "" {
+			t.Errorf("%s token fields = %+v", path, resp.TokenResponse)
+		}
+	}
+
+	w := performRequest(router, "POST", "/v1/verify", `{"phone":"+79991234567","code":"000000"}`)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("invalid code status = %d; want %d", w.Code, http.StatusUnauthorized)
+	}
+
+	w = performRequest(router, "GET", "/.well-known/jwks.json", "")
+	var set token.JWKS
+	if err := json.Unmarshal(w.Body.Bytes(), &set); err != nil || len(set.Keys) != 1 || set.Keys[0].Kid != "k1" {
+		t.Errorf("JWKS = %d %s", w.Code, w.Body)
+	}
+}
+
+type nopNotifier struct{}
+
+func (nopNotifier) Send(ctx context.Context, phone, message string) (service.SendResult, error) {
+	return service.SendResult{}, nil
+}
+
+func TestVerify_RejectsReplayedCode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	key, err := token.GenerateKey("k1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer, err := token.NewIssuer(token.Config{}, key)
+	if err != nil {
+		t.Fatalf("NewIssuer error: %v", err)
+	}
+	svc := service.NewTotpService(storage.NewMemoryStorage(), "test", 30, otp.DigitsSix, otp.AlgorithmSHA1, 1, time.Second, nopNotifier{})
+	code, err := svc.GenerateCode(context.Background(), "+79991234567", service.SendOptions{})
+	if err != nil {
+		t.Fatalf("GenerateCode error: %v", err)
+	}
+	router := gin.New()
+	NewAPI(svc, WithTokenIssuer(issuer)).RegisterRoutes(router)
+
+	body := `{"phone":"+79991234567","code":"` + code + `"}`
+	if w := performRequest(router, "POST", "/v1/verify", body); w.Code != http.StatusOK {
+		t.Fatalf("first verify = %d %s; want 200", w.Code, w.Body)
+	}
+	if w := performRequest(router, "POST", "/v1/verify", body); w.Code != http.StatusUnauthorized {
+		t.Errorf("replayed verify = %d %s; want 401", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/NlightN22/OTPSMSProvider/apikey"
+	service "github.com/NlightN22/OTPSMSProvider/service"
+)
+
+// SendRequestV1 represents request for /v1/send endpoint.
+type SendRequestV1 struct {
+	Phone           string            `json:"phone" binding:"required" example:"+79991234567"`                                     // E.164 or national format of the default region
+	Channel         string            `json:"channel" example:"sms"`                                                               // delivery channel, only "sms" for now
+	Locale          string            `json:"locale" example:"ru-RU"`                                                              // message language, falls back to Accept-Language and then the default
+	Purpose         string            `json:"purpose" binding:"omitempty,max=64" example:"login"`                                  // what the code confirms; a code only verifies for the same purpose
+	TemplateID      string            `json:"template_id" binding:"omitempty,max=64" example:"login"`                              // named message template, empty for the default
+	ClientReference string            `json:"client_reference" binding:"omitempty,max=128" example:"order-42"`                     // caller's own identifier, echoed back
+	Metadata        map[string]string `json:"metadata" binding:"omitempty,max=16,dive,keys,max=64,endkeys,max=256"`                // opaque caller data
+	Payload         string            `json:"payload" binding:"omitempty,max=1024" example:"EUR 120.00 to DE89370400440532013000"` // data the code is bound to, must be repeated on verify
+	ClientIP        string            `json:"client_ip" binding:"omitempty,ip" example:"203.0.113.7"`                              // end-user IP for rate limiting, honoured from trusted proxies and keys with the client_ip scope
+}
+
+// SendResponseV1 is the body of a successful /v1/send.
+type SendResponseV1 struct {
+	Status          string `json:"status" example:"sent"`
+	Channel         string `json:"channel" example:"sms"`
+	Purpose         string `json:"purpose,omitempty" example:"login"`
+	ClientReference string `json:"client_reference,omitempty" example:"order-42"`
+}
+
+// VerifyRequestV1 represents request for /v1/verify endpoint.
+type VerifyRequestV1 struct {
+	Phone           string `json:"phone" binding:"required" example:"+79991234567"`
+	Code            string `json:"code" binding:"required" example:"123456"`
+	Purpose         string `json:"purpose" binding:"omitempty,max=64" example:"login"`
+	Payload         string `json:"payload" binding:"omitempty,max=1024" example:"EUR 120.00 to DE89370400440532013000"`
+	ClientReference string `json:"client_reference" binding:"omitempty,max=128" example:"order-42"`
+}
+
+// VerifyResponseV1 is the body of a successful /v1/verify.
+type VerifyResponseV1 struct {
+	Valid           bool   `json:"valid" example:"true"`
+	ClientReference string `json:"client_reference,omitempty" example:"order-42"`
+	TokenResponse
+}
+
+func (a *API) registerV1Routes(g *gin.RouterGroup) {
+	g.POST("/send", a.protect(apikey.ScopeSend, a.sendV1)...)
+	g.POST("/verify", a.protect(apikey.ScopeVerify, a.verifyV1)...)
+}
+
+// sendV1 handles code generation and dispatch.
+// @Summary Generate and send a one-time code
+// @Description Generates a code for the phone and delivers it over the requested channel.
+// @Description Errors carry a stable code in "error"; rate-limited replies set Retry-After.
+// @Tags v1
+// @Accept json
+// @Produce json
+// @Param data body SendRequestV1 true "Send request"
+// @Success 200 {object} SendResponseV1
+// @Failure 400 {object} ErrorResponse "invalid_request, invalid_phone, unsupported_line_type, unsupported_channel, unknown_template, unknown_purpose, phone_unreachable"
+// @Failure 401 {object} ErrorResponse "unauthorized, invalid_signature, replayed_request"
+// @Failure 403 {object} ErrorResponse "ip_not_allowed, insufficient_scope, phone_not_allowed, phone_blocked, country_not_allowed"
+// @Failure 429 {object} ErrorResponse "rate_limited"
+// @Failure 500 {object} ErrorResponse "provider_error, internal_error"
+// @Failure 503 {object} ErrorResponse "provider_unavailable, budget_exceeded"
+// @Header 429 {integer} Retry-After "Seconds until the next attempt may succeed"
+// @Security ApiKeyAuth
+// @Router /v1/send [post]
+func (a *API) sendV1(c *gin.Context) {
+	var req SendRequestV1
+	if err := c.ShouldBindJSON(&req); err != nil {
+		a.fail(c, http.StatusBadRequest, ErrorResponse{Error: CodeInvalidRequest, Message: "Invalid request"})
+		return
+	}
+	if req.Channel == "" {
+		req.Channel = service.ChannelSMS
+	}
+	if !a.sendCode(c, req) {
+		return
+	}
+	c.JSON(http.StatusOK, SendResponseV1{
+		Status:          "sent",
+		Channel:         req.Channel,
+		Purpose:         req.Purpose,
+		ClientReference: req.ClientReference,
+	})
+}
+
+// verifyV1 handles code validation.
+// @Summary Validate a one-time code
+// @Description Checks the code sent to the phone. Purpose and payload must match the send; a code verifies only once.
+// @Tags v1
+// @Accept json
+// @Produce json
+// @Param data body VerifyRequestV1 true "Verify request"
+// @Success 200 {object} VerifyResponseV1
+// @Failure 400 {object} ErrorResponse "invalid_request, invalid_phone"
+// @Failure 401 {object} ErrorResponse "unauthorized, invalid_signature, replayed_request, invalid_code"
+// @Failure 403 {object} ErrorResponse "ip_not_allowed, insufficient_scope"
+// @Security ApiKeyAuth
+// @Router /v1/verify [post]
+func (a *API) verifyV1(c *gin.Context) {
+	var req VerifyRequestV1
+	if err := c.ShouldBindJSON(&req); err != nil {
+		a.fail(c, http.StatusBadRequest, ErrorResponse{Error: CodeInvalidRequest, Message: "Bad request"})
+		return
+	}
+	issued, ok := a.verifyCode(c, req)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, VerifyResponseV1{Valid: true, ClientReference: req.ClientReference, TokenResponse: issued})
+}
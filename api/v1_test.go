@@ -0,0 +1,99 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/NlightN22/OTPSMSProvider/message"
+	service "github.com/NlightN22/OTPSMSProvider/service"
+	"github.com/gin-gonic/gin"
+)
+
+func TestV1Send(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	stub := &stubService{canSend: true, code: "123456"}
+	router := gin.New()
+	NewAPI(stub, WithPlainText()).RegisterRoutes(router)
+
+	w := performRequest(router, "POST", "/v1/send", `{"phone":"+79991234567","purpose":"login","template_id":"login",
+		"client_reference":"order-42","metadata":{"user":"7"}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d; want %d (%s)", w.Code, http.StatusOK, w.Body)
+	}
+	var resp SendResponseV1
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("plain-text mode must not apply to /v1: %v", err)
+	}
+	if resp.Channel != "sms" || resp.ClientReference != "order-42" || resp.Purpose != "login" {
+		t.Errorf("response = %+v", resp)
+	}
+	if stub.opts.Purpose != "login" || stub.opts.TemplateID != "login" || stub.opts.Metadata["user"] != "7" {
+		t.Errorf("SendOptions = %+v", stub.opts)
+	}
+}
+
+func TestV1Send_Errors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cases := []struct {
+		body   string
+		genErr error
+		status int
+		code   string
+	}{
+		{`{"phone":"+79991234567","channel":"voice"}`, nil, http.StatusBadRequest, CodeUnsupportedChannel},
+		{`{"phone":"+79991234567","template_id":"nope"}`, fmt.Errorf("render message: %w", message.ErrUnknownTemplate), http.StatusBadRequest, CodeUnknownTemplate},
+		{`{"phone":"+79991234567","metadata":{"k":1}}`, nil, http.StatusBadRequest, CodeInvalidRequest},
+	}
+	for _, tc := range cases {
+		router := gin.New()
+		NewAPI(&stubService{canSend: true, genErr: tc.genErr}).RegisterRoutes(router)
+		w := performRequest(router, "POST", "/v1/send", tc.body)
+		var resp ErrorResponse
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		if w.Code != tc.status || resp.Error != tc.code {
+			t.Errorf("%s: got %d %q; want %d %q", tc.body, w.Code, resp.Error, tc.status, tc.code)
+		}
+	}
+}
+
+func TestV1Verify(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	NewAPI(&stubService{valid: true, code: "123456"}).RegisterRoutes(router)
+
+	w := performRequest(router, "POST", "/v1/verify", `{"phone":"+79991234567","code":"123456","client_reference":"order-42"}`)
+	var resp VerifyResponseV1
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK || !resp.Valid || resp.ClientReference != "order-42" {
+		t.Errorf("verify = %d %s; want 200 valid", w.Code, w.Body)
+	}
+	w = performRequest(router, "POST", "/v1/verify", `{"phone":"+79991234567","code":"000000"}`)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d; want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestV1_Purpose(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	stub := &stubService{canSend: true, valid: true, code: "123456"}
+	router := gin.New()
+	NewAPI(stub).RegisterRoutes(router)
+
+	performRequest(router, "POST", "/v1/send", `{"phone":"+79991234567","purpose":"payment","payload":"EUR 1"}`)
+	if stub.opts.Purpose != "payment" || stub.opts.Payload != "EUR 1" {
+		t.Errorf("SendOptions = %+v", stub.opts)
+	}
+	performRequest(router, "POST", "/v1/verify", `{"phone":"+79991234567","code":"123456","purpose":"payment","payload":"EUR 1"}`)
+	if stub.verifyOpts != (service.VerifyOptions{Purpose: "payment", Payload: "EUR 1"}) {
+		t.Errorf("VerifyOptions = %+v", stub.verifyOpts)
+	}
+
+	router = gin.New()
+	NewAPI(&stubService{canSend: true, genErr: service.ErrUnknownPurpose}).RegisterRoutes(router)
+	w := performRequest(router, "POST", "/v1/send", `{"phone":"+79991234567","purpose":"nope"}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), CodeUnknownPurpose) {
+		t.Errorf("unknown purpose = %d %s; want 400 %s", w.Code, w.Body, CodeUnknownPurpose)
+	}
+}
@@ -0,0 +1,237 @@
+// Package apikey authenticates API clients by hashed keys with scopes and limits.
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	logger "github.com/NlightN22/OTPSMSProvider/pkg"
+	storage "github.com/NlightN22/OTPSMSProvider/storage"
+	"go.uber.org/zap"
+)
+
+// Scopes a key may be granted.
+const (
+	ScopeSend   = "send"
+	ScopeVerify = "verify"
+	ScopeAdmin  = "admin"
+	// ScopeClientIP lets a backend pass its end user's IP in client_ip.
+	ScopeClientIP = "client_ip"
+)
+
+// Source of a key.
+const (
+	SourceConfig  = "config"
+	SourceStorage = "storage"
+)
+
+// tokenPrefix starts every token so leaked keys are easy to find in code and logs.
+const tokenPrefix = "otp_"
+
+// revokedList holds IDs of config keys revoked at runtime.
+const revokedList = "apikey:revoked"
+
+// Errors.
+var (
+	ErrInvalid      = errors.New("invalid API key")
+	ErrRevoked      = errors.New("API key revoked")
+	ErrInvalidScope = errors.New("invalid API key scope")
+	ErrDuplicateID  = errors.New("duplicate API key ID")
+)
+
+// Key describes a client key. The token itself is never stored, only its SHA-256 hash.
+type Key struct {
+	ID            string    `json:"id" example:"3f9a1c0b7d2e4a56"`
+	Name          string    `json:"name" example:"shop-backend"`
+	Hash          string    `json:"-"`
+	Scopes        []string  `json:"scopes" example:"send,verify"`
+	PerMinute     float64   `json:"per_minute,omitempty" example:"60"` // 0 means no per-key limit
+	Burst         int       `json:"burst,omitempty" example:"10"`
+	PhonePrefixes []string  `json:"phone_prefixes,omitempty" example:"+7"` // empty allows every number
+	Source        string    `json:"source" example:"storage"`
+	Revoked       bool      `json:"revoked,omitempty"`
+	CreatedAt     time.Time `json:"created_at,omitempty"`
+}
+
+// HasScope reports whether the key was granted scope.
+func (k Key) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsPhone reports whether the key may send to the E.164 number.
+func (k Key) AllowsPhone(e164 string) bool {
+	if len(k.PhonePrefixes) == 0 {
+		return true
+	}
+	for _, p := range k.PhonePrefixes {
+		if strings.HasPrefix(e164, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Hash returns the hex SHA-256 of a token, the form keys are configured in.
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Generate creates a new random token and its public ID.
+func Generate() (id, token string, err error) {
+	buf := make([]byte, 40)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("generate key: %w", err)
+	}
+	id = hex.EncodeToString(buf[:8])
+	return id, tokenPrefix + id + "_" + hex.EncodeToString(buf[8:]), nil
+}
+
+// parseID extracts the public ID from a token.
+func parseID(token string) (string, bool) {
+	rest, ok := strings.CutPrefix(token, tokenPrefix)
+	if !ok {
+		return "", false
+	}
+	id, _, ok := strings.Cut(rest, "_")
+	return id, ok && id != ""
+}
+
+// ValidateScopes checks that scopes is non-empty and holds only known scopes.
+func ValidateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return fmt.Errorf("%w: none given", ErrInvalidScope)
+	}
+	for _, s := range scopes {
+		switch s {
+		case ScopeSend, ScopeVerify, ScopeAdmin, ScopeClientIP:
+		default:
+			return fmt.Errorf("%w: %q", ErrInvalidScope, s)
+		}
+	}
+	return nil
+}
+
+// Manager authenticates tokens against config keys and keys minted into storage.
+type Manager struct {
+	store  storage.Storage
+	static map[string]Key
+	log    *zap.SugaredLogger
+}
+
+// NewManager creates a Manager with the given config keys.
+func NewManager(store storage.Storage, static []Key) (*Manager, error) {
+	m := &Manager{
+		store:  store,
+		static: make(map[string]Key, len(static)),
+		log:    logger.New("APIKey"),
+	}
+	for _, k := range static {
+		if k.ID == "" || len(k.Hash) != sha256.Size*2 {
+			return nil, fmt.Errorf("%w: key %q needs an id and a hex SHA-256 hash", ErrInvalid, k.Name)
+		}
+		if _, dup := m.static[k.ID]; dup {
+			return nil, fmt.Errorf("%w: %q", ErrDuplicateID, k.ID)
+		}
+		if err := ValidateScopes(k.Scopes); err != nil {
+			return nil, fmt.Errorf("key %q: %w", k.ID, err)
+		}
+		k.Hash = strings.ToLower(k.Hash)
+		k.Source = SourceConfig
+		m.static[k.ID] = k
+	}
+	return m, nil
+}
+
+// Authenticate returns the key a token belongs to.
+func (m *Manager) Authenticate(token string) (Key, error) {
+	id, ok := parseID(token)
+	if !ok {
+		return Key{}, ErrInvalid
+	}
+	k, ok := m.lookup(id)
+	if !ok || subtle.ConstantTimeCompare([]byte(k.Hash), []byte(Hash(token))) != 1 {
+		return Key{}, ErrInvalid
+	}
+	if k.Revoked {
+		return Key{}, ErrRevoked
+	}
+	return k, nil
+}
+
+func (m *Manager) lookup(id string) (Key, bool) {
+	if k, ok := m.static[id]; ok {
+		k.Revoked = m.revoked(id)
+		return k, true
+	}
+	rec, ok := m.store.GetAPIKey(id)
+	if !ok {
+		return Key{}, false
+	}
+	return fromRecord(rec), true
+}
+
+func (m *Manager) revoked(id string) bool {
+	for _, r := range m.store.GetListEntries(revokedList) {
+		if r == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Mint creates a key in storage and returns its token, which is shown only once.
+func (m *Manager) Mint(k Key) (string, Key, error) {
+	if err := ValidateScopes(k.Scopes); err != nil {
+		return "", Key{}, err
+	}
+	id, token, err := Generate()
+	if err != nil {
+		return "", Key{}, err
+	}
+	k.ID, k.Hash, k.Source, k.Revoked = id, Hash(token), SourceStorage, false
+	k.CreatedAt = time.Now().UTC()
+	m.store.SaveAPIKey(toRecord(k))
+	m.log.Infow("API key minted", "id", k.ID, "name", k.Name, "scopes", k.Scopes)
+	return token, k, nil
+}
+
+// Revoke disables a key. Minted keys are deleted, config keys are marked revoked
+// until the next restart without them in the config.
+func (m *Manager) Revoke(id string) bool {
+	if m.store.DeleteAPIKey(id) {
+		m.log.Infow("API key revoked", "id", id)
+		return true
+	}
+	if _, ok := m.static[id]; ok {
+		m.store.AddListEntry(revokedList, id)
+		m.log.Infow("API key revoked", "id", id, "source", SourceConfig)
+		return true
+	}
+	return false
+}
+
+// List returns config keys followed by minted ones.
+func (m *Manager) List() []Key {
+	out := make([]Key, 0, len(m.static))
+	for id := range m.static {
+		k, _ := m.lookup(id)
+		out = append(out, k)
+	}
+	sortKeys(out)
+	for _, rec := range m.store.ListAPIKeys() {
+		out = append(out, fromRecord(rec))
+	}
+	return out
+}
@@ -0,0 +1,78 @@
+package apikey
+
+import (
+	"errors"
+	"testing"
+
+	storage "github.com/NlightN22/OTPSMSProvider/storage"
+)
+
+func TestManager_ConfigKey(t *testing.T) {
+	id, token, err := Generate()
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+	m, err := NewManager(storage.NewMemoryStorage(), []Key{{ID: id, Name: "shop", Hash: Hash(token), Scopes: []string{ScopeSend}}})
+	if err != nil {
+		t.Fatalf("NewManager error: %v", err)
+	}
+	k, err := m.Authenticate(token)
+	if err != nil || k.Name != "shop" || !k.HasScope(ScopeSend) || k.HasScope(ScopeAdmin) {
+		t.Fatalf("Authenticate = %+v, %v; want shop with send scope", k, err)
+	}
+	if _, err := m.Authenticate(token + "0"); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Authenticate(wrong) error = %v; want ErrInvalid", err)
+	}
+	if _, err := m.Authenticate("garbage"); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Authenticate(garbage) error = %v; want ErrInvalid", err)
+	}
+	if !m.Revoke(id) {
+		t.Fatalf("Revoke = false; want true")
+	}
+	if _, err := m.Authenticate(token); !errors.Is(err, ErrRevoked) {
+		t.Errorf("Authenticate after Revoke error = %v; want ErrRevoked", err)
+	}
+}
+
+func TestManager_MintAndRevoke(t *testing.T) {
+	m, err := NewManager(storage.NewMemoryStorage(), nil)
+	if err != nil {
+		t.Fatalf("NewManager error: %v", err)
+	}
+	if _, _, err := m.Mint(Key{Name: "bad", Scopes: []string{"root"}}); !errors.Is(err, ErrInvalidScope) {
+		t.Errorf("Mint(bad scope) error = %v; want ErrInvalidScope", err)
+	}
+	token, k, err := m.Mint(Key{Name: "crm", Scopes: []string{ScopeVerify}, PhonePrefixes: []string{"+7"}})
+	if err != nil {
+		t.Fatalf("Mint error: %v", err)
+	}
+	got, err := m.Authenticate(token)
+	if err != nil || got.ID != k.ID || got.Source != SourceStorage {
+		t.Fatalf("Authenticate = %+v, %v; want minted key", got, err)
+	}
+	if !got.AllowsPhone("+79991234567") || got.AllowsPhone("+15551234567") {
+		t.Errorf("AllowsPhone does not follow prefixes %v", got.PhonePrefixes)
+	}
+	if len(m.List()) != 1 {
+		t.Errorf("List = %+v; want one key", m.List())
+	}
+	if !m.Revoke(k.ID) || m.Revoke(k.ID) {
+		t.Errorf("Revoke did not report presence correctly")
+	}
+	if _, err := m.Authenticate(token); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Authenticate after Revoke error = %v; want ErrInvalid", err)
+	}
+}
+
+func TestNewManager_Invalid(t *testing.T) {
+	cases := [][]Key{
+		{{ID: "a", Hash: "short", Scopes: []string{ScopeSend}}},
+		{{ID: "a", Hash: Hash("x"), Scopes: nil}},
+		{{ID: "a", Hash: Hash("x"), Scopes: []string{ScopeSend}}, {ID: "a", Hash: Hash("y"), Scopes: []string{ScopeSend}}},
+	}
+	for i, keys := range cases {
+		if _, err := NewManager(storage.NewMemoryStorage(), keys); err == nil {
+			t.Errorf("case %d: NewManager error = nil; want error", i)
+		}
+	}
+}
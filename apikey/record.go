@@ -0,0 +1,38 @@
+package apikey
+
+import (
+	"sort"
+
+	storage "github.com/NlightN22/OTPSMSProvider/storage"
+)
+
+func toRecord(k Key) storage.APIKey {
+	return storage.APIKey{
+		ID:            k.ID,
+		Name:          k.Name,
+		Hash:          k.Hash,
+		Scopes:        k.Scopes,
+		PerMinute:     k.PerMinute,
+		Burst:         k.Burst,
+		PhonePrefixes: k.PhonePrefixes,
+		CreatedAt:     k.CreatedAt,
+	}
+}
+
+func fromRecord(r storage.APIKey) Key {
+	return Key{
+		ID:            r.ID,
+		Name:          r.Name,
+		Hash:          r.Hash,
+		Scopes:        r.Scopes,
+		PerMinute:     r.PerMinute,
+		Burst:         r.Burst,
+		PhonePrefixes: r.PhonePrefixes,
+		Source:        SourceStorage,
+		CreatedAt:     r.CreatedAt,
+	}
+}
+
+func sortKeys(keys []Key) {
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+}
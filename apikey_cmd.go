@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/NlightN22/OTPSMSProvider/apikey"
+)
+
+const apiKeyUsage = `Usage:
+  apikey mint -name NAME -scopes send,verify [-per-minute N -burst N -prefixes +7,+375] [-server URL -admin-key KEY]
+  apikey revoke -server URL -admin-key KEY ID
+
+Without -server, mint prints a token and the config entry holding its hash.
+With -server, keys are minted and revoked through the /admin/keys endpoints.
+`
+
+// runAPIKeyCommand implements the "apikey" subcommand and returns the exit code.
+func runAPIKeyCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, apiKeyUsage)
+		return 2
+	}
+	var err error
+	switch args[0] {
+	case "mint":
+		err = mintAPIKey(args[1:])
+	case "revoke":
+		err = revokeAPIKey(args[1:])
+	default:
+		fmt.Fprint(os.Stderr, apiKeyUsage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "apikey:", err)
+		return 1
+	}
+	return 0
+}
+
+func mintAPIKey(args []string) error {
+	fs := flag.NewFlagSet("apikey mint", flag.ContinueOnError)
+	name := fs.String("name", "", "client name")
+	scopes := fs.String("scopes", "", "comma-separated scopes: send, verify, admin, client_ip")
+	perMinute := fs.Float64("per-minute", 0, "per-key rate limit, 0 disables it")
+	burst := fs.Int("burst", 0, "per-key burst")
+	prefixes := fs.String("prefixes", "", "comma-separated allowed E.164 prefixes")
+	server := fs.String("server", "", "service URL to mint the key in its storage")
+	adminKey := fs.String("admin-key", "", "API key with the admin scope, required with -server")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *name == "" || *scopes == "" {
+		return fmt.Errorf("-name and -scopes are required")
+	}
+	key := apikey.Key{
+		Name:          *name,
+		Scopes:        splitList(*scopes),
+		PerMinute:     *perMinute,
+		Burst:         *burst,
+		PhonePrefixes: splitList(*prefixes),
+	}
+	if err := apikey.ValidateScopes(key.Scopes); err != nil {
+		return err
+	}
+
+	if *server != "" {
+		body, _ := json.Marshal(map[string]any{
+			"name":           key.Name,
+			"scopes":         key.Scopes,
+			"per_minute":     key.PerMinute,
+			"burst":          key.Burst,
+			"phone_prefixes": key.PhonePrefixes,
+		})
+		resp, err := adminRequest(http.MethodPost, *server+"/admin/keys", *adminKey, body)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(resp))
+		return nil
+	}
+
+	id, token, err := apikey.Generate()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("token: %s\n\n", token)
+	fmt.Println("Add to the config (api_keys.keys):")
+	fmt.Printf("  - id: %s\n    name: %s\n    hash: %s\n    scopes: [%s]\n", id, key.Name, apikey.Hash(token), strings.Join(key.Scopes, ", "))
+	if key.PerMinute > 0 {
+		fmt.Printf("    per_minute: %g\n    burst: %d\n", key.PerMinute, key.Burst)
+	}
+	if len(key.PhonePrefixes) > 0 {
+		fmt.Printf("    phone_prefixes: [\"%s\"]\n", strings.Join(key.PhonePrefixes, `", "`))
+	}
+	return nil
+}
+
+func revokeAPIKey(args []string) error {
+	fs := flag.NewFlagSet("apikey revoke", flag.ContinueOnError)
+	server := fs.String("server", "", "service URL")
+	adminKey := fs.String("admin-key", "", "API key with the admin scope")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *server == "" || fs.NArg() != 1 {
+		return fmt.Errorf("-server and a key ID are required")
+	}
+	_, err := adminRequest(http.MethodDelete, *server+"/admin/keys/"+fs.Arg(0), *adminKey, nil)
+	if err == nil {
+		fmt.Println("revoked", fs.Arg(0))
+	}
+	return err
+}
+
+func adminRequest(method, url, token string, body []byte) ([]byte, error) {
+	if token == "" {
+		return nil, fmt.Errorf("-admin-key is required")
+	}
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
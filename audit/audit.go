@@ -0,0 +1,241 @@
+// Package audit keeps a tamper-evident record of sends, verification attempts
+// and admin actions. Every event carries a keyed hash of itself and the hash of
+// the previous one, so an edited, removed or reordered event breaks the chain;
+// see Verifier.
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	logger "github.com/NlightN22/OTPSMSProvider/pkg"
+	service "github.com/NlightN22/OTPSMSProvider/service"
+	"go.uber.org/zap"
+)
+
+// Actions.
+const (
+	ActionSend     = "send"
+	ActionVerify   = "verify"
+	ActionDelivery = "delivery"
+	ActionLockout  = "lockout"
+	// Admin actions are "admin." followed by the operation, e.g. "admin.key.mint".
+	ActionAdminPrefix = "admin."
+)
+
+// Outcomes recorded by the service flow; API rejections use the API error code.
+const (
+	OutcomeSent        = "sent"
+	OutcomeSendFailed  = "send_failed"
+	OutcomeValid       = "valid"
+	OutcomeInvalid     = "invalid"
+	OutcomeLocked      = "locked"
+	OutcomeDelivered   = "delivered"
+	OutcomeUndelivered = "undelivered"
+	OutcomeOK          = "ok"
+)
+
+// Event is one audit record. Phone is always masked.
+type Event struct {
+	Seq       uint64    `json:"seq"`
+	Time      time.Time `json:"time"`
+	Action    string    `json:"action"`
+	Actor     string    `json:"actor,omitempty"` // API key or client certificate ID
+	IP        string    `json:"ip,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+	Phone     string    `json:"phone,omitempty"`
+	Purpose   string    `json:"purpose,omitempty"`
+	Outcome   string    `json:"outcome"`
+	Detail    string    `json:"detail,omitempty"` // failure reason
+	MessageID string    `json:"message_id,omitempty"`
+	Target    string    `json:"target,omitempty"` // subject of an admin action, e.g. a key ID
+	PrevHash  string    `json:"prev_hash"`
+	Hash      string    `json:"hash"`
+}
+
+// Sink persists chained events in order.
+type Sink interface {
+	Write(e Event) error
+	Close() error
+}
+
+// Resumer is a sink that can return its newest event, so the chain continues after a restart.
+type Resumer interface {
+	Last() (Event, bool, error)
+}
+
+// Origin identifies who made a request.
+type Origin struct {
+	Actor string
+	IP    string
+}
+
+type originKey struct{}
+
+// WithOrigin returns a copy of ctx carrying o.
+func WithOrigin(ctx context.Context, o Origin) context.Context {
+	return context.WithValue(ctx, originKey{}, o)
+}
+
+// OriginFrom returns the origin stored in ctx.
+func OriginFrom(ctx context.Context) Origin {
+	o, _ := ctx.Value(originKey{}).(Origin)
+	return o
+}
+
+// Deferred holds the service's send or verification event of a request whose
+// final outcome is recorded by the API, so the service outcome does not stand
+// in for a reply that failed later.
+type Deferred struct {
+	mu    sync.Mutex
+	event Event
+	ok    bool
+}
+
+type deferredKey struct{}
+
+// WithDeferred returns a copy of ctx in which ServiceEvent hands send and
+// verification events to d instead of recording them.
+func WithDeferred(ctx context.Context, d *Deferred) context.Context {
+	return context.WithValue(ctx, deferredKey{}, d)
+}
+
+// Event returns the event handed to d, if any.
+func (d *Deferred) Event() (Event, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.event, d.ok
+}
+
+// Auditor chains events and writes them to every sink.
+type Auditor struct {
+	key   []byte
+	sinks []Sink
+	log   *zap.SugaredLogger
+
+	mu   sync.Mutex
+	seq  uint64
+	prev string
+}
+
+// New resumes the chain from the first sink implementing Resumer. key signs
+// the chain; the same key verifies it.
+func New(key []byte, sinks ...Sink) (*Auditor, error) {
+	if len(key) == 0 {
+		return nil, errors.New("audit: no key")
+	}
+	if len(sinks) == 0 {
+		return nil, errors.New("audit: no sinks")
+	}
+	a := &Auditor{key: key, sinks: sinks, log: logger.New("Audit")}
+	for _, s := range sinks {
+		r, ok := s.(Resumer)
+		if !ok {
+			continue
+		}
+		last, ok, err := r.Last()
+		if err != nil {
+			return nil, fmt.Errorf("audit: resume chain: %w", err)
+		}
+		if ok {
+			a.seq, a.prev = last.Seq, last.Hash
+		}
+		break
+	}
+	return a, nil
+}
+
+// Record completes e with the request origin, masks the phone, chains it and
+// writes it to the sinks. It returns the first sink error; the event is still
+// offered to the remaining sinks.
+func (a *Auditor) Record(ctx context.Context, e Event) error {
+	o := OriginFrom(ctx)
+	if e.Actor == "" {
+		e.Actor = o.Actor
+	}
+	if e.IP == "" {
+		e.IP = o.IP
+	}
+	if e.RequestID == "" {
+		e.RequestID = logger.RequestID(ctx)
+	}
+	if e.Phone != "" {
+		e.Phone = logger.MaskPhone(e.Phone)
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Time = e.Time.UTC()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	e.Seq = a.seq + 1
+	e.PrevHash = a.prev
+	e.Hash = Hash(a.key, e)
+
+	var first error
+	for _, s := range a.sinks {
+		if err := s.Write(e); err != nil {
+			logger.For(ctx, a.log).Errorw("Audit sink write failed", "seq", e.Seq, "err", err)
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	a.seq, a.prev = e.Seq, e.Hash
+	return first
+}
+
+// ServiceEvent records a service event; pass it to service.WithEventHandler.
+func (a *Auditor) ServiceEvent(ctx context.Context, se service.Event) {
+	e := Event{Time: se.Time, Phone: se.Phone, Purpose: se.Purpose}
+	switch se.Type {
+	case service.EventCodeSent:
+		e.Action, e.Outcome, e.MessageID = ActionSend, OutcomeSent, se.MessageID
+	case service.EventSendFailed:
+		e.Action, e.Outcome = ActionSend, OutcomeSendFailed
+		if se.Err != nil {
+			e.Detail = se.Err.Error()
+		}
+	case service.EventCodeDelivered:
+		e.Action, e.Outcome, e.MessageID = ActionDelivery, OutcomeDelivered, se.MessageID
+	case service.EventDeliveryFailed:
+		e.Action, e.Outcome, e.MessageID = ActionDelivery, OutcomeUndelivered, se.MessageID
+		if se.Err != nil {
+			e.Detail = se.Err.Error()
+		}
+	case service.EventVerificationSucceeded:
+		e.Action, e.Outcome = ActionVerify, OutcomeValid
+	case service.EventVerificationFailed:
+		e.Action, e.Outcome = ActionVerify, OutcomeInvalid
+		if errors.Is(se.Err, service.ErrLocked) {
+			e.Outcome = OutcomeLocked
+		}
+	case service.EventLockout:
+		e.Action, e.Outcome = ActionLockout, OutcomeLocked
+		e.Detail = "until " + se.LockedUntil.UTC().Format(time.RFC3339)
+	default:
+		return
+	}
+	if d, ok := ctx.Value(deferredKey{}).(*Deferred); ok && (e.Action == ActionSend || e.Action == ActionVerify) {
+		d.mu.Lock()
+		d.event, d.ok = e, true
+		d.mu.Unlock()
+		return
+	}
+	a.Record(ctx, e)
+}
+
+// Close closes every sink.
+func (a *Auditor) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var errs []error
+	for _, s := range a.sinks {
+		errs = append(errs, s.Close())
+	}
+	return errors.Join(errs...)
+}
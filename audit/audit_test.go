@@ -0,0 +1,205 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	logger "github.com/NlightN22/OTPSMSProvider/pkg"
+	service "github.com/NlightN22/OTPSMSProvider/service"
+	storage "github.com/NlightN22/OTPSMSProvider/storage"
+)
+
+var testKey = []byte("audit-test-key")
+
+func TestAuditor_ChainsAndMasks(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	sink := NewStorageSink(store)
+	a, err := New(testKey, sink)
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+
+	ctx := WithOrigin(logger.WithRequestID(context.Background(), "req-1"), Origin{Actor: "k1", IP: "10.0.0.1"})
+	a.ServiceEvent(ctx, service.Event{Type: service.EventCodeSent, Phone: "+79991234567", Purpose: "login", MessageID: "m1"})
+	a.ServiceEvent(ctx, service.Event{Type: service.EventVerificationFailed, Phone: "+79991234567", Purpose: "login"})
+
+	events, err := sink.List(0, 10)
+	if err != nil {
+		t.Fatalf("List error: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("events = %d; want 2", len(events))
+	}
+	e := events[0]
+	if e.Seq != 1 || e.PrevHash != "" || e.Action != ActionSend || e.Outcome != OutcomeSent || e.MessageID != "m1" {
+		t.Errorf("first event = %+v", e)
+	}
+	if e.Actor != "k1" || e.IP != "10.0.0.1" || e.RequestID != "req-1" {
+		t.Errorf("origin = %q %q %q; want k1 10.0.0.1 req-1", e.Actor, e.IP, e.RequestID)
+	}
+	if strings.Contains(e.Phone, "1234567") {
+		t.Errorf("phone %q is not masked", e.Phone)
+	}
+	if events[1].PrevHash != e.Hash || events[1].Outcome != OutcomeInvalid {
+		t.Errorf("second event = %+v; want link to %s", events[1], e.Hash)
+	}
+
+	v := Verifier{Key: testKey}
+	for _, e := range events {
+		if err := v.Check(e); err != nil {
+			t.Fatalf("Check error: %v", err)
+		}
+	}
+
+	// A new auditor continues the chain.
+	a2, err := New(testKey, sink)
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	a2.Record(context.Background(), Event{Action: ActionAdminPrefix + "key.revoke", Outcome: OutcomeOK, Target: "k2"})
+	events, _ = sink.List(2, 10)
+	if len(events) != 1 || events[0].Seq != 3 || v.Check(events[0]) != nil {
+		t.Errorf("resumed event = %+v; want seq 3 linked to the chain", events)
+	}
+}
+
+func TestAuditor_Deferred(t *testing.T) {
+	sink := NewStorageSink(storage.NewMemoryStorage())
+	a, err := New(testKey, sink)
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	d := &Deferred{}
+	ctx := WithDeferred(context.Background(), d)
+	a.ServiceEvent(ctx, service.Event{Type: service.EventVerificationSucceeded, Phone: "+79991234567"})
+	a.ServiceEvent(ctx, service.Event{Type: service.EventLockout, Phone: "+79991234567"})
+
+	if e, ok := d.Event(); !ok || e.Action != ActionVerify || e.Outcome != OutcomeValid {
+		t.Errorf("deferred event = %+v, %v; want the verification", e, ok)
+	}
+	events, _ := sink.List(0, 10)
+	if len(events) != 1 || events[0].Action != ActionLockout {
+		t.Errorf("recorded = %+v; want only the lockout", events)
+	}
+}
+
+// chain links events with key, recomputing every hash.
+func chain(key []byte, events []Event) []Event {
+	out := make([]Event, len(events))
+	prev := ""
+	for i, e := range events {
+		e.PrevHash = prev
+		e.Hash = Hash(key, e)
+		prev = e.Hash
+		out[i] = e
+	}
+	return out
+}
+
+func TestVerifier_DetectsTampering(t *testing.T) {
+	var plain []Event
+	for i := uint64(1); i <= 3; i++ {
+		plain = append(plain, Event{Seq: i, Action: ActionVerify, Outcome: OutcomeValid})
+	}
+	events := chain(testKey, plain)
+
+	edited := append([]Event(nil), events...)
+	edited[1].Outcome = OutcomeInvalid
+	removed := []Event{events[0], events[2]}
+	// Without the key a forger can only rebuild the whole chain with another one.
+	forged := append([]Event(nil), plain...)
+	forged[1].Outcome = OutcomeInvalid
+	rehashed := chain([]byte("guessed-key"), forged)
+	trimmed := events[1:]
+
+	for name, chain := range map[string][]Event{"edited": edited, "removed": removed, "rehashed": rehashed, "trimmed": trimmed} {
+		v := Verifier{Key: testKey}
+		var err error
+		for _, e := range chain {
+			if err = v.Check(e); err != nil {
+				break
+			}
+		}
+		if !errors.Is(err, ErrBrokenChain) {
+			t.Errorf("%s: error = %v; want ErrBrokenChain", name, err)
+		}
+	}
+}
+
+func TestVerifier_From(t *testing.T) {
+	var plain []Event
+	for i := uint64(1); i <= 3; i++ {
+		plain = append(plain, Event{Seq: i, Action: ActionSend, Outcome: OutcomeSent})
+	}
+	events := chain(testKey, plain)
+	v := Verifier{Key: testKey, From: 2}
+	for _, e := range events[1:] {
+		if err := v.Check(e); err != nil {
+			t.Fatalf("Check from 2 error: %v", err)
+		}
+	}
+	v = Verifier{Key: testKey, From: 2}
+	if err := v.Check(events[2]); !errors.Is(err, ErrBrokenChain) {
+		t.Errorf("trail starting at 3 with From 2 = %v; want ErrBrokenChain", err)
+	}
+}
+
+func TestFileSink_RotatesAndVerifies(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink, err := NewFileSink(FileConfig{Path: path, MaxSizeMB: 1, MaxBackups: 1})
+	if err != nil {
+		t.Fatalf("NewFileSink error: %v", err)
+	}
+	a, err := New(testKey, sink)
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	detail := strings.Repeat("x", 200<<10)
+	for i := 0; i < 12; i++ {
+		if err := a.Record(context.Background(), Event{Action: ActionSend, Outcome: OutcomeSendFailed, Detail: detail}); err != nil {
+			t.Fatalf("Record error: %v", err)
+		}
+	}
+	a.Close()
+
+	backups, err := Backups(path)
+	if err != nil {
+		t.Fatalf("Backups error: %v", err)
+	}
+	if len(backups) != 1 {
+		t.Fatalf("backups = %v; want 1 kept", backups)
+	}
+	v := Verifier{Key: testKey}
+	if err := v.VerifyFiles(append(backups, path)...); !errors.Is(err, ErrBrokenChain) {
+		t.Errorf("VerifyFiles without From = %v; want ErrBrokenChain, the oldest backup is gone", err)
+	}
+	v = Verifier{Key: testKey, From: 6}
+	if err := v.VerifyFiles(append(backups, path)...); err != nil {
+		t.Fatalf("VerifyFiles error: %v", err)
+	}
+	if v.Count() != 7 {
+		t.Errorf("verified %d events; want 7 after dropping the oldest backup", v.Count())
+	}
+
+	// Reopening resumes from the last line.
+	sink, _ = NewFileSink(FileConfig{Path: path})
+	a, _ = New(testKey, sink)
+	a.Record(context.Background(), Event{Action: ActionVerify, Outcome: OutcomeValid})
+	a.Close()
+	v = Verifier{Key: testKey, From: 6}
+	if err := v.VerifyFiles(append(backups, path)...); err != nil {
+		t.Fatalf("VerifyFiles after reopen error: %v", err)
+	}
+
+	// Flipping a byte in the file breaks verification.
+	b, _ := os.ReadFile(path)
+	os.WriteFile(path, []byte(strings.Replace(string(b), `"valid"`, `"invalid"`, 1)), 0o640)
+	v = Verifier{Key: testKey, From: 6}
+	if err := v.VerifyFiles(append(backups, path)...); !errors.Is(err, ErrBrokenChain) {
+		t.Errorf("VerifyFiles on tampered file = %v; want ErrBrokenChain", err)
+	}
+}
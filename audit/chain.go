@@ -0,0 +1,106 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ErrBrokenChain reports an event whose hash or link does not match.
+var ErrBrokenChain = errors.New("audit chain broken")
+
+// Hash returns the hex HMAC-SHA256 under key of e's JSON form with Hash
+// cleared. PrevHash is part of the input, which links the event to its
+// predecessor; without the key a changed event cannot be rehashed.
+func Hash(key []byte, e Event) string {
+	e.Hash = ""
+	b, _ := json.Marshal(e) // Event has only plain fields, Marshal cannot fail
+	mac := hmac.New(sha256.New, key)
+	mac.Write(b)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verifier checks events one by one, across as many files or pages as needed.
+type Verifier struct {
+	Key []byte // the key of the Auditor that wrote the chain
+	// From is the sequence number the trail must start at, for a trail whose
+	// oldest events were rotated away; 0 requires the first event.
+	From uint64
+
+	seq   uint64
+	prev  string
+	count int
+}
+
+// Check verifies e against its own hash and the previous event.
+func (v *Verifier) Check(e Event) error {
+	if got := Hash(v.Key, e); !hmac.Equal([]byte(got), []byte(e.Hash)) {
+		return fmt.Errorf("%w: event %d hash %s, computed %s", ErrBrokenChain, e.Seq, e.Hash, got)
+	}
+	if v.count > 0 {
+		if e.Seq != v.seq+1 {
+			return fmt.Errorf("%w: event %d follows %d", ErrBrokenChain, e.Seq, v.seq)
+		}
+		if e.PrevHash != v.prev {
+			return fmt.Errorf("%w: event %d does not link to event %d", ErrBrokenChain, e.Seq, v.seq)
+		}
+	} else {
+		if from := max(v.From, 1); e.Seq != from {
+			return fmt.Errorf("%w: trail starts at event %d, want %d", ErrBrokenChain, e.Seq, from)
+		}
+		if e.Seq == 1 && e.PrevHash != "" {
+			return fmt.Errorf("%w: first event has a previous hash", ErrBrokenChain)
+		}
+	}
+	v.seq, v.prev = e.Seq, e.Hash
+	v.count++
+	return nil
+}
+
+// Count returns the number of events verified so far.
+func (v *Verifier) Count() int {
+	return v.count
+}
+
+// VerifyLines checks a JSON-lines stream with v.
+func (v *Verifier) VerifyLines(r io.Reader) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	line := 0
+	for sc.Scan() {
+		line++
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := v.Check(e); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	return sc.Err()
+}
+
+// VerifyFiles checks JSON-lines files with v as one chain, in the order given.
+func (v *Verifier) VerifyFiles(paths ...string) error {
+	for _, p := range paths {
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		err = v.VerifyLines(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+	}
+	return nil
+}
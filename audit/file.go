@@ -0,0 +1,188 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileConfig configures a FileSink.
+type FileConfig struct {
+	Path       string
+	MaxSizeMB  int // rotate once the file would exceed this size, 0 disables rotation
+	MaxBackups int // rotated files to keep, 0 keeps all
+}
+
+// FileSink writes events as JSON lines. On rotation the current file is
+// renamed with a timestamp suffix and a new file continues the chain.
+type FileSink struct {
+	cfg FileConfig
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// NewFileSink opens or creates cfg.Path for appending.
+func NewFileSink(cfg FileConfig) (*FileSink, error) {
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("audit: file path is empty")
+	}
+	if err := os.MkdirAll(filepath.Dir(cfg.Path), 0o750); err != nil {
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+	s := &FileSink{cfg: cfg}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileSink) open() error {
+	f, err := os.OpenFile(s.cfg.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640)
+	if err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("audit: %w", err)
+	}
+	s.f, s.size = f, info.Size()
+	return nil
+}
+
+// Write appends e as one line, rotating first if needed.
+func (s *FileSink) Write(e Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return fmt.Errorf("audit: file sink closed")
+	}
+	if max := int64(s.cfg.MaxSizeMB) << 20; max > 0 && s.size > 0 && s.size+int64(len(b)) > max {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := s.f.Write(b)
+	s.size += int64(n)
+	return err
+}
+
+func (s *FileSink) rotate() error {
+	if err := s.f.Close(); err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+	s.f = nil
+	backup := s.cfg.Path + "." + time.Now().UTC().Format("20060102T150405.000000000")
+	if err := os.Rename(s.cfg.Path, backup); err != nil {
+		return fmt.Errorf("audit: rotate: %w", err)
+	}
+	if s.cfg.MaxBackups > 0 {
+		backups, err := Backups(s.cfg.Path)
+		if err != nil {
+			return err
+		}
+		for len(backups) > s.cfg.MaxBackups {
+			os.Remove(backups[0])
+			backups = backups[1:]
+		}
+	}
+	return s.open()
+}
+
+// Last returns the newest event, looking into the latest backup when the
+// current file is empty.
+func (s *FileSink) Last() (Event, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	backups, err := Backups(s.cfg.Path)
+	if err != nil {
+		return Event{}, false, err
+	}
+	files := append(backups, s.cfg.Path)
+	for i := len(files) - 1; i >= 0; i-- {
+		line, err := lastLine(files[i])
+		if err != nil {
+			return Event{}, false, err
+		}
+		if line == nil {
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal(line, &e); err != nil {
+			return Event{}, false, fmt.Errorf("audit: %s: last line: %w", files[i], err)
+		}
+		return e, true, nil
+	}
+	return Event{}, false, nil
+}
+
+// Close closes the current file.
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
+
+// Backups returns the rotated files of path, oldest first.
+func Backups(path string) ([]string, error) {
+	matches, err := filepath.Glob(path + ".*")
+	if err != nil {
+		return nil, err
+	}
+	prefix := path + "."
+	var out []string
+	for _, m := range matches {
+		if suffix := strings.TrimPrefix(m, prefix); len(suffix) > 0 && suffix[0] >= '0' && suffix[0] <= '9' {
+			out = append(out, m)
+		}
+	}
+	sort.Strings(out) // the timestamp suffix sorts chronologically
+	return out, nil
+}
+
+// lastLine returns the last non-empty line of the file, nil if there is none.
+func lastLine(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var last []byte
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			last = line
+		}
+		if err == io.EOF {
+			return last, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
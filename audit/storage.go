@@ -0,0 +1,60 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+
+	storage "github.com/NlightN22/OTPSMSProvider/storage"
+)
+
+// StorageSink keeps events in the storage audit table.
+type StorageSink struct {
+	store storage.Storage
+}
+
+// NewStorageSink returns a sink backed by store.
+func NewStorageSink(store storage.Storage) *StorageSink {
+	return &StorageSink{store: store}
+}
+
+// Write appends e.
+func (s *StorageSink) Write(e Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	s.store.AppendAudit(storage.AuditRecord{Seq: e.Seq, Data: b})
+	return nil
+}
+
+// Last returns the newest stored event.
+func (s *StorageSink) Last() (Event, bool, error) {
+	r, ok := s.store.LastAudit()
+	if !ok {
+		return Event{}, false, nil
+	}
+	var e Event
+	if err := json.Unmarshal(r.Data, &e); err != nil {
+		return Event{}, false, fmt.Errorf("audit: record %d: %w", r.Seq, err)
+	}
+	return e, true, nil
+}
+
+// List returns up to limit events after seq, oldest first.
+func (s *StorageSink) List(after uint64, limit int) ([]Event, error) {
+	records := s.store.ListAudit(after, limit)
+	out := make([]Event, 0, len(records))
+	for _, r := range records {
+		var e Event
+		if err := json.Unmarshal(r.Data, &e); err != nil {
+			return nil, fmt.Errorf("audit: record %d: %w", r.Seq, err)
+		}
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+// Close is a no-op; the storage is closed by its owner.
+func (s *StorageSink) Close() error {
+	return nil
+}
@@ -0,0 +1,188 @@
+// Package budget tracks SMS spend against daily and monthly caps.
+package budget
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NlightN22/OTPSMSProvider/message"
+	logger "github.com/NlightN22/OTPSMSProvider/pkg"
+	service "github.com/NlightN22/OTPSMSProvider/service"
+	storage "github.com/NlightN22/OTPSMSProvider/storage"
+	"go.uber.org/zap"
+)
+
+// ErrExceeded is returned by Allow when a spend cap is reached.
+var ErrExceeded = errors.New("sms budget exceeded")
+
+// Config describes prices and caps. Prices are per SMS segment.
+type Config struct {
+	Currency     string
+	Prices       map[string]float64 // phone prefix without "+" -> price, longest prefix wins
+	DefaultPrice float64            // price for numbers matching no prefix
+	Daily        float64            // 0 disables the daily cap
+	Monthly      float64            // 0 disables the monthly cap
+	Thresholds   []float64          // fractions of a cap that trigger an alert, e.g. 0.8
+	AllowOver    []string           // prefixes still served after a cap is exceeded
+}
+
+// PeriodStatus is spend within one accounting period.
+type PeriodStatus struct {
+	Period    string  `json:"period"`
+	Spent     float64 `json:"spent"`
+	Limit     float64 `json:"limit"`
+	Remaining float64 `json:"remaining"`
+	Exceeded  bool    `json:"exceeded"`
+}
+
+// Status is a snapshot of the current spend.
+type Status struct {
+	Currency string       `json:"currency"`
+	Day      PeriodStatus `json:"day"`
+	Month    PeriodStatus `json:"month"`
+}
+
+// Tracker accounts spend in storage and enforces the caps.
+type Tracker struct {
+	store    storage.Storage
+	cfg      Config
+	prefixes []string // price prefixes sorted longest first
+	now      func() time.Time
+	log      *zap.SugaredLogger
+}
+
+// NewTracker creates a Tracker backed by store.
+func NewTracker(store storage.Storage, cfg Config) *Tracker {
+	prefixes := make([]string, 0, len(cfg.Prices))
+	for p := range cfg.Prices {
+		prefixes = append(prefixes, p)
+	}
+	sort.Slice(prefixes, func(i, j int) bool { return len(prefixes[i]) > len(prefixes[j]) })
+	sort.Float64s(cfg.Thresholds)
+
+	return &Tracker{
+		store:    store,
+		cfg:      cfg,
+		prefixes: prefixes,
+		now:      time.Now,
+		log:      logger.New("Budget"),
+	}
+}
+
+// Price returns the price of one segment sent to phone.
+func (t *Tracker) Price(phone string) float64 {
+	digits := strings.TrimPrefix(phone, "+")
+	for _, p := range t.prefixes {
+		if strings.HasPrefix(digits, p) {
+			return t.cfg.Prices[p]
+		}
+	}
+	return t.cfg.DefaultPrice
+}
+
+// Allow returns ErrExceeded when a cap is reached, unless phone matches an AllowOver prefix.
+func (t *Tracker) Allow(phone string) error {
+	st := t.Status()
+	if !st.Day.Exceeded && !st.Month.Exceeded {
+		return nil
+	}
+	digits := strings.TrimPrefix(phone, "+")
+	for _, p := range t.cfg.AllowOver {
+		if strings.HasPrefix(digits, strings.TrimPrefix(p, "+")) {
+			return nil
+		}
+	}
+	return ErrExceeded
+}
+
+// Record accounts a successful send and returns its cost.
+func (t *Tracker) Record(phone string, segments int) float64 {
+	cost := t.Price(phone) * float64(segments)
+	if cost == 0 {
+		return 0
+	}
+	day, month := t.periods()
+	t.alert(day, t.cfg.Daily, t.store.AddSpend(day, cost), cost)
+	t.alert(month, t.cfg.Monthly, t.store.AddSpend(month, cost), cost)
+	return cost
+}
+
+// Status reports spend in the current day and month.
+func (t *Tracker) Status() Status {
+	day, month := t.periods()
+	return Status{
+		Currency: t.cfg.Currency,
+		Day:      periodStatus(day, t.store.GetSpend(day), t.cfg.Daily),
+		Month:    periodStatus(month, t.store.GetSpend(month), t.cfg.Monthly),
+	}
+}
+
+// alert logs every threshold crossed by the last add.
+func (t *Tracker) alert(period string, limit, total, cost float64) {
+	if limit <= 0 {
+		return
+	}
+	before := (total - cost) / limit
+	after := total / limit
+	for _, th := range t.cfg.Thresholds {
+		if before < th && after >= th {
+			t.log.Warnw("Budget threshold reached", "period", period, "threshold", th,
+				"spent", total, "limit", limit, "currency", t.cfg.Currency)
+		}
+	}
+	if before < 1 && after >= 1 {
+		t.log.Errorw("Budget exceeded, refusing new sends", "period", period,
+			"spent", total, "limit", limit, "currency", t.cfg.Currency)
+	}
+}
+
+func (t *Tracker) periods() (day, month string) {
+	now := t.now().UTC()
+	return "day:" + now.Format("2006-01-02"), "month:" + now.Format("2006-01")
+}
+
+func periodStatus(period string, spent, limit float64) PeriodStatus {
+	ps := PeriodStatus{Period: period, Spent: spent, Limit: limit}
+	if limit > 0 {
+		ps.Remaining = max(limit-spent, 0)
+		ps.Exceeded = spent >= limit
+	}
+	return ps
+}
+
+// Notifier records the cost of every message its wrapped notifier accepts.
+type Notifier struct {
+	next    service.Notifier
+	tracker *Tracker
+}
+
+// NewNotifier wraps next with cost accounting.
+func NewNotifier(next service.Notifier, tracker *Tracker) *Notifier {
+	return &Notifier{next: next, tracker: tracker}
+}
+
+// Send delegates to the wrapped notifier and records the cost on success.
+func (n *Notifier) Send(ctx context.Context, phone, msg string) (service.SendResult, error) {
+	res, err := n.next.Send(ctx, phone, msg)
+	if err != nil {
+		return res, err
+	}
+	segments := res.Segments
+	if segments == 0 {
+		segments = message.Analyze(msg).Segments
+	}
+	n.tracker.Record(phone, segments)
+	return res, nil
+}
+
+// Close closes the wrapped notifier if it holds resources.
+func (n *Notifier) Close() error {
+	if c, ok := n.next.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package budget
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	service "github.com/NlightN22/OTPSMSProvider/service"
+	storage "github.com/NlightN22/OTPSMSProvider/storage"
+)
+
+type stubNotifier struct {
+	res service.SendResult
+	err error
+}
+
+func (n *stubNotifier) Send(ctx context.Context, phone, msg string) (service.SendResult, error) {
+	return n.res, n.err
+}
+
+func newTestTracker(cfg Config) (*Tracker, *time.Time) {
+	now := time.Date(2024, 3, 31, 23, 0, 0, 0, time.UTC)
+	t := NewTracker(storage.NewMemoryStorage(), cfg)
+	t.now = func() time.Time { return now }
+	return t, &now
+}
+
+func TestTracker_Price(t *testing.T) {
+	tr, _ := newTestTracker(Config{
+		Prices:       map[string]float64{"7": 3, "7700": 5, "375": 8},
+		DefaultPrice: 10,
+	})
+	cases := map[string]float64{
+		"+79991234567":  3,
+		"+77001234567":  5,
+		"+375291234567": 8,
+		"+15551234567":  10,
+	}
+	for phone, want := range cases {
+		if got := tr.Price(phone); got != want {
+			t.Errorf("Price(%s) = %v; want %v", phone, got, want)
+		}
+	}
+}
+
+func TestTracker_DailyCap(t *testing.T) {
+	tr, now := newTestTracker(Config{
+		Prices:    map[string]float64{"7": 4, "1": 4},
+		Daily:     10,
+		AllowOver: []string{"+7"},
+	})
+
+	tr.Record("+15551234567", 2)
+	if err := tr.Allow("+15551234567"); err != nil {
+		t.Fatalf("Allow below cap = %v; want nil", err)
+	}
+	tr.Record("+15551234567", 1)
+
+	st := tr.Status()
+	if st.Day.Spent != 12 || !st.Day.Exceeded || st.Day.Remaining != 0 {
+		t.Errorf("Day = %+v; want spent 12, exceeded", st.Day)
+	}
+	if err := tr.Allow("+15551234567"); !errors.Is(err, ErrExceeded) {
+		t.Errorf("Allow over cap = %v; want ErrExceeded", err)
+	}
+	if err := tr.Allow("+79991234567"); err != nil {
+		t.Errorf("Allow for AllowOver prefix = %v; want nil", err)
+	}
+
+	*now = now.Add(2 * time.Hour)
+	if err := tr.Allow("+15551234567"); err != nil {
+		t.Errorf("Allow next day = %v; want nil", err)
+	}
+	if st := tr.Status(); st.Month.Spent != 0 || st.Month.Period != "month:2024-04" {
+		t.Errorf("Month = %+v; want fresh April period", st.Month)
+	}
+}
+
+func TestNotifier_RecordsCost(t *testing.T) {
+	tr, _ := newTestTracker(Config{DefaultPrice: 2})
+
+	n := NewNotifier(&stubNotifier{res: service.SendResult{Segments: 3}}, tr)
+	if _, err := n.Send(context.Background(), "+15551234567", "code"); err != nil {
+		t.Fatalf("Send error: %v", err)
+	}
+	if got := tr.Status().Day.Spent; got != 6 {
+		t.Errorf("Spent = %v; want 6", got)
+	}
+
+	failing := NewNotifier(&stubNotifier{err: errors.New("fail")}, tr)
+	if _, err := failing.Send(context.Background(), "+15551234567", "code"); err == nil {
+		t.Fatalf("Send error = nil; want fail")
+	}
+	if got := tr.Status().Day.Spent; got != 6 {
+		t.Errorf("Spent after failure = %v; want 6", got)
+	}
+}
@@ -11,7 +11,7 @@ import (
 // Config holds all application settings loaded from environment.
 type Config struct {
 	BindAddr   string   `mapstructure:"bind_addr" default:":8080"`                                    // address for HTTP server binding
-	WhiteList  []string `mapstructure:"white_list"`                                                   // allowed IPs for access control
+	WhiteList  []string `mapstructure:"white_list"`                                                   // allowed IPs and CIDR ranges for access control
 	Interval   int      `mapstructure:"interval" validate:"gte=0" default:"30"`                       // minimum interval between SMS sends
 	Period     int      `mapstructure:"period"  validate:"gt=0" default:"60"`                         // TOTP period
 	Digits     int      `mapstructure:"digits"  validate:"gt=0,lte=10" default:"6"`                   // number of digits in TOTP code
@@ -20,11 +20,213 @@ type Config struct {
 	Debug      bool     `mapstructure:"debug" env:"TOTP_DEBUG"`
 	LogLevel   string   `mapstructure:"log_level" env:"TOTP_LOG_LEVEL" default:"info"`
 	PrefixText string   `mapstructure:"prefix_text" env:"TOTP_LOG_LEVEL" default:"Your code is:"`
+	PlainText  bool     `mapstructure:"plain_text_responses"` // reply with legacy plain text instead of JSON
+
+	LogHashPhones bool   `mapstructure:"log_hash_phones"` // log an HMAC of phone numbers instead of a masked form, to correlate lines per phone
+	LogHashKey    string `mapstructure:"log_hash_key"`    // HMAC key for log_hash_phones; random per process when empty
+
+	TrustedProxies  []string            `mapstructure:"trusted_proxies"`               // proxies whose X-Forwarded-For is believed; empty trusts none
+	AllowLoopback   bool                `mapstructure:"allow_loopback" default:"true"` // loopback clients bypass the whitelists
+	RouteWhitelists map[string][]string `mapstructure:"route_whitelists"`              // path prefix -> IPs/CIDRs, checked on top of white_list; an empty list exempts the path from a shorter prefix's list
+
+	Server struct {
+		ReadTimeout       int `mapstructure:"read_timeout" default:"15"`       // seconds to read a whole request
+		ReadHeaderTimeout int `mapstructure:"read_header_timeout" default:"5"` // seconds to read request headers
+		WriteTimeout      int `mapstructure:"write_timeout" default:"30"`      // seconds to write a response, covers the SMSC call
+		IdleTimeout       int `mapstructure:"idle_timeout" default:"120"`      // seconds a keep-alive connection may stay idle
+		ShutdownTimeout   int `mapstructure:"shutdown_timeout" default:"30"`   // seconds to drain requests and deliveries on SIGTERM
+	} `mapstructure:"server"`
+
+	Health struct {
+		Timeout          int      `mapstructure:"timeout" default:"2"`            // seconds per readiness check
+		Critical         []string `mapstructure:"critical"`                       // checks whose failure makes /readyz return 503: storage, provider, backlog
+		ProviderInterval int      `mapstructure:"provider_interval" default:"60"` // seconds a provider balance result is reused
+		MinBalance       float64  `mapstructure:"min_balance"`                    // provider balance below this fails the check, 0 checks reachability only
+		MaxBacklog       int      `mapstructure:"max_backlog" default:"50"`       // sends in progress above this fail the check
+	} `mapstructure:"health"`
+
+	Metrics struct {
+		Enabled bool   `mapstructure:"enabled" default:"false"` // serve Prometheus metrics
+		Path    string `mapstructure:"path" default:"/metrics"` // scrape path, guarded by the whitelists only; add a route_whitelists entry for it
+	} `mapstructure:"metrics"`
+
+	Tracing struct {
+		Exporter    string  `mapstructure:"exporter" validate:"oneof=none stdout otlp" default:"none"` // where spans go; "none" still forwards incoming trace context
+		Endpoint    string  `mapstructure:"endpoint"`                                                  // OTLP/HTTP collector host:port
+		Insecure    bool    `mapstructure:"insecure"`                                                  // plain HTTP to the collector
+		ServiceName string  `mapstructure:"service_name" default:"otp-sms-provider"`                   // service.name of the spans
+		SampleRatio float64 `mapstructure:"sample_ratio" default:"1"`                                  // fraction of new traces recorded
+	} `mapstructure:"tracing"`
+
+	Lockout struct {
+		MaxFailures int `mapstructure:"max_failures" default:"0"` // wrong codes that lock a phone and purpose out of verification, 0 disables
+		Window      int `mapstructure:"window" default:"900"`     // seconds in which the failures must occur
+		Duration    int `mapstructure:"duration" default:"900"`   // seconds a phone stays locked
+	} `mapstructure:"lockout"`
+
+	Webhooks struct {
+		Workers        int       `mapstructure:"workers" default:"4"`         // concurrent deliveries
+		QueueSize      int       `mapstructure:"queue_size" default:"1000"`   // events waiting for a worker; more are dropped
+		MaxAttempts    int       `mapstructure:"max_attempts" default:"5"`    // attempts per delivery
+		Timeout        int       `mapstructure:"timeout" default:"5"`         // seconds per attempt
+		InitialBackoff int       `mapstructure:"initial_backoff" default:"1"` // seconds before the first retry, doubled after each
+		MaxBackoff     int       `mapstructure:"max_backoff" default:"60"`    // cap of the retry wait in seconds
+		Subscriptions  []Webhook `mapstructure:"subscriptions"`               // endpoints receiving events
+	} `mapstructure:"webhooks"`
+
+	Dashboard struct {
+		Enabled bool `mapstructure:"enabled" default:"false"` // serve the operator UI at /dashboard/, requires api_keys or tls.clients
+		Events  int  `mapstructure:"events" default:"1000"`   // recent events kept in memory for the UI
+	} `mapstructure:"dashboard"`
+
+	Audit struct {
+		Enabled    bool   `mapstructure:"enabled"`                   // record sends, verifications and admin actions in a hash chain
+		Key        string `mapstructure:"key"`                       // HMAC key of the chain, required when enabled; audit verify reads it from TOTP_AUDIT_KEY
+		File       string `mapstructure:"file"`                      // JSON-lines file, empty disables the file sink
+		MaxSizeMB  int    `mapstructure:"max_size_mb" default:"100"` // rotate the file beyond this size
+		MaxBackups int    `mapstructure:"max_backups" default:"10"`  // rotated files to keep, 0 keeps all
+		Storage    bool   `mapstructure:"storage" default:"true"`    // also keep events in the storage audit table
+	} `mapstructure:"audit"`
 
 	SMSC struct {
 		Login    string `mapstructure:"login"  validate:"required"`
 		Password string `mapstructure:"password"  validate:"required"`
+		BaseURL  string `mapstructure:"base_url" default:"https://smsc.ru"` // API root, override for mirrors or tests
+		Sender   string `mapstructure:"sender"`                             // registered sender name
+		CAFile   string `mapstructure:"ca_file"`                            // optional PEM bundle to trust instead of system roots
+		Timeout  int    `mapstructure:"timeout" default:"10"`               // HTTP timeout in seconds
+
+		StatusSecret string `mapstructure:"status_secret"` // "secret" query parameter of the /callbacks/smsc status URL; empty disables the callback
 	} `mapstructure:"smsc"`
+
+	RateLimit struct {
+		IP           RateLimit `mapstructure:"ip"`                        // per client IP; opt-in once trusted_proxies or client_ip forwarding is set up
+		Subnet       RateLimit `mapstructure:"subnet"`                    // per IPv4 /24 or IPv6 /64; opt-in like ip
+		Prefix       RateLimit `mapstructure:"prefix"`                    // per phone prefix
+		Global       RateLimit `mapstructure:"global"`                    // all sends together
+		PrefixDigits int       `mapstructure:"prefix_digits" default:"4"` // phone digits after "+" forming a prefix
+	} `mapstructure:"rate_limit"`
+
+	AdminToken string `mapstructure:"admin_token"` // "Authorization: Bearer" token of the /admin endpoints, which are not served without it; ignored with api_keys or tls.clients
+
+	Budget struct {
+		Currency        string             `mapstructure:"currency" default:"RUB"`
+		Prices          map[string]float64 `mapstructure:"prices"`           // phone prefix without "+" -> price per SMS segment
+		DefaultPrice    float64            `mapstructure:"default_price"`    // price per segment for unlisted prefixes
+		Daily           float64            `mapstructure:"daily"`            // daily cap, 0 disables
+		Monthly         float64            `mapstructure:"monthly"`          // monthly cap, 0 disables
+		AlertThresholds []float64          `mapstructure:"alert_thresholds"` // cap fractions that log an alert
+		AllowOver       []string           `mapstructure:"allow_over"`       // prefixes still served after a cap is reached
+	} `mapstructure:"budget"`
+
+	Phone struct {
+		DefaultRegion string   `mapstructure:"default_region"` // ISO region for national-format input, empty requires E.164
+		AllowedTypes  []string `mapstructure:"allowed_types"`  // line types accepted by /send, empty allows all
+	} `mapstructure:"phone"`
+
+	Policy struct {
+		Blocklist      []string `mapstructure:"blocklist"`                    // exact numbers or prefixes ending with "*"
+		AllowCountries []string `mapstructure:"allow_countries"`              // calling codes, empty allows all
+		BlocklistFile  string   `mapstructure:"blocklist_file"`               // hot-reloaded, one entry per line
+		AllowlistFile  string   `mapstructure:"allowlist_file"`               // hot-reloaded, one calling code per line
+		ReloadInterval int      `mapstructure:"reload_interval" default:"30"` // seconds between file checks
+	} `mapstructure:"policy"`
+
+	Messages struct {
+		DefaultLocale string                       `mapstructure:"default_locale" default:"en"`                                // used when the request locale has no template
+		AppName       string                       `mapstructure:"app_name"`                                                   // available as {{.AppName}}
+		AppHash       string                       `mapstructure:"app_hash"`                                                   // Android SMS Retriever hash appended to every message
+		WebOTPDomain  string                       `mapstructure:"webotp_domain"`                                              // adds the "@domain #code" WebOTP line
+		Templates     map[string]string            `mapstructure:"templates"`                                                  // locale -> text/template, overrides built-in en/ru
+		Named         map[string]map[string]string `mapstructure:"named_templates"`                                            // template ID -> locale -> text/template, chosen by /v1 template_id
+		MaxSegments   int                          `mapstructure:"max_segments" default:"1"`                                   // SMS parts a rendered template may take, 0 disables the check
+		SegmentPolicy string                       `mapstructure:"segment_policy" validate:"oneof=warn reject" default:"warn"` // what to do at startup when a template exceeds MaxSegments
+	} `mapstructure:"messages"`
+
+	Purposes map[string]Purpose `mapstructure:"purposes"` // per-action code settings; when set, every request must name one (the legacy /send and /verify cannot), when empty none may
+
+	APIKeys struct {
+		Enabled bool     `mapstructure:"enabled" default:"false"` // require a key on /send, /verify and /admin
+		Keys    []APIKey `mapstructure:"keys"`                    // static keys, generated with "apikey mint"
+	} `mapstructure:"api_keys"`
+
+	Signing struct {
+		Enabled bool              `mapstructure:"enabled" default:"false"` // require HMAC-signed /send and /verify
+		Secrets map[string]string `mapstructure:"secrets"`                 // key ID -> shared secret of at least 32 bytes
+		Window  int               `mapstructure:"window" default:"300"`    // seconds a signed request stays valid
+	} `mapstructure:"signing"`
+
+	JWT struct {
+		Enabled        bool     `mapstructure:"enabled" default:"false"`      // add a signed token to successful verify responses
+		Issuer         string   `mapstructure:"issuer"`                       // "iss" claim
+		Audience       []string `mapstructure:"audience"`                     // "aud" claim
+		TTL            int      `mapstructure:"ttl" default:"300"`            // token lifetime in seconds
+		Claims         []string `mapstructure:"claims"`                       // phone, purpose, verification_id, amr; empty for all
+		Keys           []JWTKey `mapstructure:"keys"`                         // first key signs, the rest are published for rotation
+		ReloadInterval int      `mapstructure:"reload_interval" default:"60"` // seconds between key file checks
+	} `mapstructure:"jwt"`
+
+	TLS struct {
+		Enabled        bool        `mapstructure:"enabled" default:"false"`                                          // serve HTTPS on bind_addr
+		CertFile       string      `mapstructure:"cert_file"`                                                        // PEM certificate chain
+		KeyFile        string      `mapstructure:"key_file"`                                                         // PEM private key
+		ClientCAFile   string      `mapstructure:"client_ca_file"`                                                   // CAs trusted to issue client certificates
+		ClientAuth     string      `mapstructure:"client_auth" validate:"oneof=none request require" default:"none"` // mTLS mode
+		ReloadInterval int         `mapstructure:"reload_interval" default:"60"`                                     // seconds between certificate file checks
+		Clients        []TLSClient `mapstructure:"clients"`                                                          // client certificate -> API identity, needs client_auth request or require
+	} `mapstructure:"tls"`
+}
+
+// RateLimit is a token-bucket limit; PerMinute 0 disables it. Every layer is off
+// by default: backends send from a handful of addresses, so per-IP limits only
+// make sense once the real client address reaches the service.
+type RateLimit struct {
+	PerMinute float64 `mapstructure:"per_minute"`
+	Burst     int     `mapstructure:"burst"`
+}
+
+// Purpose overrides code settings for one action. Zero values keep the global ones.
+type Purpose struct {
+	Period   int    `mapstructure:"period"`   // seconds a code step lasts
+	Digits   int    `mapstructure:"digits"`   // code length
+	Template string `mapstructure:"template"` // named message template
+}
+
+// APIKey is a client key defined in the config. Only the SHA-256 of the token is stored.
+type APIKey struct {
+	ID            string   `mapstructure:"id"`
+	Name          string   `mapstructure:"name"`
+	Hash          string   `mapstructure:"hash"` // hex SHA-256 of the token
+	Scopes        []string `mapstructure:"scopes"`
+	PerMinute     float64  `mapstructure:"per_minute"` // 0 disables the per-key limit
+	Burst         int      `mapstructure:"burst"`
+	PhonePrefixes []string `mapstructure:"phone_prefixes"` // empty allows every number
+}
+
+// Webhook is an endpoint subscribed to service events.
+type Webhook struct {
+	Name      string   `mapstructure:"name"`
+	URL       string   `mapstructure:"url"`
+	Secret    string   `mapstructure:"secret"`     // HMAC key of the X-Webhook-Signature header
+	Events    []string `mapstructure:"events"`     // code.sent, code.send_failed, code.delivered, code.delivery_failed, verification.succeeded, verification.failed, lockout; empty for all
+	MaskPhone bool     `mapstructure:"mask_phone"` // send a masked phone instead of the full number
+}
+
+// JWTKey is a PEM private key (RSA or Ed25519) used to sign tokens.
+// Its "kid" is the RFC 7638 thumbprint of the key.
+type JWTKey struct {
+	File string `mapstructure:"file"` // PKCS#8 or PKCS#1 PEM
+}
+
+// TLSClient maps a client certificate, by subject CN or SAN, to an API identity.
+type TLSClient struct {
+	Name          string   `mapstructure:"name"`
+	Subject       string   `mapstructure:"subject"` // certificate subject common name
+	SAN           string   `mapstructure:"san"`     // DNS name, email or URI SAN
+	Scopes        []string `mapstructure:"scopes"`
+	PerMinute     float64  `mapstructure:"per_minute"`
+	Burst         int      `mapstructure:"burst"`
+	PhonePrefixes []string `mapstructure:"phone_prefixes"`
 }
 
 func fileExists(path string) bool {
@@ -44,6 +246,91 @@ func LoadConfig() (*Config, error) {
 	v.SetDefault("digits", 6)
 	v.SetDefault("algorithm", "SHA1")
 	v.SetDefault("skew", 1)
+	v.SetDefault("plain_text_responses", false)
+	v.SetDefault("log_hash_phones", false)
+	v.SetDefault("log_hash_key", "")
+	v.SetDefault("trusted_proxies", []string{})
+	v.SetDefault("allow_loopback", true)
+	v.SetDefault("server.read_timeout", 15)
+	v.SetDefault("server.read_header_timeout", 5)
+	v.SetDefault("server.write_timeout", 30)
+	v.SetDefault("server.idle_timeout", 120)
+	v.SetDefault("server.shutdown_timeout", 30)
+	v.SetDefault("health.timeout", 2)
+	v.SetDefault("health.critical", []string{"storage"})
+	v.SetDefault("health.provider_interval", 60)
+	v.SetDefault("health.min_balance", 0)
+	v.SetDefault("health.max_backlog", 50)
+	v.SetDefault("metrics.enabled", false)
+	v.SetDefault("metrics.path", "/metrics")
+	v.SetDefault("tracing.exporter", "none")
+	v.SetDefault("tracing.endpoint", "")
+	v.SetDefault("tracing.insecure", false)
+	v.SetDefault("tracing.service_name", "otp-sms-provider")
+	v.SetDefault("tracing.sample_ratio", 1.0)
+	v.SetDefault("lockout.max_failures", 0)
+	v.SetDefault("lockout.window", 900)
+	v.SetDefault("lockout.duration", 900)
+	v.SetDefault("webhooks.workers", 4)
+	v.SetDefault("webhooks.queue_size", 1000)
+	v.SetDefault("webhooks.max_attempts", 5)
+	v.SetDefault("webhooks.timeout", 5)
+	v.SetDefault("webhooks.initial_backoff", 1)
+	v.SetDefault("webhooks.max_backoff", 60)
+	v.SetDefault("dashboard.enabled", false)
+	v.SetDefault("dashboard.events", 1000)
+	v.SetDefault("audit.enabled", false)
+	v.SetDefault("audit.key", "")
+	v.SetDefault("audit.file", "")
+	v.SetDefault("audit.max_size_mb", 100)
+	v.SetDefault("audit.max_backups", 10)
+	v.SetDefault("audit.storage", true)
+	v.SetDefault("smsc.login", "")
+	v.SetDefault("smsc.password", "")
+	v.SetDefault("smsc.base_url", "https://smsc.ru")
+	v.SetDefault("smsc.sender", "")
+	v.SetDefault("smsc.ca_file", "")
+	v.SetDefault("smsc.timeout", 10)
+	v.SetDefault("smsc.status_secret", "")
+	v.SetDefault("rate_limit.ip.per_minute", 0)
+	v.SetDefault("rate_limit.ip.burst", 0)
+	v.SetDefault("rate_limit.subnet.per_minute", 0)
+	v.SetDefault("rate_limit.subnet.burst", 0)
+	v.SetDefault("rate_limit.prefix.per_minute", 0)
+	v.SetDefault("rate_limit.prefix.burst", 0)
+	v.SetDefault("rate_limit.global.per_minute", 0)
+	v.SetDefault("rate_limit.global.burst", 0)
+	v.SetDefault("rate_limit.prefix_digits", 4)
+	v.SetDefault("admin_token", "")
+	v.SetDefault("budget.currency", "RUB")
+	v.SetDefault("budget.default_price", 0)
+	v.SetDefault("budget.daily", 0)
+	v.SetDefault("budget.monthly", 0)
+	v.SetDefault("budget.alert_thresholds", []float64{0.5, 0.8, 0.95})
+	v.SetDefault("phone.default_region", "")
+	v.SetDefault("phone.allowed_types", []string{"mobile", "fixed_or_mobile", "unknown"})
+	v.SetDefault("policy.blocklist_file", "")
+	v.SetDefault("policy.allowlist_file", "")
+	v.SetDefault("policy.reload_interval", 30)
+	v.SetDefault("messages.default_locale", "en")
+	v.SetDefault("messages.app_name", "")
+	v.SetDefault("messages.app_hash", "")
+	v.SetDefault("messages.webotp_domain", "")
+	v.SetDefault("messages.max_segments", 1)
+	v.SetDefault("messages.segment_policy", "warn")
+	v.SetDefault("api_keys.enabled", false)
+	v.SetDefault("signing.enabled", false)
+	v.SetDefault("signing.window", 300)
+	v.SetDefault("jwt.enabled", false)
+	v.SetDefault("jwt.issuer", "")
+	v.SetDefault("jwt.ttl", 300)
+	v.SetDefault("jwt.reload_interval", 60)
+	v.SetDefault("tls.enabled", false)
+	v.SetDefault("tls.cert_file", "")
+	v.SetDefault("tls.key_file", "")
+	v.SetDefault("tls.client_ca_file", "")
+	v.SetDefault("tls.client_auth", "none")
+	v.SetDefault("tls.reload_interval", 60)
 
 	v.SetEnvPrefix("TOTP")
 	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
@@ -69,3 +356,38 @@ func LoadConfig() (*Config, error) {
 
 	return &cfg, nil
 }
+
+// Redacted returns a copy of the config that is safe to log.
+func (c Config) Redacted() Config {
+	if c.SMSC.Password != "" {
+		c.SMSC.Password = "***"
+	}
+	if c.AdminToken != "" {
+		c.AdminToken = "***"
+	}
+	if c.LogHashKey != "" {
+		c.LogHashKey = "***"
+	}
+	if c.SMSC.StatusSecret != "" {
+		c.SMSC.StatusSecret = "***"
+	}
+	if c.Audit.Key != "" {
+		c.Audit.Key = "***"
+	}
+	if len(c.Webhooks.Subscriptions) > 0 {
+		subs := make([]Webhook, len(c.Webhooks.Subscriptions))
+		for i, w := range c.Webhooks.Subscriptions {
+			w.Secret = "***"
+			subs[i] = w
+		}
+		c.Webhooks.Subscriptions = subs
+	}
+	if len(c.Signing.Secrets) > 0 {
+		masked := make(map[string]string, len(c.Signing.Secrets))
+		for id := range c.Signing.Secrets {
+			masked[id] = "***"
+		}
+		c.Signing.Secrets = masked
+	}
+	return c
+}
@@ -32,6 +32,66 @@ func TestLoadConfigDefaults(t *testing.T) {
 	if cfg.Skew != 1 {
 		t.Errorf("Skew = %d; want 1", cfg.Skew)
 	}
+	if cfg.RateLimit.IP.PerMinute != 0 || cfg.RateLimit.Subnet.PerMinute != 0 || cfg.RateLimit.Prefix.PerMinute != 0 || cfg.RateLimit.Global.PerMinute != 0 {
+		t.Errorf("RateLimit = %+v; want every layer disabled", cfg.RateLimit)
+	}
+	if cfg.Budget.Currency != "RUB" || len(cfg.Budget.AlertThresholds) != 3 {
+		t.Errorf("Budget = %+v; want RUB with 3 alert thresholds", cfg.Budget)
+	}
+	if len(cfg.Phone.AllowedTypes) != 3 {
+		t.Errorf("Phone.AllowedTypes = %v; want 3 types", cfg.Phone.AllowedTypes)
+	}
+	if cfg.Policy.ReloadInterval != 30 {
+		t.Errorf("Policy.ReloadInterval = %d; want 30", cfg.Policy.ReloadInterval)
+	}
+	if cfg.Messages.DefaultLocale != "en" {
+		t.Errorf("Messages.DefaultLocale = %q; want \"en\"", cfg.Messages.DefaultLocale)
+	}
+	if cfg.Messages.MaxSegments != 1 || cfg.Messages.SegmentPolicy != "warn" {
+		t.Errorf("Messages segments = %d/%q; want 1/\"warn\"", cfg.Messages.MaxSegments, cfg.Messages.SegmentPolicy)
+	}
+	if !cfg.AllowLoopback || len(cfg.TrustedProxies) != 0 {
+		t.Errorf("AllowLoopback = %v, TrustedProxies = %v; want true and none", cfg.AllowLoopback, cfg.TrustedProxies)
+	}
+	if cfg.Signing.Enabled || cfg.Signing.Window != 300 {
+		t.Errorf("Signing = %+v; want disabled with a 300s window", cfg.Signing)
+	}
+	if cfg.TLS.Enabled || cfg.TLS.ClientAuth != "none" {
+		t.Errorf("TLS = %+v; want disabled without client auth", cfg.TLS)
+	}
+	if cfg.JWT.Enabled || cfg.JWT.TTL != 300 {
+		t.Errorf("JWT = %+v; want disabled with a 300s TTL", cfg.JWT)
+	}
+	if cfg.Server.WriteTimeout != 30 || cfg.Server.ShutdownTimeout != 30 || cfg.Server.ReadHeaderTimeout != 5 {
+		t.Errorf("Server = %+v; want 30s write/shutdown and 5s header timeouts", cfg.Server)
+	}
+	if len(cfg.Health.Critical) != 1 || cfg.Health.Critical[0] != "storage" || cfg.Health.MaxBacklog != 50 {
+		t.Errorf("Health = %+v; want storage critical and a backlog of 50", cfg.Health)
+	}
+	if cfg.LogHashPhones {
+		t.Errorf("LogHashPhones = true; want masked phones by default")
+	}
+	if cfg.Metrics.Enabled || cfg.Metrics.Path != "/metrics" {
+		t.Errorf("Metrics = %+v; want disabled on /metrics", cfg.Metrics)
+	}
+	if cfg.Tracing.Exporter != "none" || cfg.Tracing.SampleRatio != 1 {
+		t.Errorf("Tracing = %+v; want exporter none sampling everything", cfg.Tracing)
+	}
+	if cfg.Lockout.MaxFailures != 0 || cfg.Lockout.Duration != 900 {
+		t.Errorf("Lockout = %+v; want disabled, locking for 900s", cfg.Lockout)
+	}
+	if cfg.Webhooks.MaxAttempts != 5 || cfg.Webhooks.MaxBackoff != 60 || len(cfg.Webhooks.Subscriptions) != 0 {
+		t.Errorf("Webhooks = %+v; want 5 attempts, 60s backoff cap, no subscriptions", cfg.Webhooks)
+	}
+	if cfg.Dashboard.Enabled || cfg.Dashboard.Events != 1000 {
+		t.Errorf("Dashboard = %+v; want disabled with 1000 events", cfg.Dashboard)
+	}
+	if cfg.Audit.Enabled || !cfg.Audit.Storage || cfg.Audit.MaxSizeMB != 100 {
+		t.Errorf("Audit = %+v; want disabled, storage sink, 100 MB files", cfg.Audit)
+	}
+	if cfg.SMSC.BaseURL != "https://smsc.ru" {
+		t.Errorf("SMSC.BaseURL = %q; want \"https://smsc.ru\"", cfg.SMSC.BaseURL)
+	}
 }
 
 func TestLoadConfigFromEnv(t *testing.T) {
@@ -42,6 +102,19 @@ func TestLoadConfigFromEnv(t *testing.T) {
 	os.Setenv("TOTP_DIGITS", "8")
 	os.Setenv("TOTP_ALGORITHM", "SHA256")
 	os.Setenv("TOTP_SKEW", "3")
+	os.Setenv("TOTP_SMSC_SENDER", "ACME")
+	os.Setenv("TOTP_PHONE_DEFAULT_REGION", "RU")
+	os.Setenv("TOTP_RATE_LIMIT_GLOBAL_PER_MINUTE", "120")
+	os.Setenv("TOTP_API_KEYS_ENABLED", "true")
+	os.Setenv("TOTP_ALLOW_LOOPBACK", "false")
+	os.Setenv("TOTP_TRUSTED_PROXIES", "10.0.0.0/8,172.16.0.1")
+	os.Setenv("TOTP_SERVER_SHUTDOWN_TIMEOUT", "5")
+	os.Setenv("TOTP_TRACING_EXPORTER", "otlp")
+	os.Setenv("TOTP_LOG_HASH_PHONES", "true")
+	os.Setenv("TOTP_HEALTH_CRITICAL", "storage,provider")
+	os.Setenv("TOTP_AUDIT_FILE", "/var/log/otp/audit.log")
+	os.Setenv("TOTP_AUDIT_KEY", "chain-key")
+	os.Setenv("TOTP_LOCKOUT_MAX_FAILURES", "3")
 
 	cfg, err := LoadConfig()
 	if err != nil {
@@ -65,4 +138,37 @@ func TestLoadConfigFromEnv(t *testing.T) {
 	if cfg.Skew != 3 {
 		t.Errorf("Skew = %d; want 3", cfg.Skew)
 	}
+	if cfg.RateLimit.Global.PerMinute != 120 {
+		t.Errorf("RateLimit.Global.PerMinute = %v; want 120", cfg.RateLimit.Global.PerMinute)
+	}
+	if cfg.Phone.DefaultRegion != "RU" {
+		t.Errorf("Phone.DefaultRegion = %q; want \"RU\"", cfg.Phone.DefaultRegion)
+	}
+	if cfg.SMSC.Sender != "ACME" {
+		t.Errorf("SMSC.Sender = %q; want \"ACME\"", cfg.SMSC.Sender)
+	}
+	if !cfg.APIKeys.Enabled {
+		t.Errorf("APIKeys.Enabled = false; want true")
+	}
+	if cfg.AllowLoopback || len(cfg.TrustedProxies) != 2 {
+		t.Errorf("AllowLoopback = %v, TrustedProxies = %v; want false and two proxies", cfg.AllowLoopback, cfg.TrustedProxies)
+	}
+	if len(cfg.Health.Critical) != 2 {
+		t.Errorf("Health.Critical = %v; want storage and provider", cfg.Health.Critical)
+	}
+	if !cfg.LogHashPhones {
+		t.Errorf("LogHashPhones = false; want true")
+	}
+	if cfg.Tracing.Exporter != "otlp" {
+		t.Errorf("Tracing.Exporter = %q; want \"otlp\"", cfg.Tracing.Exporter)
+	}
+	if cfg.Server.ShutdownTimeout != 5 {
+		t.Errorf("Server.ShutdownTimeout = %d; want 5", cfg.Server.ShutdownTimeout)
+	}
+	if cfg.Audit.File != "/var/log/otp/audit.log" || cfg.Audit.Key != "chain-key" {
+		t.Errorf("Audit = %+v; want the file and key from env", cfg.Audit)
+	}
+	if cfg.Lockout.MaxFailures != 3 {
+		t.Errorf("Lockout.MaxFailures = %d; want 3", cfg.Lockout.MaxFailures)
+	}
 }
@@ -0,0 +1,204 @@
+// Package dashboard records service events for the embedded operator UI:
+// per-minute counts, recent events with masked phones, and the static files.
+package dashboard
+
+import (
+	"context"
+	"crypto/rand"
+	"embed"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	logger "github.com/NlightN22/OTPSMSProvider/pkg"
+	"github.com/NlightN22/OTPSMSProvider/ring"
+	service "github.com/NlightN22/OTPSMSProvider/service"
+	"github.com/NlightN22/OTPSMSProvider/validator"
+)
+
+//go:embed static
+var static embed.FS
+
+// Assets returns the UI files.
+func Assets() http.FileSystem {
+	sub, _ := fs.Sub(static, "static") // the directory is embedded, Sub cannot fail
+	return http.FS(sub)
+}
+
+// minutes is the span of Stats.Minutes.
+const minutes = 60
+
+// minFullPhone is the length of the shortest number searched as a whole: "+" and 7 digits.
+const minFullPhone = 8
+
+// DefaultEvents is how many recent events are kept when New gets zero.
+const DefaultEvents = 1000
+
+// Event is a recorded service event. Phone is masked.
+type Event struct {
+	Time      time.Time `json:"time"`
+	Type      string    `json:"type" example:"code.sent"`
+	Phone     string    `json:"phone" example:"+799******67"`
+	Purpose   string    `json:"purpose,omitempty"`
+	MessageID string    `json:"message_id,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+
+	phoneHash string // keyed hash of the full number, matched by full-number searches
+}
+
+// Failure reports whether e is shown among recent failures.
+func (e Event) Failure() bool {
+	switch e.Type {
+	case service.EventSendFailed, service.EventDeliveryFailed, service.EventVerificationFailed, service.EventLockout:
+		return true
+	}
+	return false
+}
+
+// Minute holds the event counts of one minute.
+type Minute struct {
+	Time   time.Time        `json:"time"`
+	Counts map[string]int64 `json:"counts"`
+}
+
+// Stats summarizes recorded events.
+type Stats struct {
+	Since    time.Time        `json:"since"`     // start of recording
+	Totals   map[string]int64 `json:"totals"`    // by event type since Since
+	LastHour map[string]int64 `json:"last_hour"` // by event type over Minutes
+	Minutes  []Minute         `json:"minutes"`   // the last 60 minutes, oldest first
+}
+
+// Query filters Events.
+type Query struct {
+	Phone    string // full number, or part of a masked one such as "+799" or "67"
+	Failures bool   // only failures
+	Limit    int    // 50 when zero
+}
+
+type bucket struct {
+	minute time.Time
+	counts map[string]int64
+}
+
+// Recorder collects events; pass Handle to service.WithEventHandler.
+type Recorder struct {
+	now func() time.Time
+	key []byte // per-process HMAC key of Event.phoneHash
+
+	mu      sync.Mutex
+	since   time.Time
+	totals  map[string]int64
+	buckets [minutes]bucket // indexed by minute of the hour
+	events  *ring.Buffer[Event]
+}
+
+// New returns a Recorder keeping the last size events.
+func New(size int) (*Recorder, error) {
+	if size <= 0 {
+		size = DefaultEvents
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("dashboard phone key: %w", err)
+	}
+	return &Recorder{now: time.Now, key: key, since: time.Now(), totals: make(map[string]int64), events: ring.New[Event](size)}, nil
+}
+
+// Handle records e.
+func (r *Recorder) Handle(ctx context.Context, e service.Event) {
+	ev := Event{
+		Time:      e.Time,
+		Type:      e.Type,
+		Phone:     logger.MaskPhone(e.Phone),
+		Purpose:   e.Purpose,
+		MessageID: e.MessageID,
+		RequestID: logger.RequestID(ctx),
+		phoneHash: logger.HashPhone(r.key, e.Phone),
+	}
+	if ev.Time.IsZero() {
+		ev.Time = r.now()
+	}
+	if e.Err != nil {
+		ev.Error = e.Err.Error()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.totals[e.Type]++
+	b := r.bucket(ev.Time.Truncate(time.Minute))
+	b.counts[e.Type]++
+	r.events.Add(ev)
+}
+
+// bucket returns the bucket of minute, resetting it when it holds an older
+// minute; r.mu must be held.
+func (r *Recorder) bucket(minute time.Time) *bucket {
+	b := &r.buckets[minute.Minute()]
+	if !b.minute.Equal(minute) {
+		*b = bucket{minute: minute, counts: make(map[string]int64)}
+	}
+	return b
+}
+
+// Stats returns the totals and the per-minute counts of the last hour.
+func (r *Recorder) Stats() Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	st := Stats{
+		Since:    r.since,
+		Totals:   make(map[string]int64, len(r.totals)),
+		LastHour: make(map[string]int64),
+		Minutes:  make([]Minute, 0, minutes),
+	}
+	for k, v := range r.totals {
+		st.Totals[k] = v
+	}
+	current := r.now().Truncate(time.Minute)
+	for i := minutes - 1; i >= 0; i-- {
+		minute := current.Add(-time.Duration(i) * time.Minute)
+		m := Minute{Time: minute, Counts: make(map[string]int64)}
+		if b := r.buckets[minute.Minute()]; b.minute.Equal(minute) {
+			for k, v := range b.counts {
+				m.Counts[k] = v
+				st.LastHour[k] += v
+			}
+		}
+		st.Minutes = append(st.Minutes, m)
+	}
+	return st
+}
+
+// Events returns recorded events matching q, newest first.
+func (r *Recorder) Events(q Query) []Event {
+	if q.Limit <= 0 {
+		q.Limit = 50
+	}
+	phone := strings.TrimSpace(q.Phone)
+	// A full number is matched by its hash, since numbers sharing the masked
+	// digits look the same; shorter input such as "+447" is a fragment of a
+	// masked phone.
+	var hash string
+	if len(phone) >= minFullPhone && validator.IsE164(phone) {
+		hash = logger.HashPhone(r.key, phone)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := []Event{}
+	r.events.Newest(func(e Event) bool {
+		switch {
+		case q.Failures && !e.Failure():
+		case hash != "" && e.phoneHash != hash:
+		case hash == "" && phone != "" && !strings.Contains(e.Phone, phone):
+		default:
+			out = append(out, e)
+		}
+		return len(out) < q.Limit
+	})
+	return out
+}
@@ -0,0 +1,97 @@
+package dashboard
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	service "github.com/NlightN22/OTPSMSProvider/service"
+)
+
+func TestRecorder_Stats(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 30, 10, 0, time.UTC)
+	r := newRecorder(t, 10)
+	r.now = func() time.Time { return now }
+
+	r.Handle(context.Background(), service.Event{Type: service.EventCodeSent, Time: now.Add(-2 * time.Hour)})
+	r.Handle(context.Background(), service.Event{Type: service.EventCodeSent, Time: now.Add(-time.Minute)})
+	r.Handle(context.Background(), service.Event{Type: service.EventCodeSent, Time: now})
+	r.Handle(context.Background(), service.Event{Type: service.EventVerificationFailed, Time: now})
+
+	st := r.Stats()
+	if st.Totals[service.EventCodeSent] != 3 {
+		t.Errorf("Totals = %v; want 3 sends", st.Totals)
+	}
+	if st.LastHour[service.EventCodeSent] != 2 || st.LastHour[service.EventVerificationFailed] != 1 {
+		t.Errorf("LastHour = %v; want 2 sends and 1 failed verification", st.LastHour)
+	}
+	if len(st.Minutes) != 60 || st.Minutes[59].Counts[service.EventCodeSent] != 1 || st.Minutes[58].Counts[service.EventCodeSent] != 1 {
+		t.Errorf("Minutes = %+v; want one send in each of the last two minutes", st.Minutes[58:])
+	}
+}
+
+func TestRecorder_Events(t *testing.T) {
+	r := newRecorder(t, 3)
+	ctx := context.Background()
+	r.Handle(ctx, service.Event{Type: service.EventCodeSent, Phone: "+15550000000"})
+	r.Handle(ctx, service.Event{Type: service.EventCodeSent, Phone: "+79991234567", MessageID: "1"})
+	r.Handle(ctx, service.Event{Type: service.EventSendFailed, Phone: "+79991234567", Err: errors.New("down")})
+	r.Handle(ctx, service.Event{Type: service.EventVerificationSucceeded, Phone: "+447700900123"})
+
+	all := r.Events(Query{})
+	if len(all) != 3 || all[0].Type != service.EventVerificationSucceeded {
+		t.Fatalf("Events = %+v; want the 3 newest, newest first", all)
+	}
+	for _, e := range all {
+		if e.Phone == "+79991234567" {
+			t.Errorf("phone %q is not masked", e.Phone)
+		}
+	}
+	if got := r.Events(Query{Phone: "+79991234567"}); len(got) != 2 {
+		t.Errorf("search by full phone = %+v; want 2 events", got)
+	}
+	if got := r.Events(Query{Phone: "+447"}); len(got) != 1 {
+		t.Errorf("search by masked prefix = %+v; want 1 event", got)
+	}
+	if got := r.Events(Query{Failures: true}); len(got) != 1 || got[0].Error != "down" {
+		t.Errorf("failures = %+v; want the send failure", got)
+	}
+}
+
+func TestRecorder_EventsFullPhoneCollision(t *testing.T) {
+	r := newRecorder(t, 10)
+	ctx := context.Background()
+	// Both numbers mask to "+799******67".
+	r.Handle(ctx, service.Event{Type: service.EventCodeSent, Phone: "+79991234567"})
+	r.Handle(ctx, service.Event{Type: service.EventCodeSent, Phone: "+79990000067"})
+
+	got := r.Events(Query{Phone: "+79990000067"})
+	if len(got) != 1 || got[0].Phone != "+799******67" {
+		t.Errorf("search by full phone = %+v; want only the matching number", got)
+	}
+	if got := r.Events(Query{Phone: "+799"}); len(got) != 2 {
+		t.Errorf("search by masked prefix = %+v; want both numbers", got)
+	}
+}
+
+func newRecorder(t *testing.T, size int) *Recorder {
+	t.Helper()
+	r, err := New(size)
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	return r
+}
+
+func TestAssets(t *testing.T) {
+	f, err := Assets().Open("index.html")
+	if err != nil {
+		t.Fatalf("Open index.html: %v", err)
+	}
+	defer f.Close()
+	if b, _ := io.ReadAll(f); len(b) == 0 {
+		t.Errorf("index.html is empty")
+	}
+}
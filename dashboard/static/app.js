@@ -0,0 +1,167 @@
+// Operator dashboard. Data comes from the admin API, authorized with the key
+// kept in sessionStorage; this page itself holds no data.
+"use strict";
+
+const REFRESH_MS = 5000;
+const SERIES = [
+  ["code.sent", "#2e7d32"],
+  ["code.send_failed", "#c62828"],
+  ["verification.succeeded", "#1565c0"],
+  ["verification.failed", "#ef6c00"],
+];
+const CARDS = [
+  ["code.sent", "Codes sent"],
+  ["code.send_failed", "Send failures"],
+  ["code.delivered", "Delivered"],
+  ["code.delivery_failed", "Not delivered"],
+  ["verification.succeeded", "Verified"],
+  ["verification.failed", "Wrong codes"],
+  ["lockout", "Lockouts"],
+];
+
+const $ = (sel) => document.querySelector(sel);
+let timer;
+
+async function api(path) {
+  const headers = {};
+  const key = sessionStorage.getItem("adminKey");
+  if (key) headers["X-API-Key"] = key;
+  const resp = await fetch(path, { headers });
+  if (resp.status === 401 || resp.status === 403) {
+    logout();
+    throw new Error("not authorized");
+  }
+  if (!resp.ok) throw new Error(`${path}: ${resp.status}`);
+  return resp.json();
+}
+
+function el(tag, text, cls) {
+  const e = document.createElement(tag);
+  if (text !== undefined) e.textContent = text;
+  if (cls) e.className = cls;
+  return e;
+}
+
+function row(...cells) {
+  const tr = el("tr");
+  for (const c of cells) tr.append(c instanceof Node ? c : el("td", c));
+  return tr;
+}
+
+const time = (t) => new Date(t).toLocaleTimeString();
+
+function renderStats(st) {
+  const cards = $("#stats");
+  cards.replaceChildren();
+  for (const [type, label] of CARDS) {
+    const card = el("div", undefined, "card");
+    card.append(el("b", String(st.last_hour[type] || 0)), el("span", label));
+    cards.append(card);
+  }
+
+  const svg = $("#chart");
+  svg.replaceChildren();
+  const max = Math.max(1, ...st.minutes.flatMap((m) => SERIES.map(([t]) => m.counts[t] || 0)));
+  const step = 600 / Math.max(1, st.minutes.length - 1);
+  for (const [type, color] of SERIES) {
+    const points = st.minutes.map((m, i) => `${(i * step).toFixed(1)},${(115 - ((m.counts[type] || 0) / max) * 110).toFixed(1)}`);
+    const line = document.createElementNS("http://www.w3.org/2000/svg", "polyline");
+    line.setAttribute("points", points.join(" "));
+    line.setAttribute("fill", "none");
+    line.setAttribute("stroke", color);
+    line.setAttribute("stroke-width", "2");
+    svg.append(line);
+  }
+}
+
+function renderHealth(rep) {
+  const body = $("#health tbody");
+  body.replaceChildren(row("overall", el("td", rep.status, rep.status), ""));
+  for (const [name, c] of Object.entries(rep.checks).sort()) {
+    body.append(row(name + (c.critical ? " (critical)" : ""), el("td", c.status, c.status),
+      c.error || `${c.duration_ms} ms, checked ${time(c.checked_at)}`));
+  }
+}
+
+function renderEvents(table, events) {
+  const body = table.querySelector("tbody");
+  body.replaceChildren();
+  if (events.length === 0) {
+    body.append(row("No events"));
+    return;
+  }
+  for (const e of events) {
+    body.append(row(time(e.time), e.type, e.phone, e.purpose || "", e.error || e.message_id || "", e.request_id || ""));
+  }
+}
+
+async function refresh() {
+  try {
+    const [stats, failures] = await Promise.all([
+      api("../admin/dashboard/stats"),
+      api("../admin/dashboard/events?failures=true&limit=20"),
+    ]);
+    renderStats(stats);
+    renderEvents($("#failures"), failures);
+    // The health report is optional: it needs the admin API of the service.
+    api("../admin/health").then(renderHealth, () => {});
+    $("#updated").textContent = "updated " + new Date().toLocaleTimeString();
+  } catch (err) {
+    $("#updated").textContent = err.message;
+  }
+}
+
+async function search(ev) {
+  ev.preventDefault();
+  const q = $("#phone").value.trim();
+  const state = $("#phone-state");
+  state.replaceChildren();
+  try {
+    renderEvents($("#results"), await api("../admin/dashboard/events?limit=50&phone=" + encodeURIComponent(q)));
+  } catch (err) {
+    state.append(el("p", err.message, "error"));
+    return;
+  }
+  if (!/^\+[1-9]\d{6,14}$/.test(q)) return;
+  try {
+    const st = await api("../admin/phones/" + encodeURIComponent(q));
+    const dl = el("p");
+    dl.textContent = [
+      `last send ${st.last_send ? time(st.last_send) : "never"}`,
+      st.next_send_in ? `next send in ${st.next_send_in}s` : "can send",
+      st.locked_until ? `locked until ${time(st.locked_until)}` : `${st.failures} wrong codes`,
+      `purposes: ${st.purposes.map((p) => p || "default").join(", ") || "none"}`,
+      `${st.deliveries.length} recent deliveries`,
+    ].join(" · ");
+    state.append(dl);
+  } catch (err) {
+    // The phone endpoints are optional as well.
+  }
+}
+
+function start() {
+  $("#login").hidden = true;
+  $("#app").hidden = false;
+  $("#logout").hidden = false;
+  refresh();
+  timer = setInterval(refresh, REFRESH_MS);
+}
+
+function logout() {
+  sessionStorage.removeItem("adminKey");
+  clearInterval(timer);
+  $("#app").hidden = true;
+  $("#logout").hidden = true;
+  $("#login").hidden = false;
+}
+
+$("#login").addEventListener("submit", (ev) => {
+  ev.preventDefault();
+  sessionStorage.setItem("adminKey", $("#key").value);
+  start();
+});
+$("#logout").addEventListener("click", logout);
+$("#search").addEventListener("submit", search);
+
+if (sessionStorage.getItem("adminKey") !== null) start();
+else logout();
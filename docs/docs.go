@@ -15,119 +15,1531 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/send": {
-            "post": {
-                "description": "Generates TOTP for given phone and records send time",
-                "consumes": [
+        "/.well-known/jwks.json": {
+            "get": {
+                "description": "Public keys for offline validation of tokens returned by /verify. Cache for a few minutes; keys rotate.",
+                "produces": [
                     "application/json"
                 ],
+                "summary": "JSON Web Key Set",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/token.JWKS"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/blocklist": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Generate and send TOTP code via SMS",
+                "summary": "List blocklist and country allowlist",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/policy.Entries"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "summary": "Add blocklist entry",
                 "parameters": [
                     {
-                        "description": "Phone",
+                        "description": "Entry",
                         "name": "data",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/api.SendRequest"
+                            "$ref": "#/definitions/api.BlocklistRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "invalid_request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "summary": "Remove blocklist entry",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Entry to remove",
+                        "name": "entry",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "not_found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
                         }
                     }
+                }
+            }
+        },
+        "/admin/budget": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
                 ],
+                "description": "Returns spend and remaining budget for the current day and month",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "SMS budget status",
                 "responses": {
                     "200": {
-                        "description": "Code sent",
+                        "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/budget.Status"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/dashboard/events": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Newest first. A full phone number matches its masked form; shorter input matches part of a masked phone.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Recent events",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Full number or part of a masked one",
+                        "name": "phone",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only failures",
+                        "name": "failures",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Maximum entries",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/dashboard.Event"
+                            }
                         }
                     },
                     "400": {
-                        "description": "Invalid request",
+                        "description": "invalid_request",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/api.ErrorResponse"
                         }
-                    },
-                    "429": {
-                        "description": "Too many requests",
+                    }
+                }
+            }
+        },
+        "/admin/dashboard/stats": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Event counts since start and per minute over the last hour.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Dashboard statistics",
+                "responses": {
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/dashboard.Stats"
                         }
                     }
                 }
             }
         },
-        "/verify": {
+        "/admin/health": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Same checks as /readyz, the SMS provider balance included, always answered with 200.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Dependency health",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/health.Report"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/keys": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "List API keys",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/apikey.Key"
+                            }
+                        }
+                    }
+                }
+            },
             "post": {
-                "description": "Checks provided TOTP code for validity",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "The token is returned only in this response; store it securely.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Validate TOTP code",
+                "summary": "Mint API key",
                 "parameters": [
                     {
-                        "description": "Code",
+                        "description": "Key settings",
                         "name": "data",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/api.VerifyRequest"
+                            "$ref": "#/definitions/api.MintKeyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.MintKeyResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid_request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/keys/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "summary": "Revoke API key",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Key ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "not_found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
                         }
                     }
+                }
+            }
+        },
+        "/admin/phones/{phone}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Last send, remaining interval, lockout, purposes with a secret and recent deliveries.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Inspect phone state",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Phone number",
+                        "name": "phone",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Code valid",
+                        "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/service.PhoneState"
                         }
                     },
                     "400": {
-                        "description": "Bad request",
+                        "description": "invalid_phone",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/api.ErrorResponse"
                         }
+                    }
+                }
+            }
+        },
+        "/admin/phones/{phone}/limits": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Clears the send interval and the verification lockout of the phone.",
+                "summary": "Reset phone rate limit and lockout",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Phone number",
+                        "name": "phone",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
                     },
-                    "401": {
-                        "description": "Invalid code",
+                    "400": {
+                        "description": "invalid_phone",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/api.ErrorResponse"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "api.SendRequest": {
-            "type": "object",
-            "required": [
-                "phone"
-            ],
-            "properties": {
-                "phone": {
-                    "type": "string"
+        },
+        "/admin/phones/{phone}/secrets": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Deletes the secrets of the phone for every purpose; the next send creates new ones.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Revoke phone secrets",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Phone number",
+                        "name": "phone",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.RevokeSecretsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid_phone",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
                 }
             }
         },
-        "api.VerifyRequest": {
-            "type": "object",
-            "required": [
-                "code",
-                "phone"
-            ],
-            "properties": {
-                "code": {
-                    "type": "string"
-                },
-                "phone": {
-                    "type": "string"
+        "/admin/webhooks/deliveries": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Finished deliveries, newest first, including failures after the last retry.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Recent webhook deliveries",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 100,
+                        "description": "Maximum entries",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/webhook.Delivery"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "invalid_request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
                 }
             }
+        },
+        "/callbacks/smsc": {
+            "post": {
+                "description": "Set \"\u003cbase URL\u003e/callbacks/smsc?secret=...\" as the status URL in the SMSC account.\nFinal statuses raise code.delivered or code.delivery_failed events; others are acknowledged and ignored.",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "text/plain"
+                ],
+                "summary": "SMSC delivery status callback",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Callback secret",
+                        "name": "secret",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Message ID",
+                        "name": "id",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Phone without the plus",
+                        "name": "phone",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "SMSC status code",
+                        "name": "status",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/healthz": {
+            "get": {
+                "description": "Returns 200 while the process can serve HTTP. Dependencies are not checked, so a provider outage does not restart the container.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Liveness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/health.ProbeReport"
+                        }
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "Checks storage, the SMS provider and the delivery backlog.\nReturns 503 when a critical check fails; failed non-critical checks give \"degraded\" with 200.\nOnly the overall status is returned; per-check details are on /admin/health.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Readiness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/health.ProbeReport"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/health.ProbeReport"
+                        }
+                    }
+                }
+            }
+        },
+        "/send": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Generates TOTP for given phone and records send time.\nErrors carry a stable code in \"error\"; rate-limited replies set Retry-After.\nSend \"Accept: text/plain\" to get the legacy plain-text replies.\nKept for existing clients; new integrations should use /v1/send.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "text/plain"
+                ],
+                "summary": "Generate and send TOTP code via SMS",
+                "parameters": [
+                    {
+                        "description": "Phone",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.SendRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.SendResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid_request, invalid_phone, unsupported_line_type, phone_unreachable",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized, invalid_signature, replayed_request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "ip_not_allowed, insufficient_scope, phone_not_allowed, phone_blocked, country_not_allowed",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "rate_limited",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "Seconds until the next attempt may succeed"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "provider_error, internal_error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "provider_unavailable, budget_exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/send": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Generates a code for the phone and delivers it over the requested channel.\nErrors carry a stable code in \"error\"; rate-limited replies set Retry-After.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "v1"
+                ],
+                "summary": "Generate and send a one-time code",
+                "parameters": [
+                    {
+                        "description": "Send request",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.SendRequestV1"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.SendResponseV1"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid_request, invalid_phone, unsupported_line_type, unsupported_channel, unknown_template, unknown_purpose, phone_unreachable",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized, invalid_signature, replayed_request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "ip_not_allowed, insufficient_scope, phone_not_allowed, phone_blocked, country_not_allowed",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "rate_limited",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "Seconds until the next attempt may succeed"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "provider_error, internal_error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "provider_unavailable, budget_exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/verify": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Checks the code sent to the phone. Purpose and payload must match the send; a code verifies only once.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "v1"
+                ],
+                "summary": "Validate a one-time code",
+                "parameters": [
+                    {
+                        "description": "Verify request",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.VerifyRequestV1"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.VerifyResponseV1"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid_request, invalid_phone",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized, invalid_signature, replayed_request, invalid_code",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "ip_not_allowed, insufficient_scope",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/verify": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Checks provided TOTP code for validity.\nKept for existing clients; new integrations should use /v1/verify.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "text/plain"
+                ],
+                "summary": "Validate TOTP code",
+                "parameters": [
+                    {
+                        "description": "Code",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.VerifyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.VerifyResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid_request, invalid_phone",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized, invalid_signature, replayed_request, invalid_code",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "ip_not_allowed, insufficient_scope",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "api.BlocklistRequest": {
+            "type": "object",
+            "required": [
+                "entry"
+            ],
+            "properties": {
+                "entry": {
+                    "description": "exact E.164 number or prefix ending with \"*\"",
+                    "type": "string",
+                    "example": "+7999*"
+                }
+            }
+        },
+        "api.ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "stable machine-readable code",
+                    "type": "string",
+                    "example": "rate_limited"
+                },
+                "message": {
+                    "description": "human-readable description, may change",
+                    "type": "string",
+                    "example": "Please wait 12s"
+                },
+                "reason": {
+                    "description": "detail for the code, e.g. the exceeded rate limit",
+                    "type": "string",
+                    "example": "ip"
+                },
+                "retry_after_seconds": {
+                    "description": "set for rate_limited, also sent as Retry-After",
+                    "type": "integer",
+                    "example": 12
+                }
+            }
+        },
+        "api.MintKeyRequest": {
+            "type": "object",
+            "required": [
+                "name",
+                "scopes"
+            ],
+            "properties": {
+                "burst": {
+                    "type": "integer",
+                    "minimum": 0,
+                    "example": 10
+                },
+                "name": {
+                    "type": "string",
+                    "maxLength": 64,
+                    "example": "shop-backend"
+                },
+                "per_minute": {
+                    "description": "0 disables the per-key limit",
+                    "type": "number",
+                    "minimum": 0,
+                    "example": 60
+                },
+                "phone_prefixes": {
+                    "description": "empty allows every number",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "+7"
+                    ]
+                },
+                "scopes": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "send",
+                        "verify"
+                    ]
+                }
+            }
+        },
+        "api.MintKeyResponse": {
+            "type": "object",
+            "properties": {
+                "key": {
+                    "$ref": "#/definitions/apikey.Key"
+                },
+                "token": {
+                    "type": "string",
+                    "example": "otp_3f9a1c0b7d2e4a56_9c1e..."
+                }
+            }
+        },
+        "api.RevokeSecretsResponse": {
+            "type": "object",
+            "properties": {
+                "revoked": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "api.SendRequest": {
+            "type": "object",
+            "required": [
+                "phone"
+            ],
+            "properties": {
+                "client_ip": {
+                    "description": "end-user IP for rate limiting, honoured from trusted proxies and keys with the client_ip scope",
+                    "type": "string",
+                    "example": "203.0.113.7"
+                },
+                "locale": {
+                    "description": "message language, falls back to Accept-Language and then the default",
+                    "type": "string",
+                    "example": "ru-RU"
+                },
+                "phone": {
+                    "description": "E.164 or national format of the default region",
+                    "type": "string",
+                    "example": "+79991234567"
+                }
+            }
+        },
+        "api.SendRequestV1": {
+            "type": "object",
+            "required": [
+                "phone"
+            ],
+            "properties": {
+                "channel": {
+                    "description": "delivery channel, only \"sms\" for now",
+                    "type": "string",
+                    "example": "sms"
+                },
+                "client_ip": {
+                    "description": "end-user IP for rate limiting, honoured from trusted proxies and keys with the client_ip scope",
+                    "type": "string",
+                    "example": "203.0.113.7"
+                },
+                "client_reference": {
+                    "description": "caller's own identifier, echoed back",
+                    "type": "string",
+                    "maxLength": 128,
+                    "example": "order-42"
+                },
+                "locale": {
+                    "description": "message language, falls back to Accept-Language and then the default",
+                    "type": "string",
+                    "example": "ru-RU"
+                },
+                "metadata": {
+                    "description": "opaque caller data",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "payload": {
+                    "description": "data the code is bound to, must be repeated on verify",
+                    "type": "string",
+                    "maxLength": 1024,
+                    "example": "EUR 120.00 to DE89370400440532013000"
+                },
+                "phone": {
+                    "description": "E.164 or national format of the default region",
+                    "type": "string",
+                    "example": "+79991234567"
+                },
+                "purpose": {
+                    "description": "what the code confirms; a code only verifies for the same purpose",
+                    "type": "string",
+                    "maxLength": 64,
+                    "example": "login"
+                },
+                "template_id": {
+                    "description": "named message template, empty for the default",
+                    "type": "string",
+                    "maxLength": 64,
+                    "example": "login"
+                }
+            }
+        },
+        "api.SendResponse": {
+            "type": "object",
+            "properties": {
+                "status": {
+                    "type": "string",
+                    "example": "sent"
+                }
+            }
+        },
+        "api.SendResponseV1": {
+            "type": "object",
+            "properties": {
+                "channel": {
+                    "type": "string",
+                    "example": "sms"
+                },
+                "client_reference": {
+                    "type": "string",
+                    "example": "order-42"
+                },
+                "purpose": {
+                    "type": "string",
+                    "example": "login"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "sent"
+                }
+            }
+        },
+        "api.VerifyRequest": {
+            "type": "object",
+            "required": [
+                "code",
+                "phone"
+            ],
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "phone": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.VerifyRequestV1": {
+            "type": "object",
+            "required": [
+                "code",
+                "phone"
+            ],
+            "properties": {
+                "client_reference": {
+                    "type": "string",
+                    "maxLength": 128,
+                    "example": "order-42"
+                },
+                "code": {
+                    "type": "string",
+                    "example": "123456"
+                },
+                "payload": {
+                    "type": "string",
+                    "maxLength": 1024,
+                    "example": "EUR 120.00 to DE89370400440532013000"
+                },
+                "phone": {
+                    "type": "string",
+                    "example": "+79991234567"
+                },
+                "purpose": {
+                    "type": "string",
+                    "maxLength": 64,
+                    "example": "login"
+                }
+            }
+        },
+        "api.VerifyResponse": {
+            "type": "object",
+            "properties": {
+                "expires_in": {
+                    "description": "seconds",
+                    "type": "integer",
+                    "example": 300
+                },
+                "token": {
+                    "type": "string",
+                    "example": "eyJhbGciOiJFZERTQSIsImtpZCI6IjIwMjYtMTAiLCJ0eXAiOiJKV1QifQ..."
+                },
+                "token_type": {
+                    "type": "string",
+                    "example": "Bearer"
+                },
+                "valid": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "verification_id": {
+                    "type": "string",
+                    "example": "9f86d081884c7d659a2feaa0c55ad015"
+                }
+            }
+        },
+        "api.VerifyResponseV1": {
+            "type": "object",
+            "properties": {
+                "client_reference": {
+                    "type": "string",
+                    "example": "order-42"
+                },
+                "expires_in": {
+                    "description": "seconds",
+                    "type": "integer",
+                    "example": 300
+                },
+                "token": {
+                    "type": "string",
+                    "example": "eyJhbGciOiJFZERTQSIsImtpZCI6IjIwMjYtMTAiLCJ0eXAiOiJKV1QifQ..."
+                },
+                "token_type": {
+                    "type": "string",
+                    "example": "Bearer"
+                },
+                "valid": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "verification_id": {
+                    "type": "string",
+                    "example": "9f86d081884c7d659a2feaa0c55ad015"
+                }
+            }
+        },
+        "apikey.Key": {
+            "type": "object",
+            "properties": {
+                "burst": {
+                    "type": "integer",
+                    "example": 10
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "3f9a1c0b7d2e4a56"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "shop-backend"
+                },
+                "per_minute": {
+                    "description": "0 means no per-key limit",
+                    "type": "number",
+                    "example": 60
+                },
+                "phone_prefixes": {
+                    "description": "empty allows every number",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "+7"
+                    ]
+                },
+                "revoked": {
+                    "type": "boolean"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "send",
+                        "verify"
+                    ]
+                },
+                "source": {
+                    "type": "string",
+                    "example": "storage"
+                }
+            }
+        },
+        "budget.PeriodStatus": {
+            "type": "object",
+            "properties": {
+                "exceeded": {
+                    "type": "boolean"
+                },
+                "limit": {
+                    "type": "number"
+                },
+                "period": {
+                    "type": "string"
+                },
+                "remaining": {
+                    "type": "number"
+                },
+                "spent": {
+                    "type": "number"
+                }
+            }
+        },
+        "budget.Status": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string"
+                },
+                "day": {
+                    "$ref": "#/definitions/budget.PeriodStatus"
+                },
+                "month": {
+                    "$ref": "#/definitions/budget.PeriodStatus"
+                }
+            }
+        },
+        "dashboard.Event": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "message_id": {
+                    "type": "string"
+                },
+                "phone": {
+                    "type": "string",
+                    "example": "+799******67"
+                },
+                "purpose": {
+                    "type": "string"
+                },
+                "request_id": {
+                    "type": "string"
+                },
+                "time": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "code.sent"
+                }
+            }
+        },
+        "dashboard.Minute": {
+            "type": "object",
+            "properties": {
+                "counts": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer",
+                        "format": "int64"
+                    }
+                },
+                "time": {
+                    "type": "string"
+                }
+            }
+        },
+        "dashboard.Stats": {
+            "type": "object",
+            "properties": {
+                "last_hour": {
+                    "description": "by event type over Minutes",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer",
+                        "format": "int64"
+                    }
+                },
+                "minutes": {
+                    "description": "the last 60 minutes, oldest first",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dashboard.Minute"
+                    }
+                },
+                "since": {
+                    "description": "start of recording",
+                    "type": "string"
+                },
+                "totals": {
+                    "description": "by event type since Since",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer",
+                        "format": "int64"
+                    }
+                }
+            }
+        },
+        "health.CheckResult": {
+            "type": "object",
+            "properties": {
+                "checked_at": {
+                    "type": "string"
+                },
+                "critical": {
+                    "type": "boolean"
+                },
+                "duration_ms": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "ok"
+                }
+            }
+        },
+        "health.ProbeReport": {
+            "type": "object",
+            "properties": {
+                "status": {
+                    "type": "string",
+                    "example": "ok"
+                }
+            }
+        },
+        "health.Report": {
+            "type": "object",
+            "properties": {
+                "checks": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/health.CheckResult"
+                    }
+                },
+                "status": {
+                    "type": "string",
+                    "example": "ok"
+                }
+            }
+        },
+        "policy.Entries": {
+            "type": "object",
+            "properties": {
+                "allow_countries": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "config": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "file": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "runtime": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "service.PhoneState": {
+            "type": "object",
+            "properties": {
+                "deliveries": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/storage.Delivery"
+                    }
+                },
+                "failures": {
+                    "description": "wrong codes counted towards the lockout, over all purposes",
+                    "type": "integer"
+                },
+                "last_send": {
+                    "type": "string"
+                },
+                "locked_until": {
+                    "description": "end of the latest lock of any purpose",
+                    "type": "string"
+                },
+                "next_send_in": {
+                    "description": "seconds until the interval allows another send",
+                    "type": "integer"
+                },
+                "phone": {
+                    "type": "string",
+                    "example": "+79991234567"
+                },
+                "purposes": {
+                    "description": "purposes with a secret; \"\" is the default purpose",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "storage.Delivery": {
+            "type": "object",
+            "properties": {
+                "encoding": {
+                    "type": "string"
+                },
+                "messageID": {
+                    "type": "string"
+                },
+                "metadata": {
+                    "description": "caller data of the send request",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "segments": {
+                    "type": "integer"
+                },
+                "sentAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "token.JWK": {
+            "type": "object",
+            "properties": {
+                "alg": {
+                    "type": "string",
+                    "example": "EdDSA"
+                },
+                "crv": {
+                    "type": "string",
+                    "example": "Ed25519"
+                },
+                "e": {
+                    "type": "string"
+                },
+                "kid": {
+                    "description": "RFC 7638 thumbprint for keys loaded from files",
+                    "type": "string",
+                    "example": "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k"
+                },
+                "kty": {
+                    "type": "string",
+                    "example": "OKP"
+                },
+                "n": {
+                    "type": "string"
+                },
+                "use": {
+                    "type": "string",
+                    "example": "sig"
+                },
+                "x": {
+                    "type": "string"
+                }
+            }
+        },
+        "token.JWKS": {
+            "type": "object",
+            "properties": {
+                "keys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/token.JWK"
+                    }
+                }
+            }
+        },
+        "webhook.Delivery": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer"
+                },
+                "delivered": {
+                    "type": "boolean"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "event": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "status": {
+                    "description": "HTTP status of the last attempt",
+                    "type": "integer"
+                },
+                "subscription": {
+                    "type": "string"
+                },
+                "time": {
+                    "description": "end of the last attempt",
+                    "type": "string"
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "ApiKeyAuth": {
+            "description": "Required when api_keys.enabled is set; \"Authorization: Bearer \u003ckey\u003e\" is accepted too.",
+            "type": "apiKey",
+            "name": "X-API-Key",
+            "in": "header"
         }
     }
 }`
@@ -139,7 +1551,7 @@ var SwaggerInfo = &swag.Spec{
 	BasePath:         "/",
 	Schemes:          []string{},
 	Title:            "TOTP SMS Auth API",
-	Description:      "API для генерации и проверки TOTP-кодов, отправляемых по SMS",
+	Description:      "API for generating and verifying OTP codes sent via SMS",
 	InfoInstanceName: "swagger",
 	SwaggerTemplate:  docTemplate,
 	LeftDelim:        "{{",
@@ -0,0 +1,46 @@
+package health
+
+import (
+	"context"
+	"fmt"
+)
+
+// Pinger is a dependency with a connectivity check, such as storage.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Balancer reports the SMS provider account balance.
+type Balancer interface {
+	Balance(ctx context.Context) (float64, error)
+}
+
+// PingCheck fails when p cannot be reached.
+func PingCheck(p Pinger) func(context.Context) error {
+	return p.Ping
+}
+
+// BalanceCheck fails when the provider is unreachable or its balance is below
+// min. A zero min only checks reachability.
+func BalanceCheck(b Balancer, min float64) func(context.Context) error {
+	return func(ctx context.Context) error {
+		bal, err := b.Balance(ctx)
+		if err != nil {
+			return err
+		}
+		if min > 0 && bal < min {
+			return fmt.Errorf("balance %.2f below %.2f", bal, min)
+		}
+		return nil
+	}
+}
+
+// BacklogCheck fails when more than max sends are in progress.
+func BacklogCheck(pending func() int, max int) func(context.Context) error {
+	return func(context.Context) error {
+		if n := pending(); n > max {
+			return fmt.Errorf("%d sends in progress, limit %d", n, max)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,187 @@
+// Package health serves liveness and readiness probes. Readiness runs a set
+// of dependency checks; only critical checks can take the replica out of
+// rotation, the others report "degraded".
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Check and report statuses.
+const (
+	StatusOK          = "ok"
+	StatusDegraded    = "degraded"    // a non-critical check failed
+	StatusUnavailable = "unavailable" // a critical check failed
+	StatusFail        = "fail"        // status of a single failed check
+)
+
+// DefaultTimeout bounds a check when Check.Timeout is zero.
+const DefaultTimeout = 2 * time.Second
+
+// Check is one readiness dependency.
+type Check struct {
+	Name     string
+	Critical bool                            // a failure makes /readyz return 503
+	Timeout  time.Duration                   // DefaultTimeout when zero
+	CacheFor time.Duration                   // reuse the last result this long, for checks that cost money or quota
+	Run      func(ctx context.Context) error // nil error means healthy
+}
+
+// CheckResult is the outcome of one check.
+type CheckResult struct {
+	Status     string    `json:"status" example:"ok"`
+	Critical   bool      `json:"critical"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+	CheckedAt  time.Time `json:"checked_at"`
+}
+
+// Report holds the outcome of every check, served on /admin/health.
+type Report struct {
+	Status string                 `json:"status" example:"ok"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// ProbeReport is the body of the public /healthz and /readyz probes.
+// It carries no check details, which may include balances or storage errors.
+type ProbeReport struct {
+	Status string `json:"status" example:"ok"`
+}
+
+// Health runs the registered checks.
+type Health struct {
+	checks []Check
+
+	mu    sync.Mutex
+	cache map[string]CheckResult
+}
+
+// New returns a Health running checks. Names must be unique.
+func New(checks ...Check) (*Health, error) {
+	seen := make(map[string]bool, len(checks))
+	for _, c := range checks {
+		if c.Name == "" || c.Run == nil {
+			return nil, errors.New("health check needs a name and a function")
+		}
+		if seen[c.Name] {
+			return nil, fmt.Errorf("duplicate health check %q", c.Name)
+		}
+		seen[c.Name] = true
+	}
+	return &Health{checks: checks, cache: make(map[string]CheckResult)}, nil
+}
+
+// Names returns the check names, sorted.
+func (h *Health) Names() []string {
+	names := make([]string, 0, len(h.checks))
+	for _, c := range h.checks {
+		names = append(names, c.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Ready runs all checks concurrently and summarizes them.
+func (h *Health) Ready(ctx context.Context) Report {
+	results := make([]CheckResult, len(h.checks))
+	var wg sync.WaitGroup
+	for i, c := range h.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = h.run(ctx, c)
+		}()
+	}
+	wg.Wait()
+
+	rep := Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(h.checks))}
+	for i, c := range h.checks {
+		r := results[i]
+		rep.Checks[c.Name] = r
+		if r.Status == StatusOK {
+			continue
+		}
+		if c.Critical {
+			rep.Status = StatusUnavailable
+		} else if rep.Status == StatusOK {
+			rep.Status = StatusDegraded
+		}
+	}
+	return rep
+}
+
+// run executes c, or returns its cached result while it is fresh.
+func (h *Health) run(ctx context.Context, c Check) CheckResult {
+	if c.CacheFor > 0 {
+		h.mu.Lock()
+		r, ok := h.cache[c.Name]
+		h.mu.Unlock()
+		if ok && time.Since(r.CheckedAt) < c.CacheFor {
+			return r
+		}
+	}
+
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := c.Run(ctx)
+	r := CheckResult{
+		Status:     StatusOK,
+		Critical:   c.Critical,
+		DurationMs: time.Since(start).Milliseconds(),
+		CheckedAt:  start,
+	}
+	if err != nil {
+		r.Status = StatusFail
+		r.Error = err.Error()
+	}
+
+	if c.CacheFor > 0 {
+		h.mu.Lock()
+		h.cache[c.Name] = r
+		h.mu.Unlock()
+	}
+	return r
+}
+
+// Live reports that the process is serving requests.
+// @Summary Liveness probe
+// @Description Returns 200 while the process can serve HTTP. Dependencies are not checked, so a provider outage does not restart the container.
+// @Produce json
+// @Success 200 {object} health.ProbeReport
+// @Router /healthz [get]
+func (h *Health) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, ProbeReport{Status: StatusOK})
+}
+
+// Readiness runs the dependency checks.
+// @Summary Readiness probe
+// @Description Checks storage, the SMS provider and the delivery backlog.
+// @Description Returns 503 when a critical check fails; failed non-critical checks give "degraded" with 200.
+// @Description Only the overall status is returned; per-check details are on /admin/health.
+// @Produce json
+// @Success 200 {object} health.ProbeReport
+// @Failure 503 {object} health.ProbeReport
+// @Router /readyz [get]
+func (h *Health) Readiness(c *gin.Context) {
+	rep := h.Ready(c.Request.Context())
+	status := http.StatusOK
+	if rep.Status == StatusUnavailable {
+		status = http.StatusServiceUnavailable
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(status, ProbeReport{Status: rep.Status})
+}
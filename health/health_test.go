@@ -0,0 +1,132 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ok(context.Context) error { return nil }
+
+func failing(context.Context) error { return errors.New("down") }
+
+func readyz(t *testing.T, h *Health) (int, ProbeReport) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/readyz", h.Readiness)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+	var rep ProbeReport
+	if err := json.Unmarshal(w.Body.Bytes(), &rep); err != nil {
+		t.Fatalf("body %s: %v", w.Body.String(), err)
+	}
+	return w.Code, rep
+}
+
+func TestReadiness(t *testing.T) {
+	cases := []struct {
+		name   string
+		checks []Check
+		code   int
+		status string
+	}{
+		{"all ok", []Check{{Name: "storage", Critical: true, Run: ok}, {Name: "provider", Run: ok}}, http.StatusOK, StatusOK},
+		{"non-critical fails", []Check{{Name: "storage", Critical: true, Run: ok}, {Name: "provider", Run: failing}}, http.StatusOK, StatusDegraded},
+		{"critical fails", []Check{{Name: "storage", Critical: true, Run: failing}, {Name: "provider", Run: failing}}, http.StatusServiceUnavailable, StatusUnavailable},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			h, err := New(tc.checks...)
+			if err != nil {
+				t.Fatalf("New error: %v", err)
+			}
+			code, rep := readyz(t, h)
+			if code != tc.code || rep.Status != tc.status {
+				t.Errorf("readyz = %d %q; want %d %q", code, rep.Status, tc.code, tc.status)
+			}
+			if checks := h.Ready(context.Background()).Checks; len(checks) != len(tc.checks) {
+				t.Errorf("checks = %v; want %d entries", checks, len(tc.checks))
+			}
+		})
+	}
+}
+
+func TestReady_ReportsCheckErrors(t *testing.T) {
+	h, _ := New(Check{Name: "provider", Run: failing})
+	rep := h.Ready(context.Background())
+	if r := rep.Checks["provider"]; r.Status != StatusFail || r.Error != "down" || r.Critical {
+		t.Errorf("provider = %+v; want non-critical fail with error", r)
+	}
+}
+
+func TestReadiness_HidesCheckDetails(t *testing.T) {
+	h, _ := New(Check{Name: "provider", Run: failing})
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/readyz", h.Readiness)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+	if body := w.Body.String(); strings.Contains(body, "down") || strings.Contains(body, "provider") {
+		t.Errorf("body = %s; want status only", body)
+	}
+}
+
+func TestReady_TimesOutSlowChecks(t *testing.T) {
+	slow := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	h, _ := New(Check{Name: "storage", Critical: true, Timeout: 10 * time.Millisecond, Run: slow})
+	if rep := h.Ready(context.Background()); rep.Status != StatusUnavailable {
+		t.Errorf("status = %q; want unavailable after timeout", rep.Status)
+	}
+}
+
+func TestReady_CachesResults(t *testing.T) {
+	calls := 0
+	h, _ := New(Check{Name: "provider", CacheFor: time.Minute, Run: func(context.Context) error {
+		calls++
+		return nil
+	}})
+	h.Ready(context.Background())
+	h.Ready(context.Background())
+	if calls != 1 {
+		t.Errorf("calls = %d; want 1 while cached", calls)
+	}
+}
+
+func TestNew_RejectsDuplicates(t *testing.T) {
+	if _, err := New(Check{Name: "a", Run: ok}, Check{Name: "a", Run: ok}); err == nil {
+		t.Error("New error = nil; want duplicate check error")
+	}
+}
+
+type balance float64
+
+func (b balance) Balance(context.Context) (float64, error) { return float64(b), nil }
+
+func TestChecks(t *testing.T) {
+	ctx := context.Background()
+	if err := BalanceCheck(balance(10), 50)(ctx); err == nil {
+		t.Error("BalanceCheck(10, min 50) = nil; want error")
+	}
+	if err := BalanceCheck(balance(10), 0)(ctx); err != nil {
+		t.Errorf("BalanceCheck(10, min 0) = %v; want nil", err)
+	}
+	pending := 3
+	if err := BacklogCheck(func() int { return pending }, 5)(ctx); err != nil {
+		t.Errorf("BacklogCheck(3, max 5) = %v; want nil", err)
+	}
+	pending = 6
+	if err := BacklogCheck(func() int { return pending }, 5)(ctx); err == nil {
+		t.Error("BacklogCheck(6, max 5) = nil; want error")
+	}
+}
@@ -2,19 +2,41 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"net/http"
+	"os"
+	"os/signal"
+	"sort"
 	"strings"
+	"syscall"
 	"time"
 
 	api "github.com/NlightN22/OTPSMSProvider/api"
+	"github.com/NlightN22/OTPSMSProvider/apikey"
+	"github.com/NlightN22/OTPSMSProvider/audit"
+	"github.com/NlightN22/OTPSMSProvider/budget"
 	config "github.com/NlightN22/OTPSMSProvider/config"
+	"github.com/NlightN22/OTPSMSProvider/dashboard"
 	_ "github.com/NlightN22/OTPSMSProvider/docs"
+	"github.com/NlightN22/OTPSMSProvider/health"
+	"github.com/NlightN22/OTPSMSProvider/message"
+	"github.com/NlightN22/OTPSMSProvider/metrics"
 	"github.com/NlightN22/OTPSMSProvider/middleware"
+	"github.com/NlightN22/OTPSMSProvider/phone"
 	logger "github.com/NlightN22/OTPSMSProvider/pkg"
+	"github.com/NlightN22/OTPSMSProvider/policy"
+	"github.com/NlightN22/OTPSMSProvider/ratelimit"
+	"github.com/NlightN22/OTPSMSProvider/server"
+	"github.com/NlightN22/OTPSMSProvider/servertls"
 	service "github.com/NlightN22/OTPSMSProvider/service"
+	"github.com/NlightN22/OTPSMSProvider/signing"
 	storage "github.com/NlightN22/OTPSMSProvider/storage"
+	"github.com/NlightN22/OTPSMSProvider/token"
+	"github.com/NlightN22/OTPSMSProvider/tracing"
 	"github.com/NlightN22/OTPSMSProvider/validator"
+	"github.com/NlightN22/OTPSMSProvider/webhook"
 
 	"github.com/gin-gonic/gin"
 	"github.com/pquerna/otp"
@@ -25,24 +47,61 @@ import (
 // @description API for generating and verifying OTP codes sent via SMS
 // @host localhost:8080
 // @BasePath /
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
+// @description Required when api_keys.enabled is set; "Authorization: Bearer <key>" is accepted too.
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "apikey" {
+		os.Exit(runAPIKeyCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "audit" {
+		os.Exit(runAuditCommand(os.Args[2:]))
+	}
 
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		panic(err)
 	}
 
-	if err := logger.Init(cfg.LogLevel); err != nil {
+	redaction := logger.Redaction{HashPhones: cfg.LogHashPhones, HashKey: []byte(cfg.LogHashKey)}
+	if err := logger.Init(cfg.LogLevel, redaction); err != nil {
 		panic(fmt.Errorf("logger init: %w", err))
 	}
 
 	mainLog := logger.New("main")
 
 	defer mainLog.Sync()
+	if cfg.LogHashPhones && cfg.LogHashKey == "" {
+		mainLog.Warnw("log_hash_key is empty, phone hashes change on every restart")
+	}
+
+	// Cancelled on SIGINT/SIGTERM; stops the file watchers and starts the graceful shutdown.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	mainLog.Infow("Loaded configuration", "config", cfg.Redacted())
+
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Config{
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		Insecure:    cfg.Tracing.Insecure,
+		ServiceName: cfg.Tracing.ServiceName,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		mainLog.Fatalw("Tracing", "err", err)
+	}
 
-	mainLog.Infow("Loaded configuration", "config", cfg)
+	var prom *metrics.Metrics
+	if cfg.Metrics.Enabled {
+		prom = metrics.New()
+	}
 
-	store := storage.NewMemoryStorage()
+	var store storage.Storage = storage.NewMemoryStorage()
+	if prom != nil {
+		store = prom.NewStorage(store)
+	}
 
 	algo := otp.AlgorithmSHA1
 	switch strings.ToUpper(cfg.Algorithm) {
@@ -53,10 +112,155 @@ func main() {
 	}
 
 	var notifier service.Notifier
+	var balancer health.Balancer
+	provider := "smsc"
 	if cfg.Debug {
 		notifier = service.NewNoopNotifier()
+		provider = "noop"
 	} else {
-		notifier = service.NewSMSCService(cfg.SMSC.Login, cfg.SMSC.Password, cfg.PrefixText)
+		smsc, err := service.NewSMSCService(service.SMSCConfig{
+			Login:    cfg.SMSC.Login,
+			Password: cfg.SMSC.Password,
+			BaseURL:  cfg.SMSC.BaseURL,
+			Sender:   cfg.SMSC.Sender,
+			CAFile:   cfg.SMSC.CAFile,
+			Timeout:  time.Duration(cfg.SMSC.Timeout) * time.Second,
+		})
+		if err != nil {
+			mainLog.Fatalw("SMSC init", "err", err)
+		}
+		notifier = smsc
+		balancer = smsc
+	}
+	if prom != nil {
+		notifier = prom.NewNotifier(notifier, provider)
+	}
+
+	tracker := budget.NewTracker(store, budget.Config{
+		Currency:     cfg.Budget.Currency,
+		Prices:       cfg.Budget.Prices,
+		DefaultPrice: cfg.Budget.DefaultPrice,
+		Daily:        cfg.Budget.Daily,
+		Monthly:      cfg.Budget.Monthly,
+		Thresholds:   cfg.Budget.AlertThresholds,
+		AllowOver:    cfg.Budget.AllowOver,
+	})
+	billed := budget.NewNotifier(notifier, tracker)
+
+	templates := cfg.Messages.Templates
+	if len(templates) == 0 && cfg.PrefixText != "" {
+		// Keep the legacy "prefix + code" message for configs written before templates existed.
+		templates = map[string]string{cfg.Messages.DefaultLocale: cfg.PrefixText + "{{.Code}}"}
+	}
+	renderer, err := message.NewRenderer(message.Config{
+		Templates:     templates,
+		Named:         cfg.Messages.Named,
+		DefaultLocale: cfg.Messages.DefaultLocale,
+		AppName:       cfg.Messages.AppName,
+		AppHash:       cfg.Messages.AppHash,
+		WebOTPDomain:  cfg.Messages.WebOTPDomain,
+	})
+	if err != nil {
+		mainLog.Fatalw("Message templates", "err", err)
+	}
+	mainLog.Infow("Message templates loaded", "locales", renderer.Locales())
+
+	if cfg.Messages.MaxSegments > 0 {
+		ttl := time.Duration(cfg.Period*(cfg.Skew+1)) * time.Second
+		analyses, err := renderer.Analyze(cfg.Digits, ttl)
+		if err != nil {
+			mainLog.Fatalw("Message templates", "err", err)
+		}
+		names := make([]string, 0, len(analyses))
+		for name := range analyses {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			a := analyses[name]
+			if a.Segments <= cfg.Messages.MaxSegments {
+				continue
+			}
+			if cfg.Messages.SegmentPolicy == "reject" {
+				mainLog.Fatalw("Message template exceeds segment budget",
+					"template", name, "encoding", a.Encoding, "segments", a.Segments, "max", cfg.Messages.MaxSegments)
+			}
+			mainLog.Warnw("Message template exceeds segment budget",
+				"template", name, "encoding", a.Encoding, "segments", a.Segments, "max", cfg.Messages.MaxSegments)
+		}
+	}
+
+	var purposes map[string]service.Purpose
+	if len(cfg.Purposes) > 0 {
+		purposes = make(map[string]service.Purpose, len(cfg.Purposes))
+		for name, p := range cfg.Purposes {
+			if p.Template != "" && !renderer.HasTemplate(p.Template) {
+				mainLog.Fatalw("Purpose references unknown template", "purpose", name, "template", p.Template)
+			}
+			purposes[name] = service.Purpose{Period: uint(p.Period), Digits: otp.Digits(p.Digits), TemplateID: p.Template}
+		}
+		mainLog.Warnw("purposes are set, so /send and /verify, which carry none, are refused; use the /v1 routes")
+	}
+
+	svcOpts := []service.Option{
+		service.WithRenderer(renderer),
+		service.WithPurposes(purposes),
+		service.WithLockout(service.Lockout{
+			MaxFailures: cfg.Lockout.MaxFailures,
+			Window:      time.Duration(cfg.Lockout.Window) * time.Second,
+			Duration:    time.Duration(cfg.Lockout.Duration) * time.Second,
+		}),
+	}
+	var auditor *audit.Auditor
+	if cfg.Audit.Enabled {
+		if cfg.Audit.Key == "" {
+			mainLog.Fatalw("audit.key is required when the audit log is enabled")
+		}
+		var sinks []audit.Sink
+		if cfg.Audit.File != "" {
+			fileSink, err := audit.NewFileSink(audit.FileConfig{
+				Path:       cfg.Audit.File,
+				MaxSizeMB:  cfg.Audit.MaxSizeMB,
+				MaxBackups: cfg.Audit.MaxBackups,
+			})
+			if err != nil {
+				mainLog.Fatalw("Audit file", "err", err)
+			}
+			sinks = append(sinks, fileSink)
+		}
+		if cfg.Audit.Storage {
+			sinks = append(sinks, audit.NewStorageSink(store))
+		}
+		if auditor, err = audit.New([]byte(cfg.Audit.Key), sinks...); err != nil {
+			mainLog.Fatalw("Audit log", "err", err)
+		}
+		svcOpts = append(svcOpts, service.WithEventHandler(auditor.ServiceEvent))
+	}
+	var board *dashboard.Recorder
+	if cfg.Dashboard.Enabled {
+		if board, err = dashboard.New(cfg.Dashboard.Events); err != nil {
+			mainLog.Fatalw("Dashboard", "err", err)
+		}
+		svcOpts = append(svcOpts, service.WithEventHandler(board.Handle))
+	}
+	var hooks *webhook.Dispatcher
+	if len(cfg.Webhooks.Subscriptions) > 0 {
+		subs := make([]webhook.Subscription, 0, len(cfg.Webhooks.Subscriptions))
+		for _, w := range cfg.Webhooks.Subscriptions {
+			subs = append(subs, webhook.Subscription{Name: w.Name, URL: w.URL, Secret: w.Secret, Events: w.Events, MaskPhone: w.MaskPhone})
+		}
+		hooks, err = webhook.New(webhook.Config{
+			Workers:        cfg.Webhooks.Workers,
+			QueueSize:      cfg.Webhooks.QueueSize,
+			MaxAttempts:    cfg.Webhooks.MaxAttempts,
+			Timeout:        time.Duration(cfg.Webhooks.Timeout) * time.Second,
+			InitialBackoff: time.Duration(cfg.Webhooks.InitialBackoff) * time.Second,
+			MaxBackoff:     time.Duration(cfg.Webhooks.MaxBackoff) * time.Second,
+		}, subs)
+		if err != nil {
+			mainLog.Fatalw("Webhooks", "err", err)
+		}
+		svcOpts = append(svcOpts, service.WithEventHandler(hooks.Handle))
 	}
 
 	svc := service.NewTotpService(
@@ -66,23 +270,294 @@ func main() {
 		otp.Digits(cfg.Digits),
 		algo,
 		uint(cfg.Skew),
-		time.Duration(cfg.Interval),
-		notifier,
+		time.Duration(cfg.Interval)*time.Second,
+		billed,
+		svcOpts...,
 	)
 
-	r := gin.Default()
+	// gin.Default's text logger is replaced by zap access logs carrying the request ID.
+	httpLog := logger.New("HTTP")
+	r := gin.New()
+	r.Use(
+		middleware.NewRequestIDMiddleware().Handler(),
+		middleware.NewAccessLogMiddleware(httpLog).Handler(),
+		gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, err any) {
+			logger.For(c.Request.Context(), httpLog).Errorw("Panic while serving request", "err", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal_error", "message": "Internal error"})
+		}),
+	)
+	if prom != nil {
+		r.Use(prom.Middleware())
+	}
+	r.Use(tracing.Middleware())
+	// Without trusted proxies gin ignores X-Forwarded-For, so clients cannot spoof their IP.
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		mainLog.Fatalw("Trusted proxies", "err", err)
+	}
 
 	r.GET("/ping", func(c *gin.Context) {
 		c.String(http.StatusOK, "pong")
 	})
 
-	whitelistMw := middleware.NewWhitelistMiddleware(cfg.WhiteList)
+	// Probes are registered before the whitelist: kubelet and Traefik check from node addresses.
+	critical := make(map[string]bool, len(cfg.Health.Critical))
+	for _, name := range cfg.Health.Critical {
+		critical[name] = true
+	}
+	checkTimeout := time.Duration(cfg.Health.Timeout) * time.Second
+	checks := []health.Check{
+		{Name: "storage", Critical: critical["storage"], Timeout: checkTimeout, Run: health.PingCheck(store)},
+		{Name: "backlog", Critical: critical["backlog"], Timeout: checkTimeout, Run: health.BacklogCheck(svc.Pending, cfg.Health.MaxBacklog)},
+	}
+	if balancer != nil {
+		checks = append(checks, health.Check{
+			Name:     "provider",
+			Critical: critical["provider"],
+			Timeout:  checkTimeout,
+			CacheFor: time.Duration(cfg.Health.ProviderInterval) * time.Second,
+			Run:      health.BalanceCheck(balancer, cfg.Health.MinBalance),
+		})
+	}
+	probes, err := health.New(checks...)
+	if err != nil {
+		mainLog.Fatalw("Health checks", "err", err)
+	}
+	for _, name := range cfg.Health.Critical {
+		if name != "storage" && name != "backlog" && name != "provider" {
+			mainLog.Warnw("Unknown critical health check", "check", name, "known", probes.Names())
+		}
+	}
+	r.GET("/healthz", probes.Live)
+	r.GET("/readyz", probes.Readiness)
+
+	if _, err := middleware.ParseWhitelist(cfg.WhiteList); err != nil {
+		mainLog.Fatalw("Whitelist", "err", err)
+	}
+	wlOpts := []middleware.WhitelistOption{middleware.WithLoopback(cfg.AllowLoopback)}
+	whitelistMw := middleware.NewWhitelistMiddleware(cfg.WhiteList, wlOpts...)
 	r.Use(whitelistMw.Handler())
+	var routeMw *middleware.RouteWhitelist
+	if len(cfg.RouteWhitelists) > 0 {
+		routeMw, err = middleware.NewRouteWhitelist(cfg.RouteWhitelists, wlOpts...)
+		if err != nil {
+			mainLog.Fatalw("Route whitelist", "err", err)
+		}
+		r.Use(routeMw.Handler())
+	}
+	if prom != nil {
+		if routeMw == nil || !routeMw.Covers(cfg.Metrics.Path) {
+			mainLog.Warnw("metrics.path has no non-empty route_whitelists entry, any whitelisted client can scrape it", "path", cfg.Metrics.Path)
+		}
+		r.GET(cfg.Metrics.Path, gin.WrapH(prom.Handler()))
+	}
 
 	validator.RegisterCustomValidations()
 
-	api := api.NewAPI(svc)
+	rl := ratelimit.NewLimiter(store, ratelimit.Limits{
+		IP:           ratelimit.Limit(cfg.RateLimit.IP),
+		Subnet:       ratelimit.Limit(cfg.RateLimit.Subnet),
+		Prefix:       ratelimit.Limit(cfg.RateLimit.Prefix),
+		Global:       ratelimit.Limit(cfg.RateLimit.Global),
+		PrefixDigits: cfg.RateLimit.PrefixDigits,
+	})
+	var sendLimiter api.RateLimiter = rl
+	var keyLimiter middleware.KeyLimiter = rl
+	if prom != nil {
+		counted := prom.NewLimiter(rl)
+		sendLimiter, keyLimiter = counted, counted
+	}
+
+	pol, err := policy.New(store, policy.Config{
+		Blocklist:      cfg.Policy.Blocklist,
+		AllowCountries: cfg.Policy.AllowCountries,
+		BlocklistFile:  cfg.Policy.BlocklistFile,
+		AllowlistFile:  cfg.Policy.AllowlistFile,
+	})
+	if err != nil {
+		mainLog.Fatalw("Policy init", "err", err)
+	}
+	go pol.Watch(ctx, time.Duration(cfg.Policy.ReloadInterval)*time.Second)
+
+	phones, err := phone.NewNormalizer(cfg.Phone.DefaultRegion, cfg.Phone.AllowedTypes)
+	if err != nil {
+		mainLog.Fatalw("Phone normalizer", "err", err)
+	}
+
+	// SetTrustedProxies above already rejected malformed entries.
+	proxies, _ := middleware.ParseWhitelist(cfg.TrustedProxies)
+	apiOpts := []api.Option{
+		api.WithPhoneNormalizer(phones),
+		api.WithTrustedProxies(proxies),
+		api.WithLimiter(sendLimiter),
+		api.WithBudget(tracker),
+		api.WithPolicy(pol),
+		api.WithPhoneAdmin(svc),
+		api.WithHealthReport(probes),
+	}
+	if cfg.PlainText {
+		apiOpts = append(apiOpts, api.WithPlainText())
+	}
+	if auditor != nil {
+		apiOpts = append(apiOpts, api.WithAudit(auditor))
+	}
+	if hooks != nil {
+		apiOpts = append(apiOpts, api.WithWebhookLog(hooks))
+	}
+	if board != nil {
+		apiOpts = append(apiOpts, api.WithDashboard(board))
+	}
+	if provider == "smsc" {
+		switch {
+		case cfg.SMSC.StatusSecret != "":
+			apiOpts = append(apiOpts, api.WithDeliveryReports(svc, cfg.SMSC.StatusSecret))
+		case hooks != nil || auditor != nil:
+			// Unauthenticated reports would become signed webhooks and audit records.
+			mainLog.Fatalw("smsc.status_secret is required when webhooks or the audit log are enabled")
+		default:
+			mainLog.Warnw("smsc.status_secret is empty, /callbacks/smsc and delivery reports are disabled")
+		}
+	}
+	var certClients *servertls.ClientMap
+	if len(cfg.TLS.Clients) > 0 {
+		// Without a requested certificate no client could ever match.
+		if !cfg.TLS.Enabled || cfg.TLS.ClientAuth == "" || cfg.TLS.ClientAuth == servertls.ClientAuthNone {
+			mainLog.Fatalw("tls.clients needs tls.enabled and tls.client_auth request or require")
+		}
+		clients := make([]servertls.Client, 0, len(cfg.TLS.Clients))
+		for _, c := range cfg.TLS.Clients {
+			clients = append(clients, servertls.Client{
+				Name:          c.Name,
+				Subject:       c.Subject,
+				SAN:           c.SAN,
+				Scopes:        c.Scopes,
+				PerMinute:     c.PerMinute,
+				Burst:         c.Burst,
+				PhonePrefixes: c.PhonePrefixes,
+			})
+		}
+		if certClients, err = servertls.NewClientMap(clients); err != nil {
+			mainLog.Fatalw("TLS clients", "err", err)
+		}
+	}
+	if cfg.APIKeys.Enabled || certClients != nil {
+		static := make([]apikey.Key, 0, len(cfg.APIKeys.Keys))
+		for _, k := range cfg.APIKeys.Keys {
+			static = append(static, apikey.Key{
+				ID:            k.ID,
+				Name:          k.Name,
+				Hash:          k.Hash,
+				Scopes:        k.Scopes,
+				PerMinute:     k.PerMinute,
+				Burst:         k.Burst,
+				PhonePrefixes: k.PhonePrefixes,
+			})
+		}
+		keys, err := apikey.NewManager(store, static)
+		if err != nil {
+			mainLog.Fatalw("API keys", "err", err)
+		}
+		var authOpts []middleware.APIKeyOption
+		if certClients != nil {
+			authOpts = append(authOpts, middleware.WithClientCerts(certClients))
+		}
+		apiOpts = append(apiOpts,
+			api.WithAuth(middleware.NewAPIKeyMiddleware(keys, keyLimiter, authOpts...)),
+			api.WithKeyManager(keys),
+		)
+		if cfg.AdminToken != "" {
+			mainLog.Warnw("admin_token is ignored, /admin requires a key or client certificate with the admin scope")
+		}
+	}
+	if cfg.Signing.Enabled {
+		verifier, err := signing.NewVerifier(store, cfg.Signing.Secrets, time.Duration(cfg.Signing.Window)*time.Second)
+		if err != nil {
+			mainLog.Fatalw("Request signing", "err", err)
+		}
+		apiOpts = append(apiOpts, api.WithSignature(middleware.NewSignatureMiddleware(verifier)))
+	}
+	if cfg.JWT.Enabled {
+		files := make([]string, 0, len(cfg.JWT.Keys))
+		for _, k := range cfg.JWT.Keys {
+			files = append(files, k.File)
+		}
+		var extra []token.Key
+		if len(files) == 0 {
+			// Tokens signed with a generated key stop validating after a restart.
+			mainLog.Warnw("No JWT signing keys configured, using a generated Ed25519 key")
+			dev, err := token.GenerateKey("dev-" + time.Now().UTC().Format("20060102150405"))
+			if err != nil {
+				mainLog.Fatalw("JWT key", "err", err)
+			}
+			extra = append(extra, dev)
+		}
+		issuer, err := token.NewIssuer(token.Config{
+			Issuer:   cfg.JWT.Issuer,
+			Audience: cfg.JWT.Audience,
+			TTL:      time.Duration(cfg.JWT.TTL) * time.Second,
+			Claims:   cfg.JWT.Claims,
+			Files:    files,
+		}, extra...)
+		if err != nil {
+			mainLog.Fatalw("JWT issuer", "err", err)
+		}
+		go issuer.Watch(ctx, time.Duration(cfg.JWT.ReloadInterval)*time.Second)
+		apiOpts = append(apiOpts, api.WithTokenIssuer(issuer))
+	}
+	if !cfg.APIKeys.Enabled && certClients == nil && !cfg.Signing.Enabled {
+		mainLog.Warnw("API keys and request signing are disabled, access is limited by IP whitelist only")
+	}
+	if !cfg.APIKeys.Enabled && certClients == nil && board != nil {
+		mainLog.Fatalw("dashboard.enabled requires api_keys or tls.clients")
+	}
+	if cfg.AdminToken != "" {
+		apiOpts = append(apiOpts, api.WithAdminAuth(middleware.NewAdminTokenMiddleware(cfg.AdminToken).Handler()))
+	} else if !cfg.APIKeys.Enabled && certClients == nil {
+		mainLog.Warnw("admin_token is empty, /admin endpoints are disabled")
+	}
+	var otpSvc service.OTPService = svc
+	if prom != nil {
+		otpSvc = prom.NewService(svc)
+	}
+	api := api.NewAPI(otpSvc, apiOpts...)
 	api.RegisterRoutes(r)
 
-	mainLog.Fatal(r.Run(cfg.BindAddr))
+	srvCfg := server.Config{
+		Addr:              cfg.BindAddr,
+		ReadTimeout:       time.Duration(cfg.Server.ReadTimeout) * time.Second,
+		ReadHeaderTimeout: time.Duration(cfg.Server.ReadHeaderTimeout) * time.Second,
+		WriteTimeout:      time.Duration(cfg.Server.WriteTimeout) * time.Second,
+		IdleTimeout:       time.Duration(cfg.Server.IdleTimeout) * time.Second,
+		ShutdownTimeout:   time.Duration(cfg.Server.ShutdownTimeout) * time.Second,
+	}
+	if cfg.TLS.Enabled {
+		certs, err := servertls.New(servertls.Config{
+			CertFile:     cfg.TLS.CertFile,
+			KeyFile:      cfg.TLS.KeyFile,
+			ClientCAFile: cfg.TLS.ClientCAFile,
+			ClientAuth:   cfg.TLS.ClientAuth,
+		})
+		if err != nil {
+			mainLog.Fatalw("TLS", "err", err)
+		}
+		go certs.Watch(ctx, time.Duration(cfg.TLS.ReloadInterval)*time.Second)
+		srvCfg.TLSConfig = certs.TLSConfig()
+	}
+
+	srv := server.New(r, srvCfg)
+	// Handlers send synchronously, so the HTTP drain normally covers deliveries;
+	// Drain also catches sends still running when the drain deadline hit.
+	srv.OnShutdown("deliveries", svc.Drain)
+	srv.OnShutdown("notifier", func(context.Context) error { return billed.Close() })
+	if hooks != nil {
+		srv.OnShutdown("webhooks", hooks.Close)
+	}
+	if auditor != nil {
+		srv.OnShutdown("audit", func(context.Context) error { return auditor.Close() })
+	}
+	srv.OnShutdown("storage", func(context.Context) error { return store.Close() })
+	srv.OnShutdown("tracing", shutdownTracing)
+	if err := srv.Run(ctx); err != nil {
+		mainLog.Fatalw("Server", "err", err)
+	}
+	mainLog.Infow("Server stopped")
 }
@@ -0,0 +1,61 @@
+package message
+
+import (
+	"strings"
+	"unicode/utf16"
+)
+
+// Encoding is the data coding an SMS is sent with.
+type Encoding string
+
+const (
+	GSM7 Encoding = "GSM-7"
+	UCS2 Encoding = "UCS-2"
+)
+
+// Segment sizes from 3GPP TS 23.038/23.040. Multipart messages lose room to the UDH.
+const (
+	gsm7Single = 160
+	gsm7Multi  = 153
+	ucs2Single = 70
+	ucs2Multi  = 67
+)
+
+// gsm7Basic is the GSM 03.38 default alphabet, each character costs one septet.
+const gsm7Basic = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+	"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+
+// gsm7Extension characters are sent as ESC + char and cost two septets.
+const gsm7Extension = "\f^{}\\[~]|€"
+
+// Analysis describes how a text is encoded and billed.
+type Analysis struct {
+	Encoding Encoding `json:"encoding"`
+	Units    int      `json:"units"`    // septets for GSM-7, UTF-16 code units for UCS-2
+	Segments int      `json:"segments"` // number of SMS parts the text is split into
+}
+
+// Analyze picks the encoding a provider will use for text and counts its segments.
+// A single character outside the GSM-7 alphabet switches the whole message to UCS-2.
+func Analyze(text string) Analysis {
+	septets := 0
+	for _, r := range text {
+		switch {
+		case strings.ContainsRune(gsm7Basic, r):
+			septets++
+		case strings.ContainsRune(gsm7Extension, r):
+			septets += 2
+		default:
+			units := len(utf16.Encode([]rune(text)))
+			return Analysis{Encoding: UCS2, Units: units, Segments: segments(units, ucs2Single, ucs2Multi)}
+		}
+	}
+	return Analysis{Encoding: GSM7, Units: septets, Segments: segments(septets, gsm7Single, gsm7Multi)}
+}
+
+func segments(units, single, multi int) int {
+	if units <= single {
+		return 1
+	}
+	return (units + multi - 1) / multi
+}
@@ -0,0 +1,30 @@
+package message
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	cases := []struct {
+		name string
+		text string
+		want Analysis
+	}{
+		{"empty", "", Analysis{GSM7, 0, 1}},
+		{"gsm7 single", strings.Repeat("a", 160), Analysis{GSM7, 160, 1}},
+		{"gsm7 multi", strings.Repeat("a", 161), Analysis{GSM7, 161, 2}},
+		{"gsm7 extension", "{code}€", Analysis{GSM7, 10, 1}},
+		{"extension overflows", strings.Repeat("€", 81), Analysis{GSM7, 162, 2}},
+		{"cyrillic single", strings.Repeat("ж", 70), Analysis{UCS2, 70, 1}},
+		{"cyrillic multi", strings.Repeat("ж", 71), Analysis{UCS2, 71, 2}},
+		{"one cyrillic letter", strings.Repeat("a", 100) + "ж", Analysis{UCS2, 101, 2}},
+		{"emoji surrogate pair", "🙂", Analysis{UCS2, 2, 1}},
+		{"ucs2 three parts", strings.Repeat("ж", 135), Analysis{UCS2, 135, 3}},
+	}
+	for _, tc := range cases {
+		if got := Analyze(tc.text); got != tc.want {
+			t.Errorf("%s: Analyze = %+v; want %+v", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+// Package message renders the SMS text that carries an OTP code.
+package message
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// DefaultLocale is used when neither the request nor the config selects one.
+const DefaultLocale = "en"
+
+// DefaultTemplates are used for locales that have no configured template.
+var DefaultTemplates = map[string]string{
+	"en": "{{if .AppName}}{{.AppName}}: {{end}}your code is {{.Code}}. Valid for {{.ExpiryMinutes}} min.",
+	"ru": "{{if .AppName}}{{.AppName}}: {{end}}ваш код {{.Code}}. Действует {{.ExpiryMinutes}} мин.",
+}
+
+// ErrUnknownTemplate is returned for a template ID that is not configured.
+var ErrUnknownTemplate = errors.New("unknown message template")
+
+// Data is the set of values available to message templates.
+type Data struct {
+	Code          string
+	ExpiryMinutes int
+	AppName       string
+	AppHash       string // Android SMS Retriever application hash
+	Domain        string // WebOTP origin without scheme
+}
+
+// Config describes message templates and the values shared by all of them.
+type Config struct {
+	Templates     map[string]string            // locale -> text/template source
+	Named         map[string]map[string]string // template ID -> locale -> source, selected per request
+	DefaultLocale string
+	AppName       string
+	AppHash       string
+	WebOTPDomain  string
+}
+
+// Renderer turns an OTP code into a localized SMS text.
+type Renderer struct {
+	templates     map[string]*template.Template
+	named         map[string]map[string]*template.Template
+	defaultLocale string
+	appName       string
+	appHash       string
+	domain        string
+}
+
+// NewRenderer parses all templates. Configured templates override the defaults for the same locale.
+func NewRenderer(cfg Config) (*Renderer, error) {
+	r := &Renderer{
+		templates:     make(map[string]*template.Template),
+		named:         make(map[string]map[string]*template.Template),
+		defaultLocale: NormalizeLocale(cfg.DefaultLocale),
+		appName:       cfg.AppName,
+		appHash:       cfg.AppHash,
+		domain:        strings.TrimPrefix(cfg.WebOTPDomain, "https://"),
+	}
+	if r.defaultLocale == "" {
+		r.defaultLocale = DefaultLocale
+	}
+
+	sources := make(map[string]string, len(DefaultTemplates)+len(cfg.Templates))
+	for locale, text := range DefaultTemplates {
+		sources[locale] = text
+	}
+	for locale, text := range cfg.Templates {
+		sources[NormalizeLocale(locale)] = text
+	}
+	var err error
+	if r.templates, err = parseSet("", sources); err != nil {
+		return nil, err
+	}
+	if _, ok := r.templates[r.defaultLocale]; !ok {
+		return nil, fmt.Errorf("no template for default locale %q", r.defaultLocale)
+	}
+	for id, locales := range cfg.Named {
+		set, err := parseSet(id, locales)
+		if err != nil {
+			return nil, err
+		}
+		// Named templates never fall back to the generic text, so they must cover the default locale.
+		if _, ok := set[r.defaultLocale]; !ok {
+			return nil, fmt.Errorf("template %q has no default locale %q", id, r.defaultLocale)
+		}
+		r.named[id] = set
+	}
+	return r, nil
+}
+
+func parseSet(id string, sources map[string]string) (map[string]*template.Template, error) {
+	set := make(map[string]*template.Template, len(sources))
+	for locale, text := range sources {
+		locale = NormalizeLocale(locale)
+		tmpl, err := template.New(id + "/" + locale).Option("missingkey=error").Parse(text)
+		if err != nil {
+			if id != "" {
+				return nil, fmt.Errorf("template %q locale %q: %w", id, locale, err)
+			}
+			return nil, fmt.Errorf("template %q: %w", locale, err)
+		}
+		set[locale] = tmpl
+	}
+	return set, nil
+}
+
+// HasTemplate reports whether id is a configured template ID. The empty ID is the default template.
+func (r *Renderer) HasTemplate(id string) bool {
+	if id == "" {
+		return true
+	}
+	_, ok := r.named[id]
+	return ok
+}
+
+// Locales returns the locales that have a template, sorted.
+func (r *Renderer) Locales() []string {
+	locales := make([]string, 0, len(r.templates))
+	for l := range r.templates {
+		locales = append(locales, l)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Resolve returns the locale whose template will be used for the requested one.
+// Lookup order: exact tag ("pt-br"), base language ("pt"), default locale.
+func (r *Renderer) Resolve(locale string) string {
+	return resolve(r.templates, locale, r.defaultLocale)
+}
+
+func resolve(set map[string]*template.Template, locale, def string) string {
+	locale = NormalizeLocale(locale)
+	if _, ok := set[locale]; ok {
+		return locale
+	}
+	if base, _, found := strings.Cut(locale, "-"); found {
+		if _, ok := set[base]; ok {
+			return base
+		}
+	}
+	return def
+}
+
+// Render produces the SMS text for code in the requested locale.
+// When an app hash or WebOTP domain is configured the matching suffix is appended;
+// the WebOTP "@domain #code" line is always the last line as the spec requires.
+func (r *Renderer) Render(locale, code string, expiry time.Duration) (string, error) {
+	return r.RenderTemplate("", locale, code, expiry)
+}
+
+// RenderTemplate is Render with the template selected by ID; the empty ID is the default template.
+func (r *Renderer) RenderTemplate(id, locale, code string, expiry time.Duration) (string, error) {
+	set := r.templates
+	if id != "" {
+		var ok bool
+		if set, ok = r.named[id]; !ok {
+			return "", fmt.Errorf("%w: %q", ErrUnknownTemplate, id)
+		}
+	}
+	data := Data{
+		Code:          code,
+		ExpiryMinutes: expiryMinutes(expiry),
+		AppName:       r.appName,
+		AppHash:       r.appHash,
+		Domain:        r.domain,
+	}
+	resolved := resolve(set, locale, r.defaultLocale)
+
+	var b strings.Builder
+	if err := set[resolved].Execute(&b, data); err != nil {
+		return "", fmt.Errorf("render template %q: %w", resolved, err)
+	}
+	text := strings.TrimSpace(b.String())
+
+	var suffix []string
+	if r.appHash != "" && !strings.Contains(text, r.appHash) {
+		suffix = append(suffix, r.appHash)
+	}
+	if r.domain != "" {
+		suffix = append(suffix, "@"+r.domain+" #"+code)
+	}
+	if len(suffix) > 0 {
+		text += "\n\n" + strings.Join(suffix, "\n")
+	}
+	return text, nil
+}
+
+// NormalizeLocale lower-cases a BCP 47 tag and uses "-" as separator, "ru_RU" -> "ru-ru".
+func NormalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
+
+func expiryMinutes(d time.Duration) int {
+	m := int((d + time.Minute - 1) / time.Minute)
+	if m < 1 {
+		return 1
+	}
+	return m
+}
+
+// Analyze renders every template with a sample code of the given length and
+// returns the encoding analysis, for budget checks at startup. Keys are the
+// locale for default templates and "id/locale" for named ones.
+func (r *Renderer) Analyze(digits int, expiry time.Duration) (map[string]Analysis, error) {
+	code := strings.Repeat("0", digits)
+	out := make(map[string]Analysis, len(r.templates))
+	sets := map[string]map[string]*template.Template{"": r.templates}
+	for id, set := range r.named {
+		sets[id] = set
+	}
+	for id, set := range sets {
+		for locale := range set {
+			text, err := r.RenderTemplate(id, locale, code, expiry)
+			if err != nil {
+				return nil, err
+			}
+			key := locale
+			if id != "" {
+				key = id + "/" + locale
+			}
+			out[key] = Analyze(text)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,99 @@
+package message
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderer_Fallback(t *testing.T) {
+	r, err := NewRenderer(Config{
+		Templates:     map[string]string{"ru": "Код {{.Code}}", "pt-BR": "Código {{.Code}}"},
+		DefaultLocale: "en",
+	})
+	if err != nil {
+		t.Fatalf("NewRenderer error: %v", err)
+	}
+	cases := map[string]string{
+		"ru":    "ru",
+		"ru_RU": "ru",
+		"pt-br": "pt-br",
+		"pt":    "en",
+		"de":    "en",
+		"":      "en",
+	}
+	for in, want := range cases {
+		if got := r.Resolve(in); got != want {
+			t.Errorf("Resolve(%q) = %q; want %q", in, got, want)
+		}
+	}
+
+	got, err := r.Render("ru-RU", "123456", time.Minute)
+	if err != nil {
+		t.Fatalf("Render error: %v", err)
+	}
+	if got != "Код 123456" {
+		t.Errorf("Render = %q; want %q", got, "Код 123456")
+	}
+}
+
+func TestRenderer_DataAndSuffix(t *testing.T) {
+	r, err := NewRenderer(Config{
+		Templates:    map[string]string{"en": "{{.AppName}} code {{.Code}}, {{.ExpiryMinutes}} min"},
+		AppName:      "Acme",
+		AppHash:      "FA+9qCX9VSu",
+		WebOTPDomain: "example.com",
+	})
+	if err != nil {
+		t.Fatalf("NewRenderer error: %v", err)
+	}
+	got, err := r.Render("en", "654321", 90*time.Second)
+	if err != nil {
+		t.Fatalf("Render error: %v", err)
+	}
+	want := "Acme code 654321, 2 min\n\nFA+9qCX9VSu\n@example.com #654321"
+	if got != want {
+		t.Errorf("Render = %q; want %q", got, want)
+	}
+	lines := strings.Split(got, "\n")
+	if last := lines[len(lines)-1]; !strings.HasPrefix(last, "@example.com") {
+		t.Errorf("last line = %q; want WebOTP line", last)
+	}
+}
+
+func TestNewRenderer_InvalidTemplate(t *testing.T) {
+	if _, err := NewRenderer(Config{Templates: map[string]string{"en": "{{.Code"}}); err == nil {
+		t.Errorf("NewRenderer error = nil; want parse error")
+	}
+	if _, err := NewRenderer(Config{DefaultLocale: "de"}); err == nil {
+		t.Errorf("NewRenderer error = nil; want missing default locale error")
+	}
+}
+
+func TestRenderer_NamedTemplates(t *testing.T) {
+	r, err := NewRenderer(Config{
+		Named: map[string]map[string]string{
+			"reset": {"en": "Reset code {{.Code}}", "ru": "Код сброса {{.Code}}"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewRenderer error: %v", err)
+	}
+	cases := map[string]string{
+		"ru-RU": "Код сброса 1234",
+		"de":    "Reset code 1234",
+	}
+	for locale, want := range cases {
+		got, err := r.RenderTemplate("reset", locale, "1234", time.Minute)
+		if err != nil || got != want {
+			t.Errorf("RenderTemplate(reset, %q) = %q, %v; want %q", locale, got, err, want)
+		}
+	}
+	if _, err := r.RenderTemplate("missing", "en", "1234", time.Minute); !errors.Is(err, ErrUnknownTemplate) {
+		t.Errorf("RenderTemplate(missing) error = %v; want ErrUnknownTemplate", err)
+	}
+	if _, err := NewRenderer(Config{Named: map[string]map[string]string{"reset": {"ru": "{{.Code}}"}}}); err == nil {
+		t.Errorf("NewRenderer without default locale error = nil; want error")
+	}
+}
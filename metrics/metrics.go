@@ -0,0 +1,115 @@
+// Package metrics exposes Prometheus metrics for sends, verifications, rate
+// limits, provider and storage latency, and HTTP traffic. Components are
+// instrumented by wrapping them, so the packages being measured stay unaware
+// of Prometheus.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "otp"
+
+// Metrics owns a registry and the collectors registered in it.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	sends           *prometheus.CounterVec
+	verifications   *prometheus.CounterVec
+	rateLimited     *prometheus.CounterVec
+	providerLatency *prometheus.HistogramVec
+	storageLatency  *prometheus.HistogramVec
+	httpRequests    *prometheus.CounterVec
+	httpLatency     *prometheus.HistogramVec
+	httpInFlight    prometheus.Gauge
+}
+
+// New creates the collectors in a dedicated registry, together with the Go runtime and process collectors.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		sends: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "sends_total",
+			Help:      "Messages handed to an SMS provider, by provider, destination country and result.",
+		}, []string{"provider", "country", "result"}),
+		verifications: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "verifications_total",
+			Help:      "Code verifications by result.",
+		}, []string{"result"}),
+		rateLimited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rate_limit_rejections_total",
+			Help:      "Requests refused by a rate limit, by the exceeded limit.",
+		}, []string{"reason"}),
+		providerLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "provider_request_duration_seconds",
+			Help:      "Time an SMS provider took to accept or reject a message.",
+			Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+		}, []string{"provider", "result"}),
+		storageLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "storage_operation_duration_seconds",
+			Help:      "Storage call latency by operation.",
+			Buckets:   []float64{.0001, .0005, .001, .005, .01, .05, .1, .5},
+		}, []string{"operation"}),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "http",
+			Name:      "requests_total",
+			Help:      "HTTP requests by method, route and status code.",
+		}, []string{"method", "route", "status"}),
+		httpLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "http",
+			Name:      "request_duration_seconds",
+			Help:      "HTTP request latency by method and route.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		httpInFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "http",
+			Name:      "requests_in_flight",
+			Help:      "HTTP requests currently being served.",
+		}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.sends, m.verifications, m.rateLimited,
+		m.providerLatency, m.storageLatency,
+		m.httpRequests, m.httpLatency, m.httpInFlight,
+	)
+	return m
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// Middleware records every request. The route label is the matched pattern,
+// e.g. "/admin/keys/:id", so IDs in paths do not create new series;
+// unmatched paths share the "unmatched" label.
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		m.httpInFlight.Inc()
+		defer m.httpInFlight.Dec()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		m.httpRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		m.httpLatency.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,151 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pquerna/otp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/NlightN22/OTPSMSProvider/ratelimit"
+	service "github.com/NlightN22/OTPSMSProvider/service"
+	storage "github.com/NlightN22/OTPSMSProvider/storage"
+)
+
+type stubNotifier struct{ err error }
+
+func (n *stubNotifier) Send(ctx context.Context, to, msg string) (service.SendResult, error) {
+	return service.SendResult{Segments: 1}, n.err
+}
+
+func TestNotifier_CountsSendsByResult(t *testing.T) {
+	m := New()
+	ok := m.NewNotifier(&stubNotifier{}, "smsc")
+	failing := m.NewNotifier(&stubNotifier{err: &service.ProviderError{
+		Provider: "smsc", Kind: service.ErrProviderThrottled, Retryable: true,
+	}}, "smsc")
+
+	ok.Send(context.Background(), "+79991234567", "code")
+	ok.Send(context.Background(), "+79991234568", "code")
+	failing.Send(context.Background(), "+14155552671", "code")
+	ok.Send(context.Background(), "garbage", "code")
+
+	if got := testutil.ToFloat64(m.sends.WithLabelValues("smsc", "RU", "ok")); got != 2 {
+		t.Errorf("RU ok sends = %v; want 2", got)
+	}
+	if got := testutil.ToFloat64(m.sends.WithLabelValues("smsc", "US", "throttled")); got != 1 {
+		t.Errorf("US throttled sends = %v; want 1", got)
+	}
+	if got := testutil.ToFloat64(m.sends.WithLabelValues("smsc", "unknown", "ok")); got != 1 {
+		t.Errorf("unknown-country sends = %v; want 1", got)
+	}
+	if got := testutil.CollectAndCount(m.providerLatency); got != 2 {
+		t.Errorf("provider latency series = %d; want 2 (ok and throttled)", got)
+	}
+}
+
+func TestSendResult(t *testing.T) {
+	cases := map[string]error{
+		"ok":              nil,
+		"invalid_phone":   &service.ProviderError{Kind: service.ErrInvalidPhone},
+		"permanent_error": &service.ProviderError{},
+		"error":           errors.New("boom"),
+	}
+	for want, err := range cases {
+		if got := sendResult(err); got != want {
+			t.Errorf("sendResult(%v) = %q; want %q", err, got, want)
+		}
+	}
+}
+
+func TestService_CountsVerificationsAndIntervalRejections(t *testing.T) {
+	m := New()
+	store := storage.NewMemoryStorage()
+	svc := m.NewService(service.NewTotpService(store, "test", 30, otp.DigitsSix, otp.AlgorithmSHA1, 1,
+		time.Minute, &stubNotifier{}))
+
+	code, err := svc.GenerateCode(context.Background(), "+79991234567", service.SendOptions{})
+	if err != nil {
+		t.Fatalf("GenerateCode error: %v", err)
+	}
+	svc.ValidateCode(context.Background(), "+79991234567", code, service.VerifyOptions{})
+	svc.ValidateCode(context.Background(), "+79991234567", "000000", service.VerifyOptions{})
+	svc.ValidateCode(context.Background(), "+79991234567", "111111", service.VerifyOptions{})
+	svc.CanSend(context.Background(), "+79991234567")
+
+	if got := testutil.ToFloat64(m.verifications.WithLabelValues("valid")); got != 1 {
+		t.Errorf("valid verifications = %v; want 1", got)
+	}
+	if got := testutil.ToFloat64(m.verifications.WithLabelValues("invalid")); got != 2 {
+		t.Errorf("invalid verifications = %v; want 2", got)
+	}
+	if got := testutil.ToFloat64(m.rateLimited.WithLabelValues("interval")); got != 1 {
+		t.Errorf("interval rejections = %v; want 1", got)
+	}
+}
+
+func TestLimiter_CountsRejectionsByReason(t *testing.T) {
+	m := New()
+	l := m.NewLimiter(ratelimit.NewLimiter(storage.NewMemoryStorage(), ratelimit.Limits{
+		IP: ratelimit.Limit{PerMinute: 1, Burst: 1},
+	}))
+	for i := 0; i < 3; i++ {
+		l.Allow("203.0.113.7", "+79991234567")
+	}
+	key := ratelimit.Limit{PerMinute: 1, Burst: 1}
+	l.AllowKey("k1", key)
+	l.AllowKey("k1", key)
+
+	if got := testutil.ToFloat64(m.rateLimited.WithLabelValues(ratelimit.ReasonIP)); got != 2 {
+		t.Errorf("ip rejections = %v; want 2", got)
+	}
+	if got := testutil.ToFloat64(m.rateLimited.WithLabelValues(ratelimit.ReasonKey)); got != 1 {
+		t.Errorf("api_key rejections = %v; want 1", got)
+	}
+}
+
+func TestStorage_TimesOperations(t *testing.T) {
+	m := New()
+	s := m.NewStorage(storage.NewMemoryStorage())
+	s.SaveSecret("k", "v")
+	s.GetSecret("k")
+	s.GetSecret("k")
+
+	if got := testutil.CollectAndCount(m.storageLatency, "otp_storage_operation_duration_seconds"); got != 2 {
+		t.Errorf("storage latency series = %d; want 2", got)
+	}
+}
+
+func TestMiddlewareAndHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := New()
+	r := gin.New()
+	r.Use(m.Middleware())
+	r.GET("/metrics", gin.WrapH(m.Handler()))
+	r.GET("/keys/:id", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	for _, path := range []string{"/keys/a", "/keys/b", "/missing"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	if got := testutil.ToFloat64(m.httpRequests.WithLabelValues("GET", "/keys/:id", "204")); got != 2 {
+		t.Errorf("/keys/:id requests = %v; want 2", got)
+	}
+	if got := testutil.ToFloat64(m.httpRequests.WithLabelValues("GET", "unmatched", "404")); got != 1 {
+		t.Errorf("unmatched requests = %v; want 1", got)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+	for _, name := range []string{"http_requests_total", "http_request_duration_seconds", "go_goroutines"} {
+		if !strings.Contains(body, name) {
+			t.Errorf("/metrics output lacks %s", name)
+		}
+	}
+}
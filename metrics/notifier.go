@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/NlightN22/OTPSMSProvider/phone"
+	service "github.com/NlightN22/OTPSMSProvider/service"
+)
+
+// Notifier counts and times the sends of the wrapped provider.
+type Notifier struct {
+	next     service.Notifier
+	provider string
+	m        *Metrics
+}
+
+// NewNotifier wraps next, labelling its metrics with provider.
+func (m *Metrics) NewNotifier(next service.Notifier, provider string) *Notifier {
+	return &Notifier{next: next, provider: provider, m: m}
+}
+
+// Send delegates to the wrapped notifier and records the outcome.
+func (n *Notifier) Send(ctx context.Context, to, msg string) (service.SendResult, error) {
+	start := time.Now()
+	res, err := n.next.Send(ctx, to, msg)
+	result := sendResult(err)
+	country := phone.RegionOf(to)
+	if country == "" {
+		country = "unknown"
+	}
+	n.m.sends.WithLabelValues(n.provider, country, result).Inc()
+	n.m.providerLatency.WithLabelValues(n.provider, result).Observe(time.Since(start).Seconds())
+	return res, err
+}
+
+// Close closes the wrapped notifier if it holds resources.
+func (n *Notifier) Close() error {
+	if c, ok := n.next.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// sendResults maps provider failure kinds to result labels, most specific first.
+var sendResults = []struct {
+	kind  error
+	label string
+}{
+	{service.ErrInvalidPhone, "invalid_phone"},
+	{service.ErrUndeliverable, "undeliverable"},
+	{service.ErrInsufficientFunds, "insufficient_funds"},
+	{service.ErrProviderAuth, "auth_failed"},
+	{service.ErrMessageRejected, "rejected"},
+	{service.ErrInvalidRequest, "invalid_request"},
+	{service.ErrProviderThrottled, "throttled"},
+	{service.ErrProviderUnavailable, "unavailable"},
+	{service.ErrProviderRetryable, "retryable_error"},
+	{service.ErrProviderPermanent, "permanent_error"},
+}
+
+// sendResult returns a bounded label for a send outcome.
+func sendResult(err error) string {
+	if err == nil {
+		return "ok"
+	}
+	for _, r := range sendResults {
+		if errors.Is(err, r.kind) {
+			return r.label
+		}
+	}
+	return "error"
+}
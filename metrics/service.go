@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/NlightN22/OTPSMSProvider/ratelimit"
+	service "github.com/NlightN22/OTPSMSProvider/service"
+)
+
+// Service counts verification results and send-interval rejections of the wrapped OTPService.
+type Service struct {
+	next service.OTPService
+	m    *Metrics
+}
+
+// NewService wraps next.
+func (m *Metrics) NewService(next service.OTPService) *Service {
+	return &Service{next: next, m: m}
+}
+
+func (s *Service) GenerateCode(ctx context.Context, key string, opts service.SendOptions) (string, error) {
+	return s.next.GenerateCode(ctx, key, opts)
+}
+
+func (s *Service) ValidateCode(ctx context.Context, key, code string, opts service.VerifyOptions) bool {
+	ok := s.next.ValidateCode(ctx, key, code, opts)
+	result := "invalid"
+	if ok {
+		result = "valid"
+	}
+	s.m.verifications.WithLabelValues(result).Inc()
+	return ok
+}
+
+// CanSend counts a refusal as a rate-limit rejection with reason "interval".
+func (s *Service) CanSend(ctx context.Context, key string) (bool, time.Duration) {
+	ok, wait := s.next.CanSend(ctx, key)
+	if !ok {
+		s.m.rateLimited.WithLabelValues("interval").Inc()
+	}
+	return ok, wait
+}
+
+// Limiter counts the rejections of the wrapped rate limiter by reason.
+type Limiter struct {
+	next *ratelimit.Limiter
+	m    *Metrics
+}
+
+// NewLimiter wraps next.
+func (m *Metrics) NewLimiter(next *ratelimit.Limiter) *Limiter {
+	return &Limiter{next: next, m: m}
+}
+
+func (l *Limiter) Allow(ip, phone string) (bool, string, time.Duration) {
+	ok, reason, wait := l.next.Allow(ip, phone)
+	if !ok {
+		l.m.rateLimited.WithLabelValues(reason).Inc()
+	}
+	return ok, reason, wait
+}
+
+func (l *Limiter) AllowKey(id string, limit ratelimit.Limit) (bool, time.Duration) {
+	ok, wait := l.next.AllowKey(id, limit)
+	if !ok {
+		l.m.rateLimited.WithLabelValues(ratelimit.ReasonKey).Inc()
+	}
+	return ok, wait
+}
@@ -0,0 +1,159 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	storage "github.com/NlightN22/OTPSMSProvider/storage"
+)
+
+// Storage times every call to the wrapped storage.
+type Storage struct {
+	next storage.Storage
+	m    *Metrics
+}
+
+var _ storage.Storage = (*Storage)(nil)
+
+// NewStorage wraps next.
+func (m *Metrics) NewStorage(next storage.Storage) *Storage {
+	return &Storage{next: next, m: m}
+}
+
+func (s *Storage) observe(op string, start time.Time) {
+	s.m.storageLatency.WithLabelValues(op).Observe(time.Since(start).Seconds())
+}
+
+func (s *Storage) GetSecret(key string) (string, bool) {
+	defer s.observe("get_secret", time.Now())
+	return s.next.GetSecret(key)
+}
+
+func (s *Storage) SaveSecret(key, secret string) {
+	defer s.observe("save_secret", time.Now())
+	s.next.SaveSecret(key, secret)
+}
+
+func (s *Storage) GetLastSend(key string) (time.Time, bool) {
+	defer s.observe("get_last_send", time.Now())
+	return s.next.GetLastSend(key)
+}
+
+func (s *Storage) SaveLastSend(key string, t time.Time) {
+	defer s.observe("save_last_send", time.Now())
+	s.next.SaveLastSend(key, t)
+}
+
+func (s *Storage) SaveDelivery(key string, d storage.Delivery) {
+	defer s.observe("save_delivery", time.Now())
+	s.next.SaveDelivery(key, d)
+}
+
+func (s *Storage) GetDeliveries(key string) []storage.Delivery {
+	defer s.observe("get_deliveries", time.Now())
+	return s.next.GetDeliveries(key)
+}
+
+func (s *Storage) UpdateBucket(key string, fn func(b storage.Bucket, ok bool) storage.Bucket) storage.Bucket {
+	defer s.observe("update_bucket", time.Now())
+	return s.next.UpdateBucket(key, fn)
+}
+
+func (s *Storage) DeleteSecret(key string) bool {
+	defer s.observe("delete_secret", time.Now())
+	return s.next.DeleteSecret(key)
+}
+
+func (s *Storage) SecretKeys(prefix string) []string {
+	defer s.observe("secret_keys", time.Now())
+	return s.next.SecretKeys(prefix)
+}
+
+func (s *Storage) DeleteLastSend(key string) bool {
+	defer s.observe("delete_last_send", time.Now())
+	return s.next.DeleteLastSend(key)
+}
+
+func (s *Storage) UpdateAttempts(key string, fn func(a storage.Attempts, ok bool) storage.Attempts) storage.Attempts {
+	defer s.observe("update_attempts", time.Now())
+	return s.next.UpdateAttempts(key, fn)
+}
+
+func (s *Storage) GetAttempts(key string) (storage.Attempts, bool) {
+	defer s.observe("get_attempts", time.Now())
+	return s.next.GetAttempts(key)
+}
+
+func (s *Storage) AddSpend(period string, amount float64) float64 {
+	defer s.observe("add_spend", time.Now())
+	return s.next.AddSpend(period, amount)
+}
+
+func (s *Storage) GetSpend(period string) float64 {
+	defer s.observe("get_spend", time.Now())
+	return s.next.GetSpend(period)
+}
+
+func (s *Storage) AddListEntry(list, entry string) {
+	defer s.observe("add_list_entry", time.Now())
+	s.next.AddListEntry(list, entry)
+}
+
+func (s *Storage) DeleteListEntry(list, entry string) bool {
+	defer s.observe("delete_list_entry", time.Now())
+	return s.next.DeleteListEntry(list, entry)
+}
+
+func (s *Storage) GetListEntries(list string) []string {
+	defer s.observe("get_list_entries", time.Now())
+	return s.next.GetListEntries(list)
+}
+
+func (s *Storage) SaveAPIKey(k storage.APIKey) {
+	defer s.observe("save_api_key", time.Now())
+	s.next.SaveAPIKey(k)
+}
+
+func (s *Storage) GetAPIKey(id string) (storage.APIKey, bool) {
+	defer s.observe("get_api_key", time.Now())
+	return s.next.GetAPIKey(id)
+}
+
+func (s *Storage) DeleteAPIKey(id string) bool {
+	defer s.observe("delete_api_key", time.Now())
+	return s.next.DeleteAPIKey(id)
+}
+
+func (s *Storage) ListAPIKeys() []storage.APIKey {
+	defer s.observe("list_api_keys", time.Now())
+	return s.next.ListAPIKeys()
+}
+
+func (s *Storage) UseNonce(nonce string, expires time.Time) bool {
+	defer s.observe("use_nonce", time.Now())
+	return s.next.UseNonce(nonce, expires)
+}
+
+func (s *Storage) AppendAudit(r storage.AuditRecord) {
+	defer s.observe("append_audit", time.Now())
+	s.next.AppendAudit(r)
+}
+
+func (s *Storage) LastAudit() (storage.AuditRecord, bool) {
+	defer s.observe("last_audit", time.Now())
+	return s.next.LastAudit()
+}
+
+func (s *Storage) ListAudit(after uint64, limit int) []storage.AuditRecord {
+	defer s.observe("list_audit", time.Now())
+	return s.next.ListAudit(after, limit)
+}
+
+func (s *Storage) Ping(ctx context.Context) error {
+	defer s.observe("ping", time.Now())
+	return s.next.Ping(ctx)
+}
+
+func (s *Storage) Close() error {
+	return s.next.Close()
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	logger "github.com/NlightN22/OTPSMSProvider/pkg"
+)
+
+type AccessLogMiddleware struct {
+	log *zap.SugaredLogger
+}
+
+func NewAccessLogMiddleware(log *zap.SugaredLogger) *AccessLogMiddleware {
+	return &AccessLogMiddleware{log: log}
+}
+
+// Handler writes one structured line per request once it is served, at warn
+// level for 5xx and info otherwise. Register it after the request ID
+// middleware so the line carries the ID.
+func (m *AccessLogMiddleware) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		fields := []any{
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"route", c.FullPath(),
+			"status", status,
+			"duration_ms", float64(time.Since(start).Microseconds()) / 1000,
+			"bytes", c.Writer.Size(),
+			"client_ip", c.ClientIP(),
+			"user_agent", c.Request.UserAgent(),
+		}
+		if key, ok := APIKeyFrom(c); ok {
+			fields = append(fields, "api_key", key.ID)
+		}
+		if len(c.Errors) > 0 {
+			fields = append(fields, "errors", c.Errors.String())
+		}
+
+		log := logger.For(c.Request.Context(), m.log)
+		if status >= 500 {
+			log.Warnw("HTTP request", fields...)
+			return
+		}
+		log.Infow("HTTP request", fields...)
+	}
+}
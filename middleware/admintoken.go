@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminTokenMiddleware guards operator endpoints with a shared bearer token.
+type AdminTokenMiddleware struct {
+	token []byte
+}
+
+func NewAdminTokenMiddleware(token string) *AdminTokenMiddleware {
+	return &AdminTokenMiddleware{token: []byte(token)}
+}
+
+// Handler accepts requests carrying "Authorization: Bearer <token>".
+func (m *AdminTokenMiddleware) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		got, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), m.token) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		c.Next()
+	}
+}
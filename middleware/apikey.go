@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"crypto/x509"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/NlightN22/OTPSMSProvider/apikey"
+	"github.com/NlightN22/OTPSMSProvider/ratelimit"
+)
+
+// APIKeyHeader carries the key when the Authorization header is not used.
+const APIKeyHeader = "X-API-Key"
+
+// apiKeyContextKey stores the authenticated apikey.Key in the gin context.
+const apiKeyContextKey = "apikey"
+
+// KeyAuthenticator resolves a token to its key.
+type KeyAuthenticator interface {
+	Authenticate(token string) (apikey.Key, error)
+}
+
+// KeyLimiter applies per-key rate limits.
+type KeyLimiter interface {
+	AllowKey(id string, limit ratelimit.Limit) (bool, time.Duration)
+}
+
+// CertAuthenticator maps a verified client certificate to a key.
+type CertAuthenticator interface {
+	AuthenticateCert(cert *x509.Certificate) (apikey.Key, bool)
+}
+
+type APIKeyMiddleware struct {
+	auth    KeyAuthenticator
+	limiter KeyLimiter
+	certs   CertAuthenticator
+}
+
+// APIKeyOption configures an APIKeyMiddleware.
+type APIKeyOption func(*APIKeyMiddleware)
+
+// WithClientCerts accepts mapped mTLS client certificates in place of a token.
+func WithClientCerts(c CertAuthenticator) APIKeyOption {
+	return func(m *APIKeyMiddleware) {
+		m.certs = c
+	}
+}
+
+// NewAPIKeyMiddleware creates the middleware. limiter may be nil to ignore per-key limits.
+func NewAPIKeyMiddleware(auth KeyAuthenticator, limiter KeyLimiter, opts ...APIKeyOption) *APIKeyMiddleware {
+	m := &APIKeyMiddleware{auth: auth, limiter: limiter}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Require rejects requests without a valid key granted scope.
+func (m *APIKeyMiddleware) Require(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key, ok := m.clientCertKey(c)
+		if !ok {
+			token := bearerToken(c.GetHeader("Authorization"))
+			if token == "" {
+				token = c.GetHeader(APIKeyHeader)
+			}
+			if token == "" {
+				c.Header("WWW-Authenticate", "Bearer")
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": "API key required"})
+				return
+			}
+			var err error
+			if key, err = m.auth.Authenticate(token); err != nil {
+				msg := "Invalid API key"
+				if errors.Is(err, apikey.ErrRevoked) {
+					msg = "API key revoked"
+				}
+				c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized", "message": msg})
+				return
+			}
+		}
+		if !key.HasScope(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "insufficient_scope", "message": "API key lacks scope " + scope})
+			return
+		}
+		if m.limiter != nil {
+			if ok, wait := m.limiter.AllowKey(key.ID, ratelimit.Limit{PerMinute: key.PerMinute, Burst: key.Burst}); !ok {
+				secs := int(math.Max(1, math.Ceil(wait.Seconds())))
+				c.Header("Retry-After", strconv.Itoa(secs))
+				c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+					"error":               "rate_limited",
+					"message":             "API key rate limit exceeded",
+					"reason":              ratelimit.ReasonKey,
+					"retry_after_seconds": secs,
+				})
+				return
+			}
+		}
+		c.Set(apiKeyContextKey, key)
+		c.Next()
+	}
+}
+
+// clientCertKey maps the verified client certificate of the connection, if any.
+func (m *APIKeyMiddleware) clientCertKey(c *gin.Context) (apikey.Key, bool) {
+	if m.certs == nil || c.Request.TLS == nil || len(c.Request.TLS.VerifiedChains) == 0 {
+		return apikey.Key{}, false
+	}
+	return m.certs.AuthenticateCert(c.Request.TLS.VerifiedChains[0][0])
+}
+
+// APIKeyFrom returns the key that authenticated the request, if any.
+func APIKeyFrom(c *gin.Context) (apikey.Key, bool) {
+	v, ok := c.Get(apiKeyContextKey)
+	if !ok {
+		return apikey.Key{}, false
+	}
+	k, ok := v.(apikey.Key)
+	return k, ok
+}
+
+func bearerToken(header string) string {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
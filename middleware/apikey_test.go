@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/NlightN22/OTPSMSProvider/apikey"
+	"github.com/NlightN22/OTPSMSProvider/ratelimit"
+	storage "github.com/NlightN22/OTPSMSProvider/storage"
+)
+
+func newAPIKeyRouter(t *testing.T, key apikey.Key) (*gin.Engine, string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	store := storage.NewMemoryStorage()
+	m, err := apikey.NewManager(store, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := m.Mint(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mw := NewAPIKeyMiddleware(m, ratelimit.NewLimiter(store, ratelimit.Limits{}))
+
+	router := gin.New()
+	router.GET("/send", mw.Require(apikey.ScopeSend), func(c *gin.Context) {
+		k, _ := APIKeyFrom(c)
+		c.String(http.StatusOK, k.Name)
+	})
+	return router, token
+}
+
+func TestAPIKeyMiddleware_Auth(t *testing.T) {
+	router, token := newAPIKeyRouter(t, apikey.Key{Name: "shop", Scopes: []string{apikey.ScopeSend}})
+
+	cases := []struct {
+		name   string
+		header string
+		value  string
+		status int
+	}{
+		{"missing", "", "", http.StatusUnauthorized},
+		{"bearer", "Authorization", "Bearer " + token, http.StatusOK},
+		{"header", APIKeyHeader, token, http.StatusOK},
+		{"wrong", APIKeyHeader, token + "0", http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest("GET", "/send", nil)
+		if tc.header != "" {
+			req.Header.Set(tc.header, tc.value)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, tc.status, w.Code, tc.name)
+		if tc.status == http.StatusOK {
+			assert.Equal(t, "shop", w.Body.String(), tc.name)
+		}
+	}
+}
+
+func TestAPIKeyMiddleware_ScopeAndLimit(t *testing.T) {
+	router, token := newAPIKeyRouter(t, apikey.Key{Name: "crm", Scopes: []string{apikey.ScopeVerify}})
+	req := httptest.NewRequest("GET", "/send", nil)
+	req.Header.Set(APIKeyHeader, token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	router, token = newAPIKeyRouter(t, apikey.Key{Name: "shop", Scopes: []string{apikey.ScopeSend}, PerMinute: 1, Burst: 1})
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		req := httptest.NewRequest("GET", "/send", nil)
+		req.Header.Set(APIKeyHeader, token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, want, w.Code, "request %d", i+1)
+		if want == http.StatusTooManyRequests {
+			assert.Equal(t, "60", w.Header().Get("Retry-After"))
+		}
+	}
+}
+
+type stubCerts map[string]apikey.Key
+
+func (s stubCerts) AuthenticateCert(cert *x509.Certificate) (apikey.Key, bool) {
+	k, ok := s[cert.Subject.CommonName]
+	return k, ok
+}
+
+func TestAPIKeyMiddleware_ClientCertificate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m, err := apikey.NewManager(storage.NewMemoryStorage(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certs := stubCerts{"shop-backend": {ID: "cert:shop", Name: "shop", Scopes: []string{apikey.ScopeSend}}}
+	mw := NewAPIKeyMiddleware(m, nil, WithClientCerts(certs))
+	router := gin.New()
+	router.GET("/send", mw.Require(apikey.ScopeSend), func(c *gin.Context) {
+		k, _ := APIKeyFrom(c)
+		c.String(http.StatusOK, k.Name)
+	})
+
+	for cn, want := range map[string]int{"shop-backend": http.StatusOK, "unknown": http.StatusUnauthorized} {
+		req := httptest.NewRequest("GET", "/send", nil)
+		req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: cn}}}}}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, want, w.Code, cn)
+	}
+}
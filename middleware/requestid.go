@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	logger "github.com/NlightN22/OTPSMSProvider/pkg"
+)
+
+// RequestIDHeader carries the request ID in both directions.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestID caps the length of an accepted incoming ID.
+const maxRequestID = 128
+
+type RequestIDMiddleware struct{}
+
+func NewRequestIDMiddleware() *RequestIDMiddleware {
+	return &RequestIDMiddleware{}
+}
+
+// Handler reuses a well-formed incoming X-Request-ID or generates one, echoes
+// it in the response and stores it in the request context for logger.For.
+func (m *RequestIDMiddleware) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(logger.WithRequestID(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// validRequestID accepts IDs made of letters, digits and "-_.:", so a caller
+// cannot inject separators or control characters into the logs.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestID {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// randRead is swapped in tests to simulate an entropy failure.
+var randRead = rand.Read
+
+// fallbackSeq numbers the IDs generated while crypto/rand fails. The process
+// start time in front keeps them apart across restarts.
+var (
+	fallbackSeq   atomic.Uint64
+	fallbackStart = strconv.FormatInt(time.Now().UnixNano(), 36)
+)
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := randRead(b); err != nil {
+		return fallbackStart + "-" + strconv.FormatUint(fallbackSeq.Add(1), 10)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	logger "github.com/NlightN22/OTPSMSProvider/pkg"
+)
+
+func newRequestIDRouter(base *zap.SugaredLogger, seen *string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(NewRequestIDMiddleware().Handler(), NewAccessLogMiddleware(base).Handler())
+	r.GET("/items/:id", func(c *gin.Context) {
+		*seen = logger.RequestID(c.Request.Context())
+		logger.For(c.Request.Context(), base).Infow("handler")
+		c.Status(http.StatusNoContent)
+	})
+	return r
+}
+
+func TestRequestID_KeepsValidIncomingID(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	var seen string
+	r := newRequestIDRouter(zap.New(core).Sugar(), &seen)
+
+	req := httptest.NewRequest("GET", "/items/7", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if got := w.Header().Get(RequestIDHeader); got != "abc-123" || seen != "abc-123" {
+		t.Errorf("response ID = %q, context ID = %q; want abc-123", got, seen)
+	}
+	entries := logs.All()
+	if len(entries) != 2 {
+		t.Fatalf("log entries = %d; want handler and access lines", len(entries))
+	}
+	for _, e := range entries {
+		if got := e.ContextMap()["request_id"]; got != "abc-123" {
+			t.Errorf("%q request_id = %v; want abc-123", e.Message, got)
+		}
+	}
+	access := entries[1].ContextMap()
+	if access["route"] != "/items/:id" || access["status"] != int64(http.StatusNoContent) {
+		t.Errorf("access log = %v; want route /items/:id and status 204", access)
+	}
+}
+
+func TestRequestID_ReplacesInvalidIncomingID(t *testing.T) {
+	core, _ := observer.New(zap.InfoLevel)
+	var seen string
+	r := newRequestIDRouter(zap.New(core).Sugar(), &seen)
+
+	for _, bad := range []string{"", "has space", "line\nbreak", strings.Repeat("a", maxRequestID+1)} {
+		req := httptest.NewRequest("GET", "/items/7", nil)
+		if bad != "" {
+			req.Header[RequestIDHeader] = []string{bad}
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		got := w.Header().Get(RequestIDHeader)
+		if got == bad || len(got) != 32 || seen != got {
+			t.Errorf("incoming %q: ID = %q, context %q; want a generated 32-char ID", bad, got, seen)
+		}
+	}
+}
+
+func TestNewRequestID_FallsBackWithoutEntropy(t *testing.T) {
+	defer func(orig func([]byte) (int, error)) { randRead = orig }(randRead)
+	randRead = func([]byte) (int, error) { return 0, errors.New("no entropy") }
+
+	a, b := newRequestID(), newRequestID()
+	if a == b || !validRequestID(a) || !validRequestID(b) {
+		t.Errorf("fallback IDs = %q, %q; want distinct valid IDs", a, b)
+	}
+}
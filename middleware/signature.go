@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/NlightN22/OTPSMSProvider/signing"
+)
+
+// maxSignedBody caps how much of a request body is read for the digest.
+const maxSignedBody = 1 << 20
+
+// RequestVerifier checks signed requests.
+type RequestVerifier interface {
+	Verify(method, pathQuery string, body []byte, h signing.Headers) error
+}
+
+type SignatureMiddleware struct {
+	verifier RequestVerifier
+}
+
+func NewSignatureMiddleware(v RequestVerifier) *SignatureMiddleware {
+	return &SignatureMiddleware{verifier: v}
+}
+
+// Handler rejects requests without a valid HMAC signature. The body is restored for the next handlers.
+func (m *SignatureMiddleware) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxSignedBody+1))
+		if err != nil || len(body) > maxSignedBody {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "invalid_request", "message": "Request body too large"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		h := signing.Headers{
+			KeyID:     c.GetHeader(signing.HeaderKeyID),
+			Timestamp: c.GetHeader(signing.HeaderTimestamp),
+			Nonce:     c.GetHeader(signing.HeaderNonce),
+			Digest:    c.GetHeader(signing.HeaderDigest),
+			Signature: c.GetHeader(signing.HeaderSignature),
+		}
+		if err := m.verifier.Verify(c.Request.Method, c.Request.URL.RequestURI(), body, h); err != nil {
+			code := "invalid_signature"
+			if errors.Is(err, signing.ErrReplay) {
+				code = "replayed_request"
+			}
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": code, "message": err.Error()})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/NlightN22/OTPSMSProvider/signing"
+	storage "github.com/NlightN22/OTPSMSProvider/storage"
+)
+
+func TestSignatureMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	secret := strings.Repeat("s", 32)
+	v, err := signing.NewVerifier(storage.NewMemoryStorage(), map[string]string{"shop": secret}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := gin.New()
+	router.POST("/send", NewSignatureMiddleware(v).Handler(), func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, string(body))
+	})
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	client := &http.Client{Transport: &signing.Transport{Signer: signing.NewSigner("shop", secret)}}
+	resp, err := client.Post(srv.URL+"/send", "application/json", strings.NewReader(`{"phone":"+79991234567"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `{"phone":"+79991234567"}`, string(body), "body must reach the handler")
+
+	resp, err = http.Post(srv.URL+"/send", "application/json", strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
@@ -1,38 +1,194 @@
 package middleware
 
 import (
-	"net"
+	"fmt"
 	"net/http"
+	"net/netip"
+	"sort"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
+// WhitelistOption configures a WhitelistMiddleware or RouteWhitelist.
+type WhitelistOption func(*whitelistOptions)
+
+type whitelistOptions struct {
+	loopback bool
+}
+
+// WithLoopback sets whether loopback clients pass without being listed. Defaults to true.
+func WithLoopback(allow bool) WhitelistOption {
+	return func(o *whitelistOptions) {
+		o.loopback = allow
+	}
+}
+
+func applyWhitelistOptions(opts []WhitelistOption) whitelistOptions {
+	o := whitelistOptions{loopback: true}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// ParseWhitelist parses IPv4/IPv6 addresses and CIDR ranges.
+func ParseWhitelist(entries []string) ([]netip.Prefix, error) {
+	out := make([]netip.Prefix, 0, len(entries))
+	for _, e := range entries {
+		e = strings.TrimSpace(e)
+		if e == "" {
+			continue
+		}
+		if strings.Contains(e, "/") {
+			p, err := netip.ParsePrefix(e)
+			if err != nil {
+				return nil, fmt.Errorf("whitelist entry %q: %w", e, err)
+			}
+			out = append(out, p.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(e)
+		if err != nil {
+			return nil, fmt.Errorf("whitelist entry %q: %w", e, err)
+		}
+		addr = addr.Unmap()
+		out = append(out, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return out, nil
+}
+
+// ipList is a parsed whitelist. An empty list allows everyone.
+type ipList struct {
+	prefixes []netip.Prefix
+	// closed is set when entries were configured but none parsed, so a typo denies instead of opening access.
+	closed   bool
+	loopback bool
+}
+
+func newIPList(entries []string, o whitelistOptions) ipList {
+	l := ipList{loopback: o.loopback}
+	for _, e := range entries {
+		if strings.TrimSpace(e) == "" {
+			continue
+		}
+		if p, err := ParseWhitelist([]string{e}); err == nil {
+			l.prefixes = append(l.prefixes, p...)
+		} else {
+			l.closed = true
+		}
+	}
+	return l
+}
+
+// open reports whether the list lets every client through.
+func (l ipList) open() bool {
+	return len(l.prefixes) == 0 && !l.closed
+}
+
+func (l ipList) allows(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return l.open()
+	}
+	addr = addr.Unmap()
+	if l.loopback && addr.IsLoopback() {
+		return true
+	}
+	if l.open() {
+		return true
+	}
+	for _, p := range l.prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
 type WhitelistMiddleware struct {
-	allowed []string
+	list ipList
 }
 
-func NewWhitelistMiddleware(allowed []string) *WhitelistMiddleware {
-	return &WhitelistMiddleware{allowed: allowed}
+// NewWhitelistMiddleware allows the listed addresses and CIDR ranges. Entries
+// that do not parse never match; check them with ParseWhitelist at startup.
+func NewWhitelistMiddleware(allowed []string, opts ...WhitelistOption) *WhitelistMiddleware {
+	return &WhitelistMiddleware{list: newIPList(allowed, applyWhitelistOptions(opts))}
 }
 
 func (m *WhitelistMiddleware) Handler() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ip := c.ClientIP()
-		if parsed := net.ParseIP(ip); parsed != nil && parsed.IsLoopback() {
+		if m.list.allows(c.ClientIP()) {
 			c.Next()
 			return
 		}
-		if len(m.allowed) == 0 {
-			c.Next()
-			return
+		denyIP(c)
+	}
+}
+
+// denyIP rejects a client outside a whitelist.
+func denyIP(c *gin.Context) {
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "ip_not_allowed", "message": "IP not allowed"})
+}
+
+// RouteWhitelist applies extra whitelists to path prefixes, e.g. a stricter one for /admin.
+// The longest matching prefix wins; paths without a match pass. An empty list
+// lets every client through, which exempts a sub-path from its parent's list.
+type RouteWhitelist struct {
+	routes []routeList
+}
+
+type routeList struct {
+	prefix string
+	list   ipList
+}
+
+// NewRouteWhitelist creates a RouteWhitelist from path prefix -> entries.
+func NewRouteWhitelist(routes map[string][]string, opts ...WhitelistOption) (*RouteWhitelist, error) {
+	o := applyWhitelistOptions(opts)
+	rw := &RouteWhitelist{}
+	for prefix, entries := range routes {
+		if !strings.HasPrefix(prefix, "/") {
+			return nil, fmt.Errorf("route whitelist %q: path must start with /", prefix)
+		}
+		if _, err := ParseWhitelist(entries); err != nil {
+			return nil, fmt.Errorf("route whitelist %q: %w", prefix, err)
 		}
-		for _, w := range m.allowed {
-			if strings.TrimSpace(w) == ip {
-				c.Next()
+		rw.routes = append(rw.routes, routeList{prefix: prefix, list: newIPList(entries, o)})
+	}
+	sort.Slice(rw.routes, func(i, j int) bool { return len(rw.routes[i].prefix) > len(rw.routes[j].prefix) })
+	return rw, nil
+}
+
+// Covers reports whether a non-empty list guards path.
+func (rw *RouteWhitelist) Covers(path string) bool {
+	for _, r := range rw.routes {
+		if matchPathPrefix(path, r.prefix) {
+			return !r.list.open()
+		}
+	}
+	return false
+}
+
+func (rw *RouteWhitelist) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		for _, r := range rw.routes {
+			if !matchPathPrefix(path, r.prefix) {
+				continue
+			}
+			if !r.list.allows(c.ClientIP()) {
+				denyIP(c)
 				return
 			}
+			break
 		}
-		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "IP not allowed"})
+		c.Next()
 	}
 }
+
+// matchPathPrefix matches whole path segments, so "/admin" covers "/admin/keys" but not "/administrator".
+func matchPathPrefix(path, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return path == prefix || strings.HasPrefix(path, prefix+"/") || prefix == ""
+}
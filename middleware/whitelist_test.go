@@ -44,6 +44,7 @@ func TestWhitelistMiddleware_BlocksOtherIP(t *testing.T) {
 
 	router.ServeHTTP(w, req)
 	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.JSONEq(t, `{"error":"ip_not_allowed","message":"IP not allowed"}`, w.Body.String())
 }
 
 func TestWhitelistMiddleware_AllowsLoopbackEvenIfNotListed(t *testing.T) {
@@ -64,3 +65,97 @@ func TestWhitelistMiddleware_AllowsLoopbackEvenIfNotListed(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.Equal(t, "ok", w.Body.String())
 }
+
+func whitelistStatus(t *testing.T, router *gin.Engine, path, remote string, header map[string]string) int {
+	t.Helper()
+	req := httptest.NewRequest("GET", path, nil)
+	req.RemoteAddr = remote
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestWhitelistMiddleware_CIDRAndIPv6(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(NewWhitelistMiddleware([]string{"10.1.0.0/16", "2001:db8::/32", "192.0.2.7"}).Handler())
+	router.GET("/", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+	assert.Equal(t, http.StatusOK, whitelistStatus(t, router, "/", "10.1.200.3:1000", nil))
+	assert.Equal(t, http.StatusForbidden, whitelistStatus(t, router, "/", "10.2.0.1:1000", nil))
+	assert.Equal(t, http.StatusOK, whitelistStatus(t, router, "/", "[2001:db8::1]:1000", nil))
+	assert.Equal(t, http.StatusForbidden, whitelistStatus(t, router, "/", "[2001:db9::1]:1000", nil))
+	assert.Equal(t, http.StatusOK, whitelistStatus(t, router, "/", "192.0.2.7:1000", nil))
+}
+
+func TestWhitelistMiddleware_InvalidEntryDenies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(NewWhitelistMiddleware([]string{"10.0.0.0/33"}, WithLoopback(false)).Handler())
+	router.GET("/", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+	assert.Equal(t, http.StatusForbidden, whitelistStatus(t, router, "/", "192.168.1.1:1000", nil))
+	_, err := ParseWhitelist([]string{"10.0.0.0/33"})
+	assert.Error(t, err)
+}
+
+func TestWhitelistMiddleware_LoopbackDisabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(NewWhitelistMiddleware([]string{"10.0.0.1"}, WithLoopback(false)).Handler())
+	router.GET("/", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+	assert.Equal(t, http.StatusForbidden, whitelistStatus(t, router, "/", "127.0.0.1:1000", nil))
+	assert.Equal(t, http.StatusForbidden, whitelistStatus(t, router, "/", "[::1]:1000", nil))
+}
+
+func TestWhitelistMiddleware_TrustedProxies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	if err := router.SetTrustedProxies([]string{"172.16.0.0/12"}); err != nil {
+		t.Fatal(err)
+	}
+	router.Use(NewWhitelistMiddleware([]string{"203.0.113.5"}, WithLoopback(false)).Handler())
+	router.GET("/", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+	spoofed := map[string]string{"X-Forwarded-For": "203.0.113.5"}
+	assert.Equal(t, http.StatusOK, whitelistStatus(t, router, "/", "172.16.0.2:1000", spoofed), "forwarded by trusted proxy")
+	assert.Equal(t, http.StatusForbidden, whitelistStatus(t, router, "/", "198.51.100.1:1000", spoofed), "header from untrusted peer")
+}
+
+func TestRouteWhitelist(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rw, err := NewRouteWhitelist(map[string][]string{
+		"/admin":      {"10.0.0.1"},
+		"/admin/open": {},
+	}, WithLoopback(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := gin.New()
+	router.Use(rw.Handler())
+	for _, p := range []string{"/send", "/admin/keys", "/admin/open/x", "/administrator"} {
+		router.GET(p, func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+	}
+
+	assert.Equal(t, http.StatusOK, whitelistStatus(t, router, "/send", "192.168.1.1:1000", nil))
+	assert.Equal(t, http.StatusForbidden, whitelistStatus(t, router, "/admin/keys", "192.168.1.1:1000", nil))
+	assert.Equal(t, http.StatusOK, whitelistStatus(t, router, "/admin/keys", "10.0.0.1:1000", nil))
+	assert.Equal(t, http.StatusOK, whitelistStatus(t, router, "/admin/open/x", "192.168.1.1:1000", nil))
+	assert.Equal(t, http.StatusOK, whitelistStatus(t, router, "/administrator", "192.168.1.1:1000", nil))
+	assert.True(t, rw.Covers("/admin/keys"))
+	assert.False(t, rw.Covers("/metrics"))
+	assert.False(t, rw.Covers("/admin/open/x"), "empty list")
+
+	req := httptest.NewRequest("GET", "/admin/keys", nil)
+	req.RemoteAddr = "192.168.1.1:1000"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.JSONEq(t, `{"error":"ip_not_allowed","message":"IP not allowed"}`, w.Body.String())
+
+	_, err = NewRouteWhitelist(map[string][]string{"admin": {"10.0.0.1"}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,73 @@
+package phone
+
+// region holds the numbering plan of one country. Prefixes apply to the
+// national significant number, i.e. without country code and trunk prefix.
+type region struct {
+	iso      string
+	cc       string   // country calling code
+	trunk    string   // national (trunk) prefix dialled before the number, "" if none
+	lengths  []int    // valid lengths of the national significant number
+	leading  []string // prefixes that select this region when cc is shared, nil for the main region
+	mobile   []string
+	fixed    []string
+	tollFree []string
+	premium  []string
+	shared   []string // numbers that may be fixed or mobile, e.g. the NANP
+}
+
+// regions covers the countries we serve. Numbers of other countries are
+// accepted when they have a valid E.164 length and are classified as unknown.
+var regions = []region{
+	{
+		iso: "RU", cc: "7", trunk: "8", lengths: []int{10},
+		mobile:   []string{"9"},
+		fixed:    []string{"3", "4", "8"},
+		tollFree: []string{"800"},
+		premium:  []string{"803", "809"},
+	},
+	{
+		iso: "KZ", cc: "7", trunk: "8", lengths: []int{10},
+		leading: []string{"6", "7"},
+		mobile:  []string{"70", "74", "75", "76", "77"},
+		fixed:   []string{"71", "72"},
+	},
+	{
+		iso: "BY", cc: "375", trunk: "80", lengths: []int{9},
+		mobile:   []string{"25", "29", "33", "44"},
+		fixed:    []string{"1", "2"},
+		tollFree: []string{"800"},
+		premium:  []string{"902"},
+	},
+	{
+		iso: "UA", cc: "380", trunk: "0", lengths: []int{9},
+		mobile:   []string{"39", "50", "63", "66", "67", "68", "73", "91", "92", "93", "94", "95", "96", "97", "98", "99"},
+		fixed:    []string{"3", "4", "5", "6"},
+		tollFree: []string{"800"},
+		premium:  []string{"900"},
+	},
+	{
+		iso: "UZ", cc: "998", lengths: []int{9},
+		mobile: []string{"20", "33", "50", "77", "88", "9"},
+		fixed:  []string{"6", "7"},
+	},
+	{
+		iso: "US", cc: "1", trunk: "1", lengths: []int{10},
+		tollFree: []string{"800", "833", "844", "855", "866", "877", "888"},
+		premium:  []string{"900", "976"},
+		shared:   []string{"2", "3", "4", "5", "6", "7", "8", "9"},
+	},
+	{
+		iso: "GB", cc: "44", trunk: "0", lengths: []int{10},
+		mobile:   []string{"71", "72", "73", "74", "75", "77", "78", "79"},
+		fixed:    []string{"1", "2"},
+		tollFree: []string{"80"},
+		premium:  []string{"9"},
+	},
+	{
+		iso: "DE", cc: "49", trunk: "0", lengths: []int{7, 8, 9, 10, 11},
+		mobile:   []string{"15", "16", "17"},
+		fixed:    []string{"2", "3", "4", "5", "6", "7", "8", "9"},
+		tollFree: []string{"800"},
+		premium:  []string{"900"},
+	},
+}
@@ -0,0 +1,229 @@
+// Package phone parses user-entered phone numbers into canonical E.164 form.
+package phone
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Parse errors.
+var (
+	ErrInvalid       = errors.New("invalid phone number")
+	ErrInvalidLength = errors.New("invalid phone number length")
+	ErrNoRegion      = errors.New("national number without default region")
+	ErrLineType      = errors.New("phone line type not allowed")
+)
+
+// LineType classifies what kind of line a number belongs to.
+type LineType string
+
+const (
+	Mobile        LineType = "mobile"
+	FixedLine     LineType = "fixed_line"
+	FixedOrMobile LineType = "fixed_or_mobile" // plans that do not separate them, e.g. the NANP
+	TollFree      LineType = "toll_free"
+	Premium       LineType = "premium"
+	Unknown       LineType = "unknown" // no numbering plan data for the country
+)
+
+// Number is a parsed phone number.
+type Number struct {
+	E164        string   `json:"e164"`
+	CountryCode string   `json:"country_code"`
+	Region      string   `json:"region,omitempty"` // ISO 3166-1 alpha-2, empty when unknown
+	National    string   `json:"national"`         // national significant number
+	Type        LineType `json:"type"`
+}
+
+// Parse accepts international ("+7 (999) 123-45-67", "00 7 999...") and national
+// ("8 999 123 45 67") formats. National numbers are read in defaultRegion.
+func Parse(raw, defaultRegion string) (Number, error) {
+	digits, international, err := clean(raw)
+	if err != nil {
+		return Number{}, err
+	}
+
+	def, hasDefault := lookup(defaultRegion)
+	if !international && hasDefault {
+		switch {
+		case def.trunk != "" && strings.HasPrefix(digits, def.trunk) && validLength(def, len(digits)-len(def.trunk)):
+			national := digits[len(def.trunk):]
+			return build(*regionFor(def.cc, national), national)
+		case validLength(def, len(digits)):
+			return build(*regionFor(def.cc, digits), digits)
+		case strings.HasPrefix(digits, def.cc):
+			// Country code typed without "+".
+			international = true
+		}
+	}
+	if !international {
+		if !hasDefault {
+			return Number{}, ErrNoRegion
+		}
+		return Number{}, fmt.Errorf("%w: %q in %s", ErrInvalidLength, raw, def.iso)
+	}
+	return parseInternational(digits)
+}
+
+func parseInternational(digits string) (Number, error) {
+	if len(digits) < 8 || len(digits) > 15 || digits[0] == '0' {
+		return Number{}, fmt.Errorf("%w: +%s", ErrInvalidLength, digits)
+	}
+	for n := 1; n <= 3; n++ {
+		cc, national := digits[:n], digits[n:]
+		if match := regionFor(cc, national); match != nil {
+			if !validLength(*match, len(national)) {
+				return Number{}, fmt.Errorf("%w: +%s in %s", ErrInvalidLength, digits, match.iso)
+			}
+			return build(*match, national)
+		}
+	}
+	return Number{E164: "+" + digits, National: digits, Type: Unknown}, nil
+}
+
+// regionFor picks the region of a national number within calling code cc.
+// Regions sharing a code are told apart by their leading digits.
+func regionFor(cc, national string) *region {
+	var match *region
+	for i := range regions {
+		r := &regions[i]
+		if r.cc != cc {
+			continue
+		}
+		if r.leading != nil {
+			if hasAnyPrefix(national, r.leading) {
+				return r
+			}
+			continue
+		}
+		if match == nil {
+			match = r
+		}
+	}
+	return match
+}
+
+func build(r region, national string) (Number, error) {
+	return Number{
+		E164:        "+" + r.cc + national,
+		CountryCode: r.cc,
+		Region:      r.iso,
+		National:    national,
+		Type:        classify(r, national),
+	}, nil
+}
+
+func classify(r region, national string) LineType {
+	switch {
+	case hasAnyPrefix(national, r.premium):
+		return Premium
+	case hasAnyPrefix(national, r.tollFree):
+		return TollFree
+	case hasAnyPrefix(national, r.mobile):
+		return Mobile
+	case hasAnyPrefix(national, r.fixed):
+		return FixedLine
+	case hasAnyPrefix(national, r.shared):
+		return FixedOrMobile
+	}
+	return Unknown
+}
+
+// clean strips formatting and reports whether the number carried an international prefix.
+func clean(raw string) (string, bool, error) {
+	s := strings.TrimSpace(raw)
+	international := false
+	switch {
+	case strings.HasPrefix(s, "+"):
+		international, s = true, s[1:]
+	case strings.HasPrefix(s, "00"):
+		international, s = true, s[2:]
+	}
+	var b strings.Builder
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+			b.WriteRune(c)
+		case c == ' ' || c == '-' || c == '(' || c == ')' || c == '.' || c == '\u00a0':
+		default:
+			return "", false, fmt.Errorf("%w: %q", ErrInvalid, raw)
+		}
+	}
+	if b.Len() == 0 {
+		return "", false, fmt.Errorf("%w: %q", ErrInvalid, raw)
+	}
+	return b.String(), international, nil
+}
+
+func lookup(iso string) (region, bool) {
+	iso = strings.ToUpper(iso)
+	for _, r := range regions {
+		if r.iso == iso {
+			return r, true
+		}
+	}
+	return region{}, false
+}
+
+func validLength(r region, n int) bool {
+	for _, l := range r.lengths {
+		if l == n {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Normalizer parses numbers with a default region and rejects disallowed line types.
+type Normalizer struct {
+	defaultRegion string
+	allowed       map[LineType]bool
+}
+
+// NewNormalizer creates a Normalizer. An empty allowed list permits every line type.
+func NewNormalizer(defaultRegion string, allowed []string) (*Normalizer, error) {
+	if defaultRegion != "" {
+		if _, ok := lookup(defaultRegion); !ok {
+			return nil, fmt.Errorf("unsupported default region %q", defaultRegion)
+		}
+	}
+	n := &Normalizer{defaultRegion: defaultRegion}
+	if len(allowed) > 0 {
+		n.allowed = make(map[LineType]bool, len(allowed))
+		for _, t := range allowed {
+			n.allowed[LineType(t)] = true
+		}
+	}
+	return n, nil
+}
+
+// Normalize parses raw and checks its line type.
+func (n *Normalizer) Normalize(raw string) (Number, error) {
+	num, err := Parse(raw, n.defaultRegion)
+	if err != nil {
+		return Number{}, err
+	}
+	if n.allowed != nil && !n.allowed[num.Type] {
+		return num, fmt.Errorf("%w: %s", ErrLineType, num.Type)
+	}
+	return num, nil
+}
+
+// RegionOf returns the ISO region of an E.164 number, or "" when unknown.
+func RegionOf(e164 string) string {
+	num, err := Parse(e164, "")
+	if err != nil {
+		return ""
+	}
+	return num.Region
+}
@@ -0,0 +1,77 @@
+package phone
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		raw, region string
+		e164        string
+		iso         string
+		typ         LineType
+	}{
+		{"+7 (999) 123-45-67", "", "+79991234567", "RU", Mobile},
+		{"8 999 123 45 67", "RU", "+79991234567", "RU", Mobile},
+		{"9991234567", "RU", "+79991234567", "RU", Mobile},
+		{"79991234567", "RU", "+79991234567", "RU", Mobile},
+		{"0079991234567", "", "+79991234567", "RU", Mobile},
+		{"+7 495 123-45-67", "", "+74951234567", "RU", FixedLine},
+		{"+7 809 123-45-67", "", "+78091234567", "RU", Premium},
+		{"+7 701 123 4567", "", "+77011234567", "KZ", Mobile},
+		{"8 701 123 4567", "RU", "+77011234567", "KZ", Mobile},
+		{"+375 29 123-45-67", "", "+375291234567", "BY", Mobile},
+		{"80 29 123-45-67", "BY", "+375291234567", "BY", Mobile},
+		{"+44 7911 123456", "", "+447911123456", "GB", Mobile},
+		{"07911 123456", "GB", "+447911123456", "GB", Mobile},
+		{"+1 (202) 555-0123", "", "+12025550123", "US", FixedOrMobile},
+		{"+1 900 555 0123", "", "+19005550123", "US", Premium},
+		{"+81 90 1234 5678", "", "+819012345678", "", Unknown},
+	}
+	for _, tc := range cases {
+		got, err := Parse(tc.raw, tc.region)
+		if err != nil {
+			t.Errorf("Parse(%q, %q) error: %v", tc.raw, tc.region, err)
+			continue
+		}
+		if got.E164 != tc.e164 || got.Region != tc.iso || got.Type != tc.typ {
+			t.Errorf("Parse(%q, %q) = %+v; want %s %s %s", tc.raw, tc.region, got, tc.e164, tc.iso, tc.typ)
+		}
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	cases := []struct {
+		raw, region string
+		want        error
+	}{
+		{"+7 999 123 45", "", ErrInvalidLength},
+		{"+7999abc4567", "", ErrInvalid},
+		{"", "RU", ErrInvalid},
+		{"9991234567", "", ErrNoRegion},
+		{"12345", "RU", ErrInvalidLength},
+		{"+1234567890", "", ErrInvalidLength},
+	}
+	for _, tc := range cases {
+		if _, err := Parse(tc.raw, tc.region); !errors.Is(err, tc.want) {
+			t.Errorf("Parse(%q, %q) error = %v; want %v", tc.raw, tc.region, err, tc.want)
+		}
+	}
+}
+
+func TestNormalizer_LineTypes(t *testing.T) {
+	n, err := NewNormalizer("RU", []string{"mobile", "unknown"})
+	if err != nil {
+		t.Fatalf("NewNormalizer error: %v", err)
+	}
+	if num, err := n.Normalize("8 (999) 123-45-67"); err != nil || num.E164 != "+79991234567" {
+		t.Errorf("Normalize = %+v, %v; want +79991234567", num, err)
+	}
+	if _, err := n.Normalize("+7 809 123 45 67"); !errors.Is(err, ErrLineType) {
+		t.Errorf("Normalize(premium) error = %v; want ErrLineType", err)
+	}
+	if _, err := NewNormalizer("XX", nil); err == nil {
+		t.Errorf("NewNormalizer(XX) error = nil; want error")
+	}
+}
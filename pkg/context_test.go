@@ -0,0 +1,28 @@
+package logger
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestFor_AddsRequestID(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	base := zap.New(core).Sugar()
+
+	For(context.Background(), base).Info("plain")
+	For(WithRequestID(context.Background(), "req-1"), base).Info("tagged")
+
+	entries := logs.All()
+	if len(entries) != 2 {
+		t.Fatalf("entries = %d; want 2", len(entries))
+	}
+	if _, ok := entries[0].ContextMap()["request_id"]; ok {
+		t.Errorf("plain entry has request_id: %v", entries[0].ContextMap())
+	}
+	if got := entries[1].ContextMap()["request_id"]; got != "req-1" {
+		t.Errorf("request_id = %v; want req-1", got)
+	}
+}
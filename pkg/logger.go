@@ -9,7 +9,8 @@ import (
 
 var Log *zap.SugaredLogger
 
-func Init(level string) error {
+// Init builds the global logger. Every entry passes through the redaction layer, see Redaction.
+func Init(level string, r Redaction) error {
 	var zapLevel zapcore.Level
 	if err := zapLevel.UnmarshalText([]byte(level)); err != nil {
 		return fmt.Errorf("invalid log level %q: %w", level, err)
@@ -17,7 +18,14 @@ func Init(level string) error {
 
 	cfg := zap.NewProductionConfig()
 	cfg.Level = zap.NewAtomicLevelAt(zapLevel)
-	logger, err := cfg.Build(zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel))
+	red, err := newRedactor(r)
+	if err != nil {
+		return fmt.Errorf("log redaction: %w", err)
+	}
+	logger, err := cfg.Build(zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel),
+		zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return &redactCore{Core: core, r: red}
+		}))
 	if err != nil {
 		return fmt.Errorf("build logger: %w", err)
 	}
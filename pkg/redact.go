@@ -0,0 +1,194 @@
+package logger
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Redaction configures what the logger hides. Phones are always masked or
+// hashed, codes and message texts are never written, credentials are scrubbed.
+type Redaction struct {
+	HashPhones bool   // write an HMAC of the number instead of a masked form, so lines can be correlated per phone
+	HashKey    []byte // HMAC key; a random per-process key is used when empty
+}
+
+const redacted = "[REDACTED]"
+
+var (
+	// secretKeys are field names whose values are dropped entirely.
+	secretKeys = map[string]bool{
+		"code": true, "otp": true,
+		"password": true, "psw": true, "secret": true, "token": true,
+		"authorization": true, "signature": true,
+	}
+	// textKeys hold rendered SMS texts, which contain the code; only their length is kept.
+	textKeys = map[string]bool{"message": true, "mes": true, "text": true}
+
+	phonePattern = regexp.MustCompile(`\+\d{8,15}\b`)
+	credPatterns = []struct {
+		re   *regexp.Regexp
+		repl string
+	}{
+		{regexp.MustCompile(`(?i)\b(psw|password|secret|token)=[^&\s"]+`), "${1}=" + redacted},
+		{regexp.MustCompile(`(?i)\bBearer\s+\S+`), "Bearer " + redacted},
+		{regexp.MustCompile(`\b(otp_[0-9a-f]{16})_[0-9a-f]{64}\b`), "${1}_" + redacted},
+	}
+)
+
+// redactor rewrites log fields and messages.
+type redactor struct {
+	hashKey []byte // nil masks phones instead of hashing them
+}
+
+func newRedactor(r Redaction) (*redactor, error) {
+	if !r.HashPhones {
+		return &redactor{}, nil
+	}
+	key := r.HashKey
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("phone hash key: %w", err)
+		}
+	}
+	return &redactor{hashKey: key}, nil
+}
+
+// phone masks an E.164 number to "+799******67", or hashes it to "ph_" and 12 hex digits.
+func (r *redactor) phone(p string) string {
+	if r.hashKey != nil {
+		return HashPhone(r.hashKey, p)
+	}
+	return MaskPhone(p)
+}
+
+// HashPhone returns "ph_" and the first 12 hex digits of an HMAC-SHA256 of p under key.
+func HashPhone(key []byte, p string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(p))
+	return "ph_" + hex.EncodeToString(mac.Sum(nil))[:12]
+}
+
+// MaskPhone keeps the "+", the first three and the last two digits of a number.
+func MaskPhone(p string) string {
+	if len(p) < 7 {
+		return strings.Repeat("*", len(p))
+	}
+	return p[:4] + strings.Repeat("*", len(p)-6) + p[len(p)-2:]
+}
+
+// scrub replaces phone numbers and credentials inside free text.
+func (r *redactor) scrub(s string) string {
+	s = phonePattern.ReplaceAllStringFunc(s, r.phone)
+	for _, p := range credPatterns {
+		s = p.re.ReplaceAllString(s, p.repl)
+	}
+	return s
+}
+
+func (r *redactor) field(f zapcore.Field) zapcore.Field {
+	key := strings.ToLower(f.Key)
+	switch {
+	case secretKeys[key]:
+		return zap.String(f.Key, redacted)
+	case textKeys[key] && f.Type == zapcore.StringType:
+		return zap.String(f.Key, fmt.Sprintf("[%d chars]", utf8.RuneCountInString(f.String)))
+	}
+	switch f.Type {
+	case zapcore.StringType:
+		f.String = r.scrub(f.String)
+	case zapcore.ErrorType:
+		if err, ok := f.Interface.(error); ok && err != nil {
+			return zap.String(f.Key, r.scrub(err.Error()))
+		}
+	case zapcore.StringerType:
+		if s, ok := f.Interface.(fmt.Stringer); ok {
+			return zap.String(f.Key, r.scrub(s.String()))
+		}
+	case zapcore.ByteStringType:
+		if b, ok := f.Interface.([]byte); ok {
+			return zap.ByteString(f.Key, []byte(r.scrub(string(b))))
+		}
+	case zapcore.ReflectType:
+		return zap.Any(f.Key, r.value(f.Interface))
+	}
+	return f
+}
+
+// value scrubs an arbitrary value through its JSON form, which is how the
+// encoder would write it anyway. Values that do not encode are dropped.
+func (r *redactor) value(v any) any {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return redacted
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var out any
+	if err := dec.Decode(&out); err != nil {
+		return redacted
+	}
+	return r.walk(out)
+}
+
+// walk scrubs the strings of a decoded JSON value and drops secret keys.
+func (r *redactor) walk(v any) any {
+	switch t := v.(type) {
+	case string:
+		return r.scrub(t)
+	case []any:
+		for i := range t {
+			t[i] = r.walk(t[i])
+		}
+	case map[string]any:
+		for k, e := range t {
+			if secretKeys[strings.ToLower(k)] {
+				t[k] = redacted
+				continue
+			}
+			t[k] = r.walk(e)
+		}
+	}
+	return v
+}
+
+func (r *redactor) fields(fs []zapcore.Field) []zapcore.Field {
+	out := make([]zapcore.Field, len(fs))
+	for i, f := range fs {
+		out[i] = r.field(f)
+	}
+	return out
+}
+
+// redactCore applies a redactor to everything written through the wrapped core.
+type redactCore struct {
+	zapcore.Core
+	r *redactor
+}
+
+func (c *redactCore) With(fs []zapcore.Field) zapcore.Core {
+	return &redactCore{Core: c.Core.With(c.r.fields(fs)), r: c.r}
+}
+
+func (c *redactCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(e.Level) {
+		return ce.AddCore(e, c)
+	}
+	return ce
+}
+
+func (c *redactCore) Write(e zapcore.Entry, fs []zapcore.Field) error {
+	e.Message = c.r.scrub(e.Message)
+	return c.Core.Write(e, c.r.fields(fs))
+}
@@ -0,0 +1,95 @@
+package logger
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func newRedactedLogger(t *testing.T, r Redaction) (*zap.SugaredLogger, *observer.ObservedLogs) {
+	t.Helper()
+	red, err := newRedactor(r)
+	if err != nil {
+		t.Fatalf("newRedactor error: %v", err)
+	}
+	core, logs := observer.New(zap.DebugLevel)
+	return zap.New(&redactCore{Core: core, r: red}).Sugar(), logs
+}
+
+func TestRedaction_MasksPhonesAndDropsSecrets(t *testing.T) {
+	log, logs := newRedactedLogger(t, Redaction{})
+	log.With("phone", "+79991234567").Infow("Code sent to +79991234567",
+		"code", "123456",
+		"message", "Your code: 123456",
+		"psw", "hunter2",
+		"err", errors.New(`post "https://smsc.ru/sys/send.php?login=u&psw=hunter2": timeout`),
+		"auth", "Bearer otp_0123456789abcdef_"+strings.Repeat("a", 64),
+	)
+
+	e := logs.All()[0]
+	if e.Message != "Code sent to +799******67" {
+		t.Errorf("message = %q; want the phone masked", e.Message)
+	}
+	fields := e.ContextMap()
+	want := map[string]string{
+		"phone":   "+799******67",
+		"code":    redacted,
+		"message": "[17 chars]",
+		"psw":     redacted,
+		"err":     `post "https://smsc.ru/sys/send.php?login=u&psw=[REDACTED]": timeout`,
+		"auth":    "Bearer " + redacted,
+	}
+	for k, v := range want {
+		if fields[k] != v {
+			t.Errorf("%s = %v; want %q", k, fields[k], v)
+		}
+	}
+}
+
+func TestRedaction_ScrubsBytesAndReflectedValues(t *testing.T) {
+	log, logs := newRedactedLogger(t, Redaction{})
+	type request struct {
+		Phone    string
+		Password string
+		Tries    int
+	}
+	log.Desugar().Info("sent",
+		zap.ByteString("body", []byte("login=u&psw=hunter2&phones=+79991234567")),
+		zap.Reflect("req", request{Phone: "+79991234567", Password: "hunter2", Tries: 2}),
+	)
+
+	fields := logs.All()[0].ContextMap()
+	if got := fields["body"]; got != "login=u&psw=[REDACTED]&phones=+799******67" {
+		t.Errorf("body = %v; want credentials and phone scrubbed", got)
+	}
+	req, ok := fields["req"].(map[string]any)
+	if !ok {
+		t.Fatalf("req = %#v; want a scrubbed object", fields["req"])
+	}
+	if req["Phone"] != "+799******67" || req["Password"] != redacted || req["Tries"] != json.Number("2") {
+		t.Errorf("req = %v; want phone masked, password dropped and tries kept", req)
+	}
+}
+
+func TestRedaction_HashesPhones(t *testing.T) {
+	log, logs := newRedactedLogger(t, Redaction{HashPhones: true, HashKey: []byte("k")})
+	log.Infow("a", "phone", "+79991234567")
+	log.Infow("b", "phone", "+79991234567")
+	log.Infow("c", "phone", "+79991234568")
+
+	entries := logs.All()
+	first := entries[0].ContextMap()["phone"].(string)
+	if !strings.HasPrefix(first, "ph_") || len(first) != 15 || strings.Contains(first, "7999") {
+		t.Fatalf("hashed phone = %q; want ph_ and 12 hex digits", first)
+	}
+	if second := entries[1].ContextMap()["phone"]; second != first {
+		t.Errorf("same phone hashed to %v and %v; want equal", first, second)
+	}
+	if third := entries[2].ContextMap()["phone"]; third == first {
+		t.Errorf("different phones hashed to the same %v", first)
+	}
+}
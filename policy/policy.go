@@ -0,0 +1,262 @@
+// Package policy decides which phone numbers may receive codes.
+package policy
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NlightN22/OTPSMSProvider/phone"
+	logger "github.com/NlightN22/OTPSMSProvider/pkg"
+	storage "github.com/NlightN22/OTPSMSProvider/storage"
+	"go.uber.org/zap"
+)
+
+// Errors returned by Check.
+var (
+	ErrBlocked           = errors.New("phone number is blocked")
+	ErrCountryNotAllowed = errors.New("destination country is not allowed")
+)
+
+// ErrInvalidEntry is returned by Block for malformed entries.
+var ErrInvalidEntry = errors.New("invalid blocklist entry")
+
+// blocklistKey is the storage list holding entries added at runtime.
+const blocklistKey = "policy:blocklist"
+
+// Config describes static lists and files. Blocklist entries are either an
+// exact E.164 number ("+79991234567") or a prefix ending with "*" ("+7999*").
+// Countries are calling codes without "+", e.g. "7" or "375", or ISO 3166-1
+// alpha-2 regions known to the phone package, e.g. "RU" or "KZ".
+type Config struct {
+	Blocklist      []string
+	AllowCountries []string // empty allows every country
+	BlocklistFile  string   // one entry per line, "#" starts a comment
+	AllowlistFile  string   // one calling code per line
+}
+
+// Entries lists the effective rules by source.
+type Entries struct {
+	Config         []string `json:"config"`
+	File           []string `json:"file"`
+	Runtime        []string `json:"runtime"`
+	AllowCountries []string `json:"allow_countries"`
+}
+
+// Policy checks numbers against blocklists and the country allowlist.
+// File lists are reloaded by Watch, runtime entries live in storage.
+type Policy struct {
+	store storage.Storage
+	cfg   Config
+	log   *zap.SugaredLogger
+
+	mu            sync.RWMutex
+	fileBlocklist []string
+	fileCountries []string
+	blockMod      time.Time
+	allowMod      time.Time
+}
+
+// New loads the configured files. A missing or unreadable file is an error.
+func New(store storage.Storage, cfg Config) (*Policy, error) {
+	for _, e := range cfg.Blocklist {
+		if err := validateEntry(e); err != nil {
+			return nil, err
+		}
+	}
+	p := &Policy{store: store, cfg: cfg, log: logger.New("Policy")}
+	if err := p.reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Check returns ErrBlocked or ErrCountryNotAllowed when number must not receive codes.
+func (p *Policy) Check(number string) error {
+	p.mu.RLock()
+	fileBlocklist, fileCountries := p.fileBlocklist, p.fileCountries
+	p.mu.RUnlock()
+
+	for _, list := range [][]string{p.cfg.Blocklist, fileBlocklist, p.store.GetListEntries(blocklistKey)} {
+		for _, e := range list {
+			if matches(e, number) {
+				return ErrBlocked
+			}
+		}
+	}
+
+	countries := append(append([]string(nil), p.cfg.AllowCountries...), fileCountries...)
+	if len(countries) == 0 {
+		return nil
+	}
+	digits := strings.TrimPrefix(number, "+")
+	region := ""
+	for _, cc := range countries {
+		if isRegion(cc) {
+			if region == "" {
+				region = phone.RegionOf(number)
+			}
+			if strings.EqualFold(cc, region) {
+				return nil
+			}
+			continue
+		}
+		if strings.HasPrefix(digits, strings.TrimPrefix(cc, "+")) {
+			return nil
+		}
+	}
+	return ErrCountryNotAllowed
+}
+
+// Block adds a runtime blocklist entry.
+func (p *Policy) Block(entry string) error {
+	entry = strings.TrimSpace(entry)
+	if err := validateEntry(entry); err != nil {
+		return err
+	}
+	p.store.AddListEntry(blocklistKey, entry)
+	p.log.Infow("Blocklist entry added", "entry", entry)
+	return nil
+}
+
+// Unblock removes a runtime blocklist entry and reports whether it existed.
+// Entries from config or files can only be removed there.
+func (p *Policy) Unblock(entry string) bool {
+	ok := p.store.DeleteListEntry(blocklistKey, strings.TrimSpace(entry))
+	if ok {
+		p.log.Infow("Blocklist entry removed", "entry", entry)
+	}
+	return ok
+}
+
+// Entries returns all rules currently in effect.
+func (p *Policy) Entries() Entries {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return Entries{
+		Config:         p.cfg.Blocklist,
+		File:           p.fileBlocklist,
+		Runtime:        p.store.GetListEntries(blocklistKey),
+		AllowCountries: append(append([]string(nil), p.cfg.AllowCountries...), p.fileCountries...),
+	}
+}
+
+// Watch reloads list files whenever their modification time changes, until ctx is done.
+// Reload errors are logged and the previous lists stay in effect.
+func (p *Policy) Watch(ctx context.Context, interval time.Duration) {
+	if p.cfg.BlocklistFile == "" && p.cfg.AllowlistFile == "" || interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.reload(); err != nil {
+				p.log.Errorw("Policy reload failed", "err", err)
+			}
+		}
+	}
+}
+
+func (p *Policy) reload() error {
+	if p.cfg.BlocklistFile != "" {
+		entries, mod, changed, err := readIfChanged(p.cfg.BlocklistFile, p.blockModTime())
+		if err != nil {
+			return err
+		}
+		if changed {
+			for _, e := range entries {
+				if err := validateEntry(e); err != nil {
+					return fmt.Errorf("%s: %w", p.cfg.BlocklistFile, err)
+				}
+			}
+			p.mu.Lock()
+			p.fileBlocklist, p.blockMod = entries, mod
+			p.mu.Unlock()
+			p.log.Infow("Blocklist loaded", "file", p.cfg.BlocklistFile, "entries", len(entries))
+		}
+	}
+	if p.cfg.AllowlistFile != "" {
+		entries, mod, changed, err := readIfChanged(p.cfg.AllowlistFile, p.allowModTime())
+		if err != nil {
+			return err
+		}
+		if changed {
+			p.mu.Lock()
+			p.fileCountries, p.allowMod = entries, mod
+			p.mu.Unlock()
+			p.log.Infow("Country allowlist loaded", "file", p.cfg.AllowlistFile, "entries", len(entries))
+		}
+	}
+	return nil
+}
+
+func (p *Policy) blockModTime() time.Time {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.blockMod
+}
+
+func (p *Policy) allowModTime() time.Time {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.allowMod
+}
+
+// readIfChanged reads non-empty, non-comment lines of path if it was modified after since.
+func readIfChanged(path string, since time.Time) ([]string, time.Time, bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, time.Time{}, false, fmt.Errorf("policy file: %w", err)
+	}
+	if !info.ModTime().After(since) {
+		return nil, since, false, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, time.Time{}, false, fmt.Errorf("policy file: %w", err)
+	}
+	defer f.Close()
+
+	var entries []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		if line = strings.TrimSpace(line); line != "" {
+			entries = append(entries, line)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, time.Time{}, false, fmt.Errorf("policy file %s: %w", path, err)
+	}
+	return entries, info.ModTime(), true, nil
+}
+
+func isRegion(s string) bool {
+	return len(s) == 2 && strings.Trim(strings.ToUpper(s), "ABCDEFGHIJKLMNOPQRSTUVWXYZ") == ""
+}
+
+func validateEntry(e string) error {
+	digits := strings.TrimSuffix(strings.TrimPrefix(e, "+"), "*")
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return fmt.Errorf("%w: %q", ErrInvalidEntry, e)
+	}
+	return nil
+}
+
+func matches(entry, phone string) bool {
+	entry = strings.TrimPrefix(entry, "+")
+	phone = strings.TrimPrefix(phone, "+")
+	if prefix, ok := strings.CutSuffix(entry, "*"); ok {
+		return strings.HasPrefix(phone, prefix)
+	}
+	return entry == phone
+}
@@ -0,0 +1,104 @@
+package policy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	storage "github.com/NlightN22/OTPSMSProvider/storage"
+)
+
+func TestPolicy_Check(t *testing.T) {
+	p, err := New(storage.NewMemoryStorage(), Config{
+		Blocklist:      []string{"+79990000000", "+7900*"},
+		AllowCountries: []string{"7", "375"},
+	})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	cases := map[string]error{
+		"+79990000000":  ErrBlocked,
+		"+79001234567":  ErrBlocked,
+		"+79991234567":  nil,
+		"+375291234567": nil,
+		"+15551234567":  ErrCountryNotAllowed,
+	}
+	for phone, want := range cases {
+		if got := p.Check(phone); !errors.Is(got, want) {
+			t.Errorf("Check(%s) = %v; want %v", phone, got, want)
+		}
+	}
+}
+
+func TestPolicy_RuntimeEntries(t *testing.T) {
+	p, err := New(storage.NewMemoryStorage(), Config{})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	if err := p.Block("+1555*"); err != nil {
+		t.Fatalf("Block error: %v", err)
+	}
+	if err := p.Check("+15551234567"); !errors.Is(err, ErrBlocked) {
+		t.Errorf("Check after Block = %v; want ErrBlocked", err)
+	}
+	if !p.Unblock("+1555*") {
+		t.Errorf("Unblock = false; want true")
+	}
+	if err := p.Check("+15551234567"); err != nil {
+		t.Errorf("Check after Unblock = %v; want nil", err)
+	}
+	if err := p.Block("+1-555"); !errors.Is(err, ErrInvalidEntry) {
+		t.Errorf("Block(invalid) = %v; want ErrInvalidEntry", err)
+	}
+}
+
+func TestPolicy_FileReload(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "blocklist.txt")
+	if err := os.WriteFile(path, []byte("# abusers\n+79990000000\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := New(storage.NewMemoryStorage(), Config{BlocklistFile: path})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	if err := p.Check("+79990000000"); !errors.Is(err, ErrBlocked) {
+		t.Errorf("Check = %v; want ErrBlocked", err)
+	}
+
+	if err := os.WriteFile(path, []byte("+1555* # premium range\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.reload(); err != nil {
+		t.Fatalf("reload error: %v", err)
+	}
+	if err := p.Check("+79990000000"); err != nil {
+		t.Errorf("Check after reload = %v; want nil", err)
+	}
+	if err := p.Check("+15551234567"); !errors.Is(err, ErrBlocked) {
+		t.Errorf("Check after reload = %v; want ErrBlocked", err)
+	}
+
+	if _, err := New(storage.NewMemoryStorage(), Config{AllowlistFile: filepath.Join(dir, "missing")}); err == nil {
+		t.Errorf("New with missing file error = nil; want error")
+	}
+}
+
+func TestPolicy_RegionAllowlist(t *testing.T) {
+	p, err := New(storage.NewMemoryStorage(), Config{AllowCountries: []string{"KZ"}})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	if err := p.Check("+77011234567"); err != nil {
+		t.Errorf("Check(KZ) = %v; want nil", err)
+	}
+	if err := p.Check("+79991234567"); !errors.Is(err, ErrCountryNotAllowed) {
+		t.Errorf("Check(RU) = %v; want ErrCountryNotAllowed", err)
+	}
+}
@@ -0,0 +1,157 @@
+// Package ratelimit implements layered token-bucket limits on SMS sends.
+package ratelimit
+
+import (
+	"math"
+	"net"
+	"strings"
+	"time"
+
+	logger "github.com/NlightN22/OTPSMSProvider/pkg"
+	storage "github.com/NlightN22/OTPSMSProvider/storage"
+	"go.uber.org/zap"
+)
+
+// Reasons reported when a send is rejected.
+const (
+	ReasonIP     = "ip"
+	ReasonSubnet = "subnet"
+	ReasonPrefix = "prefix"
+	ReasonGlobal = "global"
+	ReasonKey    = "api_key" // per-client limit, see AllowKey
+)
+
+// Limit is a token-bucket rate. A zero PerMinute disables the limit.
+type Limit struct {
+	PerMinute float64 // refill rate
+	Burst     int     // bucket capacity, 1 when zero
+}
+
+// Enabled reports whether the limit is active.
+func (l Limit) Enabled() bool {
+	return l.PerMinute > 0
+}
+
+// Limits groups all layers consulted for a send.
+type Limits struct {
+	IP           Limit // per client IP
+	Subnet       Limit // per IPv4 /24 or IPv6 /64
+	Prefix       Limit // per leading PrefixDigits digits of the phone
+	Global       Limit // all sends together
+	PrefixDigits int   // digits of the phone number after "+" that form a prefix
+}
+
+// Limiter checks sends against Limits with buckets kept in storage,
+// so every replica sharing the storage shares the limits.
+type Limiter struct {
+	store  storage.Storage
+	limits Limits
+	now    func() time.Time
+	log    *zap.SugaredLogger
+}
+
+// NewLimiter creates a Limiter backed by store.
+func NewLimiter(store storage.Storage, limits Limits) *Limiter {
+	if limits.PrefixDigits <= 0 {
+		limits.PrefixDigits = 4
+	}
+	return &Limiter{
+		store:  store,
+		limits: limits,
+		now:    time.Now,
+		log:    logger.New("RateLimiter"),
+	}
+}
+
+// Allow takes one token from every enabled layer, most specific first.
+// It stops at the first empty bucket and returns its reason and the time until a token is available.
+// Tokens taken from earlier layers are not returned, so rejected attempts still count.
+func (l *Limiter) Allow(ip, phone string) (bool, string, time.Duration) {
+	checks := []struct {
+		reason string
+		key    string
+		limit  Limit
+	}{
+		{ReasonIP, ip, l.limits.IP},
+		{ReasonSubnet, Subnet(ip), l.limits.Subnet},
+		{ReasonPrefix, Prefix(phone, l.limits.PrefixDigits), l.limits.Prefix},
+		{ReasonGlobal, "all", l.limits.Global},
+	}
+	for _, c := range checks {
+		if !c.limit.Enabled() || c.key == "" {
+			continue
+		}
+		if ok, wait := l.take("rl:"+c.reason+":"+c.key, c.limit); !ok {
+			l.log.Warnw("Rate limit exceeded", "reason", c.reason, "key", c.key, "wait", wait)
+			return false, c.reason, wait
+		}
+	}
+	return true, "", 0
+}
+
+// AllowKey applies a per-client limit, e.g. of an API key, independently of the layers in Limits.
+func (l *Limiter) AllowKey(id string, limit Limit) (bool, time.Duration) {
+	if !limit.Enabled() {
+		return true, 0
+	}
+	ok, wait := l.take("rl:"+ReasonKey+":"+id, limit)
+	if !ok {
+		l.log.Warnw("Rate limit exceeded", "reason", ReasonKey, "key", id, "wait", wait)
+	}
+	return ok, wait
+}
+
+func (l *Limiter) take(key string, limit Limit) (bool, time.Duration) {
+	burst := float64(limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	perSecond := limit.PerMinute / 60
+	now := l.now()
+
+	var ok bool
+	var wait time.Duration
+	l.store.UpdateBucket(key, func(b storage.Bucket, exists bool) storage.Bucket {
+		if !exists {
+			b = storage.Bucket{Tokens: burst, Updated: now}
+		}
+		if elapsed := now.Sub(b.Updated).Seconds(); elapsed > 0 {
+			b.Tokens = math.Min(burst, b.Tokens+elapsed*perSecond)
+		}
+		b.Updated = now
+		if b.Tokens >= 1 {
+			b.Tokens--
+			ok = true
+		} else {
+			wait = time.Duration((1 - b.Tokens) / perSecond * float64(time.Second))
+		}
+		// Once refilled the bucket equals a fresh one, so storage may drop it.
+		b.TTL = time.Duration((burst - b.Tokens) / perSecond * float64(time.Second))
+		return b
+	})
+	return ok, wait
+}
+
+// Subnet returns the /24 network of an IPv4 address or the /64 of an IPv6 one.
+func Subnet(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return (&net.IPNet{IP: v4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+	return (&net.IPNet{IP: parsed.Mask(net.CIDRMask(64, 128)), Mask: net.CIDRMask(64, 128)}).String()
+}
+
+// Prefix returns "+" and the first digits of an E.164 phone number.
+func Prefix(phone string, digits int) string {
+	p := strings.TrimPrefix(phone, "+")
+	if len(p) > digits {
+		p = p[:digits]
+	}
+	if p == "" {
+		return ""
+	}
+	return "+" + p
+}
@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	storage "github.com/NlightN22/OTPSMSProvider/storage"
+)
+
+func newTestLimiter(limits Limits) (*Limiter, *time.Time) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := NewLimiter(storage.NewMemoryStorage(), limits)
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func TestLimiter_IPBurstAndRefill(t *testing.T) {
+	l, now := newTestLimiter(Limits{IP: Limit{PerMinute: 6, Burst: 2}})
+
+	for i := 0; i < 2; i++ {
+		if ok, _, _ := l.Allow("10.0.0.1", "+79990000000"); !ok {
+			t.Fatalf("Allow #%d = false; want true within burst", i+1)
+		}
+	}
+	ok, reason, wait := l.Allow("10.0.0.1", "+79990000001")
+	if ok || reason != ReasonIP {
+		t.Fatalf("Allow = %v,%q; want false,%q", ok, reason, ReasonIP)
+	}
+	if wait != 10*time.Second {
+		t.Errorf("wait = %v; want 10s", wait)
+	}
+
+	if ok, _, _ := l.Allow("10.0.0.2", "+79990000000"); !ok {
+		t.Errorf("Allow for other IP = false; want true")
+	}
+
+	*now = now.Add(10 * time.Second)
+	if ok, _, _ := l.Allow("10.0.0.1", "+79990000000"); !ok {
+		t.Errorf("Allow after refill = false; want true")
+	}
+}
+
+func TestLimiter_SetsBucketTTL(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	l := NewLimiter(store, Limits{IP: Limit{PerMinute: 6, Burst: 3}})
+	l.Allow("10.0.0.1", "+79990000000")
+	l.Allow("10.0.0.1", "+79990000000")
+	b := store.UpdateBucket("rl:"+ReasonIP+":10.0.0.1", func(b storage.Bucket, _ bool) storage.Bucket { return b })
+	if b.TTL < 19*time.Second || b.TTL > 20*time.Second {
+		t.Errorf("TTL = %v; want about 20s until two tokens refill", b.TTL)
+	}
+}
+
+func TestLimiter_Layers(t *testing.T) {
+	l, _ := newTestLimiter(Limits{
+		Subnet: Limit{PerMinute: 1, Burst: 1},
+		Prefix: Limit{PerMinute: 1, Burst: 1},
+		Global: Limit{PerMinute: 1, Burst: 1},
+	})
+
+	if ok, _, _ := l.Allow("10.0.0.1", "+79990000000"); !ok {
+		t.Fatalf("first Allow = false; want true")
+	}
+	if _, reason, _ := l.Allow("10.0.0.2", "+15550000000"); reason != ReasonSubnet {
+		t.Errorf("same /24 reason = %q; want %q", reason, ReasonSubnet)
+	}
+	if _, reason, _ := l.Allow("10.0.1.1", "+79991111111"); reason != ReasonPrefix {
+		t.Errorf("same prefix reason = %q; want %q", reason, ReasonPrefix)
+	}
+	if _, reason, _ := l.Allow("10.0.2.1", "+15550000000"); reason != ReasonGlobal {
+		t.Errorf("global reason = %q; want %q", reason, ReasonGlobal)
+	}
+}
+
+func TestLimiter_AllowKey(t *testing.T) {
+	l, _ := newTestLimiter(Limits{})
+	limit := Limit{PerMinute: 1, Burst: 1}
+
+	if ok, _ := l.AllowKey("a", limit); !ok {
+		t.Fatalf("first AllowKey = false; want true")
+	}
+	if ok, wait := l.AllowKey("a", limit); ok || wait != time.Minute {
+		t.Errorf("second AllowKey = %v, %v; want false, 1m", ok, wait)
+	}
+	if ok, _ := l.AllowKey("b", limit); !ok {
+		t.Errorf("AllowKey for other key = false; want true")
+	}
+	if ok, _ := l.AllowKey("a", Limit{}); !ok {
+		t.Errorf("AllowKey without limit = false; want true")
+	}
+}
+
+func TestSubnetAndPrefix(t *testing.T) {
+	if got := Subnet("192.168.1.77"); got != "192.168.1.0/24" {
+		t.Errorf("Subnet = %q; want 192.168.1.0/24", got)
+	}
+	if got := Subnet("2001:db8:1:2:3::1"); got != "2001:db8:1:2::/64" {
+		t.Errorf("Subnet = %q; want 2001:db8:1:2::/64", got)
+	}
+	if got := Subnet("bogus"); got != "" {
+		t.Errorf("Subnet = %q; want empty", got)
+	}
+	if got := Prefix("+79991234567", 4); got != "+7999" {
+		t.Errorf("Prefix = %q; want +7999", got)
+	}
+}
@@ -0,0 +1,41 @@
+// Package ring keeps the most recent entries of a bounded log.
+package ring
+
+// Buffer holds up to a fixed number of entries, overwriting the oldest once
+// full. It is not safe for concurrent use; callers hold their own lock.
+type Buffer[T any] struct {
+	items []T
+	next  int // oldest entry once full
+	size  int
+}
+
+// New returns a Buffer keeping the last size entries.
+func New[T any](size int) *Buffer[T] {
+	return &Buffer[T]{size: size}
+}
+
+// Add appends v, dropping the oldest entry when the buffer is full.
+func (b *Buffer[T]) Add(v T) {
+	if len(b.items) < b.size {
+		b.items = append(b.items, v)
+		return
+	}
+	b.items[b.next] = v
+	b.next = (b.next + 1) % b.size
+}
+
+// Len returns the number of entries held.
+func (b *Buffer[T]) Len() int {
+	return len(b.items)
+}
+
+// Newest calls fn for each entry, newest first, until fn returns false.
+func (b *Buffer[T]) Newest(fn func(T) bool) {
+	n := len(b.items)
+	for i := 0; i < n; i++ {
+		// Walk back from the newest entry, which sits just before next.
+		if !fn(b.items[(b.next-1-i+2*n)%n]) {
+			return
+		}
+	}
+}
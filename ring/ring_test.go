@@ -0,0 +1,48 @@
+package ring
+
+import (
+	"reflect"
+	"testing"
+)
+
+func newest(b *Buffer[int]) []int {
+	out := []int{}
+	b.Newest(func(v int) bool {
+		out = append(out, v)
+		return true
+	})
+	return out
+}
+
+func TestBuffer(t *testing.T) {
+	b := New[int](3)
+	if got := newest(b); len(got) != 0 {
+		t.Errorf("empty buffer = %v; want nothing", got)
+	}
+	b.Add(1)
+	b.Add(2)
+	if got := newest(b); !reflect.DeepEqual(got, []int{2, 1}) {
+		t.Errorf("partial buffer = %v; want [2 1]", got)
+	}
+	for v := 3; v <= 7; v++ {
+		b.Add(v)
+	}
+	if got := newest(b); !reflect.DeepEqual(got, []int{7, 6, 5}) || b.Len() != 3 {
+		t.Errorf("wrapped buffer = %v; want [7 6 5]", got)
+	}
+}
+
+func TestBuffer_NewestStops(t *testing.T) {
+	b := New[int](4)
+	for v := 1; v <= 4; v++ {
+		b.Add(v)
+	}
+	var seen []int
+	b.Newest(func(v int) bool {
+		seen = append(seen, v)
+		return len(seen) < 2
+	})
+	if !reflect.DeepEqual(seen, []int{4, 3}) {
+		t.Errorf("seen = %v; want [4 3]", seen)
+	}
+}
@@ -0,0 +1,129 @@
+// Package server runs the HTTP listener and shuts it down in order:
+// stop accepting connections, finish in-flight requests, then run the
+// registered shutdown hooks within the same deadline.
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	logger "github.com/NlightN22/OTPSMSProvider/pkg"
+	"go.uber.org/zap"
+)
+
+// DefaultShutdownTimeout is used when Config.ShutdownTimeout is zero.
+const DefaultShutdownTimeout = 30 * time.Second
+
+// Config holds listener timeouts. Zero timeouts disable the limit, as in http.Server.
+type Config struct {
+	Addr              string
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	ShutdownTimeout   time.Duration // time allowed for draining and hooks
+	TLSConfig         *tls.Config   // serve HTTPS when set; certificates come from the config callbacks
+}
+
+type hook struct {
+	name string
+	fn   func(context.Context) error
+}
+
+// Server wraps http.Server with signal-driven graceful shutdown.
+type Server struct {
+	srv     *http.Server
+	timeout time.Duration
+	hooks   []hook
+	log     *zap.SugaredLogger
+}
+
+// New builds a server for handler.
+func New(handler http.Handler, cfg Config) *Server {
+	timeout := cfg.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = DefaultShutdownTimeout
+	}
+	return &Server{
+		srv: &http.Server{
+			Addr:              cfg.Addr,
+			Handler:           handler,
+			ReadTimeout:       cfg.ReadTimeout,
+			ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+			WriteTimeout:      cfg.WriteTimeout,
+			IdleTimeout:       cfg.IdleTimeout,
+			TLSConfig:         cfg.TLSConfig,
+		},
+		timeout: timeout,
+		log:     logger.New("Server"),
+	}
+}
+
+// OnShutdown registers fn to run after the listener has drained.
+// Hooks run in registration order and share the shutdown deadline.
+func (s *Server) OnShutdown(name string, fn func(context.Context) error) {
+	s.hooks = append(s.hooks, hook{name: name, fn: fn})
+}
+
+// Run listens on the configured address until ctx is done, then shuts down.
+func (s *Server) Run(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.srv.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve accepts connections on ln until ctx is done, then shuts down.
+// It returns nil after a clean shutdown.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	errc := make(chan error, 1)
+	go func() {
+		if s.srv.TLSConfig != nil {
+			s.log.Infow("Serving HTTPS", "addr", ln.Addr().String())
+			errc <- s.srv.ServeTLS(ln, "", "")
+		} else {
+			s.log.Infow("Serving HTTP", "addr", ln.Addr().String())
+			errc <- s.srv.Serve(ln)
+		}
+	}()
+
+	select {
+	case err := <-errc:
+		// The listener failed before a shutdown was requested.
+		s.shutdown()
+		return err
+	case <-ctx.Done():
+	}
+	s.log.Infow("Shutting down", "timeout", s.timeout)
+	err := s.shutdown()
+	if serveErr := <-errc; !errors.Is(serveErr, http.ErrServerClosed) {
+		err = errors.Join(err, serveErr)
+	}
+	return err
+}
+
+// shutdown drains connections and runs the hooks.
+func (s *Server) shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	var errs []error
+	if err := s.srv.Shutdown(ctx); err != nil {
+		s.log.Warnw("HTTP drain incomplete", "err", err)
+		errs = append(errs, err)
+	}
+	for _, h := range s.hooks {
+		if err := h.fn(ctx); err != nil {
+			s.log.Warnw("Shutdown step failed", "step", h.name, "err", err)
+			errs = append(errs, err)
+			continue
+		}
+		s.log.Infow("Shutdown step done", "step", h.name)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestServe_DrainsInFlightRequests(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "done")
+	})
+	srv := New(handler, Config{ShutdownTimeout: 5 * time.Second})
+	var steps []string
+	srv.OnShutdown("first", func(context.Context) error { steps = append(steps, "first"); return nil })
+	srv.OnShutdown("second", func(context.Context) error { steps = append(steps, "second"); return nil })
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen error: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(ctx, ln) }()
+
+	type result struct {
+		body string
+		err  error
+	}
+	resc := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String() + "/")
+		if err != nil {
+			resc <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		resc <- result{body: string(b), err: err}
+	}()
+
+	<-started
+	cancel()
+	select {
+	case err := <-served:
+		t.Fatalf("Serve returned %v before the in-flight request finished", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+
+	if res := <-resc; res.err != nil || res.body != "done" {
+		t.Errorf("response = %q, %v; want done", res.body, res.err)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("Serve error = %v; want nil", err)
+	}
+	if len(steps) != 2 || steps[0] != "first" || steps[1] != "second" {
+		t.Errorf("shutdown steps = %v; want [first second]", steps)
+	}
+}
+
+func TestServe_ReportsHookErrors(t *testing.T) {
+	srv := New(http.NotFoundHandler(), Config{})
+	boom := errors.New("boom")
+	srv.OnShutdown("broken", func(context.Context) error { return boom })
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen error: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := srv.Serve(ctx, ln); !errors.Is(err, boom) {
+		t.Errorf("Serve error = %v; want %v", err, boom)
+	}
+}
@@ -0,0 +1,84 @@
+package servertls
+
+import (
+	"crypto/x509"
+	"fmt"
+
+	"github.com/NlightN22/OTPSMSProvider/apikey"
+)
+
+// SourceCertificate marks keys derived from a client certificate.
+const SourceCertificate = "certificate"
+
+// Client maps a certificate to an API client. A certificate matches when its
+// subject common name equals Subject or any DNS, email or URI SAN equals SAN.
+type Client struct {
+	Name          string
+	Subject       string
+	SAN           string
+	Scopes        []string
+	PerMinute     float64
+	Burst         int
+	PhonePrefixes []string
+}
+
+// ClientMap resolves verified client certificates to API identities.
+type ClientMap struct {
+	clients []Client
+}
+
+// NewClientMap validates the client definitions.
+func NewClientMap(clients []Client) (*ClientMap, error) {
+	for _, c := range clients {
+		if c.Name == "" || (c.Subject == "" && c.SAN == "") {
+			return nil, fmt.Errorf("certificate client %q needs a name and a subject or SAN", c.Name)
+		}
+		if err := apikey.ValidateScopes(c.Scopes); err != nil {
+			return nil, fmt.Errorf("certificate client %q: %w", c.Name, err)
+		}
+	}
+	return &ClientMap{clients: clients}, nil
+}
+
+// AuthenticateCert returns the identity of a verified leaf certificate.
+func (m *ClientMap) AuthenticateCert(cert *x509.Certificate) (apikey.Key, bool) {
+	for _, c := range m.clients {
+		if matches(c, cert) {
+			return apikey.Key{
+				ID:            "cert:" + c.Name,
+				Name:          c.Name,
+				Scopes:        c.Scopes,
+				PerMinute:     c.PerMinute,
+				Burst:         c.Burst,
+				PhonePrefixes: c.PhonePrefixes,
+				Source:        SourceCertificate,
+			}, true
+		}
+	}
+	return apikey.Key{}, false
+}
+
+func matches(c Client, cert *x509.Certificate) bool {
+	if c.Subject != "" && cert.Subject.CommonName == c.Subject {
+		return true
+	}
+	if c.SAN == "" {
+		return false
+	}
+	for _, n := range cert.DNSNames {
+		if n == c.SAN {
+			return true
+		}
+	}
+	for _, e := range cert.EmailAddresses {
+		if e == c.SAN {
+			return true
+		}
+	}
+	for _, u := range cert.URIs {
+		if u.String() == c.SAN {
+			return true
+		}
+	}
+	return false
+}
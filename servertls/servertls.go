@@ -0,0 +1,170 @@
+// Package servertls builds the TLS configuration of the HTTP listener:
+// hot-reloaded server certificates and optional client certificate checks.
+package servertls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	logger "github.com/NlightN22/OTPSMSProvider/pkg"
+	"go.uber.org/zap"
+)
+
+// Client certificate modes.
+const (
+	ClientAuthNone    = "none"    // no client certificate requested
+	ClientAuthRequest = "request" // verified when presented, API keys still accepted
+	ClientAuthRequire = "require" // every connection needs a certificate from the client CA
+)
+
+// Config describes the listener certificates.
+type Config struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string // PEM bundle of CAs allowed to issue client certificates
+	ClientAuth   string // one of the ClientAuth* modes, empty for none
+}
+
+// Reloader serves the current certificate and client CA pool, re-reading the files when they change.
+type Reloader struct {
+	cfg  Config
+	mode tls.ClientAuthType
+	log  *zap.SugaredLogger
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	pool    *x509.CertPool
+	modTime time.Time
+}
+
+// New loads the files in cfg.
+func New(cfg Config) (*Reloader, error) {
+	r := &Reloader{cfg: cfg, log: logger.New("TLS")}
+	switch cfg.ClientAuth {
+	case "", ClientAuthNone:
+		r.mode = tls.NoClientCert
+	case ClientAuthRequest:
+		r.mode = tls.VerifyClientCertIfGiven
+	case ClientAuthRequire:
+		r.mode = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("unknown client auth mode %q", cfg.ClientAuth)
+	}
+	if r.mode != tls.NoClientCert && cfg.ClientCAFile == "" {
+		return nil, fmt.Errorf("client auth %q needs a client CA file", cfg.ClientAuth)
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// TLSConfig returns a server configuration that always uses the latest loaded
+// files. It offers HTTP/2 and HTTP/1.1; the per-connection configuration
+// copies the protocols of the returned one, which net/http may extend.
+func (r *Reloader) TLSConfig() *tls.Config {
+	base := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		return &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			NextProtos:   base.NextProtos,
+			Certificates: []tls.Certificate{*r.cert},
+			ClientAuth:   r.mode,
+			ClientCAs:    r.pool,
+		}, nil
+	}
+	return base
+}
+
+// Watch reloads the files when they change, until ctx is done.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := r.changed()
+			if err == nil && changed {
+				err = r.load()
+			}
+			if err != nil {
+				// Keep serving the previous certificate.
+				r.log.Errorw("TLS reload failed", "err", err)
+			}
+		}
+	}
+}
+
+func (r *Reloader) files() []string {
+	files := []string{r.cfg.CertFile, r.cfg.KeyFile}
+	if r.cfg.ClientCAFile != "" {
+		files = append(files, r.cfg.ClientCAFile)
+	}
+	return files
+}
+
+func (r *Reloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, f := range r.files() {
+		info, err := os.Stat(f)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func (r *Reloader) changed() (bool, error) {
+	latest, err := r.latestModTime()
+	if err != nil {
+		return false, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return latest.After(r.modTime), nil
+}
+
+func (r *Reloader) load() error {
+	mod, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("load certificate: %w", err)
+	}
+	var pool *x509.CertPool
+	if r.cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.cfg.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("read client CA: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates in client CA file %s", r.cfg.ClientCAFile)
+		}
+	}
+	r.mu.Lock()
+	r.cert, r.pool, r.modTime = &cert, pool, mod
+	r.mu.Unlock()
+	r.log.Infow("TLS certificates loaded", "cert", r.cfg.CertFile, "client_auth", r.cfg.ClientAuth)
+	return nil
+}
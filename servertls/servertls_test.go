@@ -0,0 +1,184 @@
+package servertls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NlightN22/OTPSMSProvider/apikey"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+	kpem []byte
+}
+
+func issue(t *testing.T, tmpl *x509.Certificate, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl.SerialNumber = big.NewInt(time.Now().UnixNano())
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+	signerCert, signerKey := tmpl, key
+	if parent != nil {
+		signerCert, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signerCert, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	kder, _ := x509.MarshalECPrivateKey(key)
+	return &testCert{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		kpem: pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kder}),
+	}
+}
+
+func newCA(t *testing.T) *testCert {
+	return issue(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "test CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+}
+
+func write(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReloader_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newCA(t)
+	server := issue(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "otp"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca)
+	client := issue(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "shop-backend"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca)
+
+	r, err := New(Config{
+		CertFile:     write(t, dir, "server.pem", server.pem),
+		KeyFile:      write(t, dir, "server.key", server.kpem),
+		ClientCAFile: write(t, dir, "ca.pem", ca.pem),
+		ClientAuth:   ClientAuthRequire,
+	})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(req.TLS.VerifiedChains[0][0].Subject.CommonName))
+	}))
+	srv.EnableHTTP2 = true
+	srv.TLS = r.TLSConfig()
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	clientPair, err := tls.X509KeyPair(client.pem, client.kpem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withCert := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{clientPair}},
+		ForceAttemptHTTP2: true,
+	}}
+	resp, err := withCert.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("request with client certificate: %v", err)
+	}
+	resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Errorf("protocol = %s; want HTTP/2", resp.Proto)
+	}
+
+	withoutCert := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	if resp, err := withoutCert.Get(srv.URL); err == nil {
+		resp.Body.Close()
+		t.Errorf("request without client certificate succeeded; want handshake error")
+	}
+}
+
+func TestReloader_Reload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newCA(t)
+	first := issue(t, &x509.Certificate{Subject: pkix.Name{CommonName: "first"}}, ca)
+	certPath := write(t, dir, "server.pem", first.pem)
+	keyPath := write(t, dir, "server.key", first.kpem)
+	r, err := New(Config{CertFile: certPath, KeyFile: keyPath})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+
+	second := issue(t, &x509.Certificate{Subject: pkix.Name{CommonName: "second"}}, ca)
+	write(t, dir, "server.pem", second.pem)
+	write(t, dir, "server.key", second.kpem)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(certPath, future, future)
+	if changed, err := r.changed(); err != nil || !changed {
+		t.Fatalf("changed = %v, %v; want true", changed, err)
+	}
+	if err := r.load(); err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	cfg, _ := r.TLSConfig().GetConfigForClient(nil)
+	leaf, _ := x509.ParseCertificate(cfg.Certificates[0].Certificate[0])
+	if leaf.Subject.CommonName != "second" {
+		t.Errorf("served certificate = %q; want second", leaf.Subject.CommonName)
+	}
+
+	if _, err := New(Config{CertFile: certPath, KeyFile: keyPath, ClientAuth: ClientAuthRequire}); err == nil {
+		t.Errorf("New with require and no CA error = nil; want error")
+	}
+}
+
+func TestClientMap(t *testing.T) {
+	m, err := NewClientMap([]Client{
+		{Name: "shop", Subject: "shop-backend", Scopes: []string{apikey.ScopeSend}},
+		{Name: "crm", SAN: "crm.internal", Scopes: []string{apikey.ScopeVerify}, PhonePrefixes: []string{"+7"}},
+	})
+	if err != nil {
+		t.Fatalf("NewClientMap error: %v", err)
+	}
+	if k, ok := m.AuthenticateCert(&x509.Certificate{Subject: pkix.Name{CommonName: "shop-backend"}}); !ok || k.Name != "shop" || !k.HasScope(apikey.ScopeSend) {
+		t.Errorf("subject match = %+v, %v", k, ok)
+	}
+	if k, ok := m.AuthenticateCert(&x509.Certificate{DNSNames: []string{"crm.internal"}}); !ok || k.Name != "crm" || k.AllowsPhone("+1555") {
+		t.Errorf("SAN match = %+v, %v", k, ok)
+	}
+	if _, ok := m.AuthenticateCert(&x509.Certificate{Subject: pkix.Name{CommonName: "other"}}); ok {
+		t.Errorf("unknown certificate matched")
+	}
+	if _, err := NewClientMap([]Client{{Name: "x", Subject: "x", Scopes: []string{"root"}}}); err == nil {
+		t.Errorf("NewClientMap with bad scope error = nil; want error")
+	}
+}
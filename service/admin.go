@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	logger "github.com/NlightN22/OTPSMSProvider/pkg"
+	storage "github.com/NlightN22/OTPSMSProvider/storage"
+)
+
+// PhoneState is what the service keeps about a phone, for operators.
+type PhoneState struct {
+	Phone       string             `json:"phone" example:"+79991234567"`
+	LastSend    *time.Time         `json:"last_send,omitempty"`
+	NextSendIn  int                `json:"next_send_in"`           // seconds until the interval allows another send
+	Failures    int                `json:"failures"`               // wrong codes counted towards the lockout, over all purposes
+	LockedUntil *time.Time         `json:"locked_until,omitempty"` // end of the latest lock of any purpose
+	Purposes    []string           `json:"purposes"`               // purposes with a secret; "" is the default purpose
+	Deliveries  []storage.Delivery `json:"deliveries"`
+}
+
+// State returns the send, lockout and delivery state of phone.
+func (s *TotpService) State(ctx context.Context, phone string) PhoneState {
+	st := PhoneState{Phone: phone, Purposes: s.purposesOf(phone), Deliveries: s.store.GetDeliveries(phone)}
+	now := time.Now()
+	if last, ok := s.store.GetLastSend(phone); ok {
+		st.LastSend = &last
+		if wait := s.interval - now.Sub(last); wait > 0 {
+			st.NextSendIn = int(wait.Round(time.Second).Seconds())
+		}
+	}
+	for _, key := range s.lockoutKeys(phone) {
+		a, ok := s.store.GetAttempts(key)
+		if !ok {
+			continue
+		}
+		if now.Sub(a.FirstFailure) <= s.lockout.Window {
+			st.Failures += a.Failures
+		}
+		if now.Before(a.LockedUntil) && (st.LockedUntil == nil || a.LockedUntil.After(*st.LockedUntil)) {
+			until := a.LockedUntil
+			st.LockedUntil = &until
+		}
+	}
+	if st.Deliveries == nil {
+		st.Deliveries = []storage.Delivery{}
+	}
+	return st
+}
+
+// ResetLimits clears the send interval and the lockout of phone and reports
+// whether there was anything to clear.
+func (s *TotpService) ResetLimits(ctx context.Context, phone string) bool {
+	cleared := s.store.DeleteLastSend(phone)
+	for _, key := range s.lockoutKeys(phone) {
+		s.store.UpdateAttempts(key, func(a storage.Attempts, ok bool) storage.Attempts {
+			cleared = cleared || ok
+			return storage.Attempts{}
+		})
+	}
+	logger.For(ctx, s.log).Infow("Limits reset", "phone", phone, "cleared", cleared)
+	return cleared
+}
+
+// RevokeSecrets deletes the secrets of phone for every purpose, so codes
+// already sent stop working, and returns how many were deleted.
+func (s *TotpService) RevokeSecrets(ctx context.Context, phone string) int {
+	n := 0
+	for _, p := range s.purposesOf(phone) {
+		if s.store.DeleteSecret(secretKey(phone, p)) {
+			n++
+		}
+	}
+	logger.For(ctx, s.log).Infow("Secrets revoked", "phone", phone, "count", n)
+	return n
+}
+
+// purposesOf returns the purposes phone has a secret for.
+func (s *TotpService) purposesOf(phone string) []string {
+	purposes := []string{}
+	for _, k := range s.store.SecretKeys(phone) {
+		// The prefix also matches longer numbers; keep the phone itself and its "|purpose" keys.
+		if k == phone {
+			purposes = append(purposes, "")
+		} else if p, ok := strings.CutPrefix(k, phone+"|"); ok {
+			purposes = append(purposes, p)
+		}
+	}
+	sort.Strings(purposes)
+	return purposes
+}
+
+// lockoutKeys returns the lockout keys of phone: the default purpose, the
+// configured purposes and those phone has a secret for.
+func (s *TotpService) lockoutKeys(phone string) []string {
+	seen := map[string]bool{"": true}
+	keys := []string{secretKey(phone, "")}
+	add := func(p string) {
+		if !seen[p] {
+			seen[p] = true
+			keys = append(keys, secretKey(phone, p))
+		}
+	}
+	for p := range s.purposes {
+		add(p)
+	}
+	for _, p := range s.purposesOf(phone) {
+		add(p)
+	}
+	return keys
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+)
+
+// DeliveryReport is the final status of a sent message reported by the provider.
+type DeliveryReport struct {
+	MessageID string
+	Phone     string
+	Delivered bool
+	Reason    string // why delivery failed
+}
+
+// ReportDelivery emits EventCodeDelivered or EventDeliveryFailed for r.
+func (s *TotpService) ReportDelivery(ctx context.Context, r DeliveryReport) {
+	e := Event{Type: EventCodeDelivered, Phone: r.Phone, MessageID: r.MessageID}
+	if !r.Delivered {
+		e.Type, e.Err = EventDeliveryFailed, errors.New(r.Reason)
+	}
+	s.emit(ctx, e)
+}
+
+// ParseSMSCStatus reads an SMSC status callback. ok is false for intermediate
+// statuses, which carry no final outcome.
+// See https://smsc.ru/api/http/status_messages/statuses/ for the codes.
+func ParseSMSCStatus(form url.Values) (r DeliveryReport, ok bool) {
+	r = DeliveryReport{MessageID: form.Get("id"), Phone: form.Get("phone")}
+	if r.MessageID == "" {
+		return r, false
+	}
+	switch form.Get("status") {
+	case "1":
+		r.Delivered = true
+	case "3":
+		r.Reason = "expired"
+	case "20":
+		r.Reason = "undeliverable"
+	case "22":
+		r.Reason = "invalid number"
+	case "23":
+		r.Reason = "prohibited"
+	case "24":
+		r.Reason = "insufficient funds"
+	case "25":
+		r.Reason = "unavailable number"
+	default:
+		return r, false
+	}
+	if r.Phone != "" && r.Phone[0] != '+' {
+		r.Phone = "+" + r.Phone // SMSC reports numbers without the plus
+	}
+	return r, true
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// Event types emitted by TotpService.
+const (
+	EventCodeSent              = "code.sent"            // the provider accepted the message
+	EventSendFailed            = "code.send_failed"     // rendering or the provider failed
+	EventCodeDelivered         = "code.delivered"       // the provider reported delivery to the handset
+	EventDeliveryFailed        = "code.delivery_failed" // the provider gave up delivering
+	EventVerificationSucceeded = "verification.succeeded"
+	EventVerificationFailed    = "verification.failed"
+	EventLockout               = "lockout" // too many wrong codes locked the phone
+)
+
+// EventTypes lists every event type, for validating subscriptions.
+var EventTypes = []string{
+	EventCodeSent, EventSendFailed, EventCodeDelivered, EventDeliveryFailed,
+	EventVerificationSucceeded, EventVerificationFailed, EventLockout,
+}
+
+// Event describes one step of the send or verify flow. Phone is the full
+// number; handlers that persist or forward it decide how to mask it.
+type Event struct {
+	Type        string
+	Time        time.Time
+	Phone       string
+	Purpose     string
+	MessageID   string    // set on EventCodeSent and delivery events
+	Segments    int       // set on EventCodeSent
+	LockedUntil time.Time // set on EventLockout
+	Err         error     // set on EventSendFailed, EventDeliveryFailed, and EventVerificationFailed while locked
+}
+
+// EventHandler observes service events. It runs synchronously in the request,
+// with the request context, so slow handlers must hand work off.
+type EventHandler func(ctx context.Context, e Event)
+
+// WithEventHandler adds h to the handlers called for every event.
+func WithEventHandler(h EventHandler) Option {
+	return func(s *TotpService) {
+		s.handlers = append(s.handlers, h)
+	}
+}
+
+// emit stamps e and passes it to the handlers.
+func (s *TotpService) emit(ctx context.Context, e Event) {
+	e.Time = time.Now()
+	for _, h := range s.handlers {
+		h(ctx, e)
+	}
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	storage "github.com/NlightN22/OTPSMSProvider/storage"
+)
+
+// ErrLocked marks a verification refused because the phone is locked out.
+var ErrLocked = errors.New("phone is locked after too many wrong codes")
+
+// Lockout locks a phone out of verification for a purpose after repeated
+// wrong codes for it.
+type Lockout struct {
+	MaxFailures int           // wrong codes within Window that lock the phone, 0 disables the lockout
+	Window      time.Duration // failures older than this are forgotten
+	Duration    time.Duration // how long the phone stays locked
+}
+
+// WithLockout enables the verification lockout.
+func WithLockout(l Lockout) Option {
+	return func(s *TotpService) {
+		s.lockout = l
+	}
+}
+
+// reserveAttempt counts a verification of key as failed before its code is
+// checked, so concurrent guesses cannot pass the limit. It returns false when
+// key is locked or already has MaxFailures attempts in progress.
+func (s *TotpService) reserveAttempt(ctx context.Context, key string) (until time.Time, ok bool) {
+	if s.lockout.MaxFailures <= 0 {
+		return time.Time{}, true
+	}
+	now := time.Now()
+	ok = true
+	sp := storeSpan(ctx, "UpdateAttempts")
+	defer sp.End()
+	s.store.UpdateAttempts(key, func(a storage.Attempts, found bool) storage.Attempts {
+		if found && now.Before(a.LockedUntil) {
+			until, ok = a.LockedUntil, false
+			return a
+		}
+		if !found || now.Sub(a.FirstFailure) > s.lockout.Window {
+			a = storage.Attempts{FirstFailure: now}
+		}
+		if a.Failures >= s.lockout.MaxFailures {
+			ok = false
+			return a
+		}
+		a.Failures++
+		return a
+	})
+	return until, ok
+}
+
+// settleAttempt finishes an attempt reserved on key: a valid code clears the
+// record, a wrong one keeps the reserved failure and locks key once it reaches
+// MaxFailures. It returns the end of the lock it started, zero if none.
+func (s *TotpService) settleAttempt(ctx context.Context, key string, valid bool) time.Time {
+	if s.lockout.MaxFailures <= 0 {
+		return time.Time{}
+	}
+	now := time.Now()
+	var until time.Time
+	sp := storeSpan(ctx, "UpdateAttempts")
+	defer sp.End()
+	s.store.UpdateAttempts(key, func(a storage.Attempts, ok bool) storage.Attempts {
+		if ok && now.Before(a.LockedUntil) {
+			return a // locked by a concurrent verification
+		}
+		if valid {
+			return storage.Attempts{}
+		}
+		if a.Failures >= s.lockout.MaxFailures {
+			// The failures that caused the lock are cleared; counting starts again after it.
+			until = now.Add(s.lockout.Duration)
+			return storage.Attempts{LockedUntil: until}
+		}
+		return a
+	})
+	return until
+}
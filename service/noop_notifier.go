@@ -1,6 +1,9 @@
 package service
 
 import (
+	"context"
+
+	"github.com/NlightN22/OTPSMSProvider/message"
 	logger "github.com/NlightN22/OTPSMSProvider/pkg"
 	"go.uber.org/zap"
 )
@@ -14,7 +17,7 @@ func NewNoopNotifier() *NoopNotifier {
 	return &NoopNotifier{log: l}
 }
 
-func (n *NoopNotifier) Send(to, msg string) error {
-	n.log.Infow("DEBUG mode — skipping SMS send", "to", to, "message", msg)
-	return nil
+func (n *NoopNotifier) Send(ctx context.Context, to, msg string) (SendResult, error) {
+	logger.For(ctx, n.log).Infow("DEBUG mode — skipping SMS send", "to", to, "message", msg)
+	return SendResult{Segments: message.Analyze(msg).Segments}, nil
 }
@@ -1,5 +1,15 @@
 package service
 
+import "context"
+
+// SendResult describes a message accepted by a provider.
+type SendResult struct {
+	MessageID string // provider message ID, empty if the provider has none
+	Segments  int    // billed SMS parts, 0 if the provider did not report it
+}
+
+// Notifier delivers a rendered message to a phone. Implementations calling a
+// remote API should pass ctx on, so the trace context reaches the provider.
 type Notifier interface {
-	Send(phone, code string) error
+	Send(ctx context.Context, phone, message string) (SendResult, error)
 }
@@ -1,10 +1,33 @@
 package service
 
-import "time"
+import (
+	"context"
+	"time"
+)
+
+// SendOptions carries per-request parameters of a code send.
+type SendOptions struct {
+	Locale          string            // BCP 47 tag of the message language, empty for the default
+	Channel         string            // delivery channel, empty for SMS
+	Purpose         string            // what the code confirms, e.g. "login"
+	TemplateID      string            // named message template, empty for the default
+	ClientReference string            // caller's own identifier, echoed in logs
+	Metadata        map[string]string // opaque caller data kept with the delivery record
+	Payload         string            // data the code is bound to, e.g. amount and payee; must be repeated on verify
+}
+
+// VerifyOptions carries per-request parameters of a code check. They must match the send.
+type VerifyOptions struct {
+	Purpose string
+	Payload string
+}
+
+// ChannelSMS is the only delivery channel so far.
+const ChannelSMS = "sms"
 
 // OTPService defines business logic for TOTP.
 type OTPService interface {
-	GenerateCode(key string) (code string, err error)
-	ValidateCode(key, code string) bool
-	CanSend(key string) (bool, time.Duration)
+	GenerateCode(ctx context.Context, key string, opts SendOptions) (code string, err error)
+	ValidateCode(ctx context.Context, key, code string, opts VerifyOptions) bool
+	CanSend(ctx context.Context, key string) (bool, time.Duration)
 }
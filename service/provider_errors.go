@@ -0,0 +1,62 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error classes returned by notifiers. Use errors.Is to test them.
+var (
+	// ErrProviderRetryable marks failures that may succeed if the send is repeated later.
+	ErrProviderRetryable = errors.New("provider: retryable error")
+	// ErrProviderPermanent marks failures that will not succeed without changing the request or account.
+	ErrProviderPermanent = errors.New("provider: permanent error")
+)
+
+// Specific provider failure kinds. Every kind is also either retryable or permanent.
+var (
+	ErrInvalidPhone        = errors.New("invalid phone number")
+	ErrUndeliverable       = errors.New("message cannot be delivered to this number")
+	ErrInsufficientFunds   = errors.New("insufficient funds")
+	ErrProviderAuth        = errors.New("provider authentication failed")
+	ErrMessageRejected     = errors.New("message rejected by provider")
+	ErrInvalidRequest      = errors.New("invalid provider request")
+	ErrProviderThrottled   = errors.New("provider throttled the request")
+	ErrProviderUnavailable = errors.New("provider unavailable")
+	// ErrProviderResponse covers replies that cannot be read or carry an unknown
+	// error code. It is permanent: the provider may have accepted the message.
+	ErrProviderResponse = errors.New("unexpected provider response")
+)
+
+// ProviderError describes a failed send reported by an SMS provider.
+type ProviderError struct {
+	Provider  string
+	Code      int
+	Message   string
+	Kind      error
+	Retryable bool
+}
+
+func (e *ProviderError) Error() string {
+	if e.Code != 0 {
+		return fmt.Sprintf("%s API error %d: %s", e.Provider, e.Code, e.Message)
+	}
+	return fmt.Sprintf("%s error: %s", e.Provider, e.Message)
+}
+
+// Unwrap exposes both the failure kind and its class to errors.Is.
+func (e *ProviderError) Unwrap() []error {
+	class := ErrProviderPermanent
+	if e.Retryable {
+		class = ErrProviderRetryable
+	}
+	if e.Kind == nil {
+		return []error{class}
+	}
+	return []error{e.Kind, class}
+}
+
+// IsRetryable reports whether err is a provider failure worth retrying.
+func IsRetryable(err error) bool {
+	return errors.Is(err, ErrProviderRetryable)
+}
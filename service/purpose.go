@@ -0,0 +1,81 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+
+	"github.com/pquerna/otp"
+)
+
+// ErrUnknownPurpose is returned for a purpose outside the configured set: any
+// purpose when none are configured, and none at all when some are.
+var ErrUnknownPurpose = errors.New("unknown code purpose")
+
+// Purpose overrides code settings for one action, e.g. "payment".
+// Zero fields keep the service defaults.
+type Purpose struct {
+	Period     uint       // seconds a code step lasts
+	Digits     otp.Digits // code length
+	TemplateID string     // message template used when the request names none
+}
+
+// WithPurposes restricts purposes to the given set and applies their settings.
+// Every send and verification must then name one of them. Without it only the
+// empty default purpose is accepted.
+func WithPurposes(p map[string]Purpose) Option {
+	return func(s *TotpService) {
+		s.purposes = p
+	}
+}
+
+// purpose resolves the settings of name. The empty name is the default flow,
+// which exists only while no purposes are configured.
+func (s *TotpService) purpose(name string) (Purpose, error) {
+	p := Purpose{Period: s.period, Digits: s.digits}
+	if s.purposes == nil {
+		if name != "" {
+			return p, ErrUnknownPurpose
+		}
+		return p, nil
+	}
+	cfg, ok := s.purposes[name]
+	if !ok {
+		return p, ErrUnknownPurpose
+	}
+	if cfg.Period > 0 {
+		p.Period = cfg.Period
+	}
+	if cfg.Digits > 0 {
+		p.Digits = cfg.Digits
+	}
+	p.TemplateID = cfg.TemplateID
+	return p, nil
+}
+
+// secretKey scopes the stored secret to the purpose, so a login code cannot confirm a payment.
+func secretKey(phone, purpose string) string {
+	if purpose == "" {
+		return phone
+	}
+	return phone + "|" + purpose
+}
+
+// usedCodeKey is the storage nonce marking code as spent for the secret at key.
+func usedCodeKey(key, code string) string {
+	return "code|" + key + "|" + code
+}
+
+var secretEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// linkSecret derives a per-payload secret, binding the code to e.g. a
+// transaction amount and payee. The same payload must be presented on verify.
+func linkSecret(secret, payload string) string {
+	if payload == "" {
+		return secret
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return secretEncoding.EncodeToString(mac.Sum(nil)[:20])
+}
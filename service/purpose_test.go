@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/NlightN22/OTPSMSProvider/storage"
+	"github.com/pquerna/otp"
+)
+
+func newPurposeService(notifier *stubNotifier) *TotpService {
+	return NewTotpService(storage.NewMemoryStorage(), "test", 60, otp.DigitsSix, otp.AlgorithmSHA1, 1, 0, notifier,
+		WithPurposes(map[string]Purpose{
+			"login":   {},
+			"payment": {Digits: otp.DigitsEight, Period: 120},
+		}))
+}
+
+func TestPurpose_ScopesCodes(t *testing.T) {
+	svc := newPurposeService(&stubNotifier{})
+
+	code, err := svc.GenerateCode(context.Background(), "123", SendOptions{Purpose: "login"})
+	if err != nil {
+		t.Fatalf("GenerateCode error: %v", err)
+	}
+	if !svc.ValidateCode(context.Background(), "123", code, VerifyOptions{Purpose: "login"}) {
+		t.Errorf("login code rejected for login")
+	}
+	if svc.ValidateCode(context.Background(), "123", code, VerifyOptions{Purpose: "payment"}) {
+		t.Errorf("login code accepted for payment")
+	}
+	if svc.ValidateCode(context.Background(), "123", code, VerifyOptions{}) {
+		t.Errorf("login code accepted without purpose")
+	}
+}
+
+func TestPurpose_Settings(t *testing.T) {
+	notifier := &stubNotifier{}
+	svc := newPurposeService(notifier)
+
+	code, err := svc.GenerateCode(context.Background(), "123", SendOptions{Purpose: "payment"})
+	if err != nil {
+		t.Fatalf("GenerateCode error: %v", err)
+	}
+	if len(code) != 8 {
+		t.Errorf("payment code %q; want 8 digits", code)
+	}
+	if _, err := svc.GenerateCode(context.Background(), "123", SendOptions{Purpose: "unknown"}); !errors.Is(err, ErrUnknownPurpose) {
+		t.Errorf("GenerateCode(unknown) error = %v; want ErrUnknownPurpose", err)
+	}
+}
+
+func TestPurpose_PayloadLinking(t *testing.T) {
+	svc := newPurposeService(&stubNotifier{})
+	payload := "EUR 120.00 to DE89370400440532013000"
+
+	code, err := svc.GenerateCode(context.Background(), "123", SendOptions{Purpose: "payment", Payload: payload})
+	if err != nil {
+		t.Fatalf("GenerateCode error: %v", err)
+	}
+	if !svc.ValidateCode(context.Background(), "123", code, VerifyOptions{Purpose: "payment", Payload: payload}) {
+		t.Errorf("code rejected for its payload")
+	}
+	if svc.ValidateCode(context.Background(), "123", code, VerifyOptions{Purpose: "payment", Payload: "EUR 9999.00 to DE89370400440532013000"}) {
+		t.Errorf("code accepted for a different payload")
+	}
+}
+
+func TestPurpose_DefaultsWithoutConfig(t *testing.T) {
+	svc := NewTotpService(&stubStorage{}, "test", 60, otp.DigitsSix, otp.AlgorithmSHA1, 1, time.Second, &stubNotifier{})
+	if _, err := svc.GenerateCode(context.Background(), "123", SendOptions{}); err != nil {
+		t.Errorf("GenerateCode error = %v; want nil for the default purpose", err)
+	}
+	if _, err := svc.GenerateCode(context.Background(), "123", SendOptions{Purpose: "anything"}); !errors.Is(err, ErrUnknownPurpose) {
+		t.Errorf("GenerateCode(anything) error = %v; want ErrUnknownPurpose when no purposes are configured", err)
+	}
+}
+
+func TestPurpose_RequiredWhenConfigured(t *testing.T) {
+	svc := newPurposeService(&stubNotifier{})
+	if _, err := svc.GenerateCode(context.Background(), "123", SendOptions{}); !errors.Is(err, ErrUnknownPurpose) {
+		t.Errorf("GenerateCode without purpose error = %v; want ErrUnknownPurpose", err)
+	}
+	if svc.ValidateCode(context.Background(), "123", "123456", VerifyOptions{}) {
+		t.Errorf("ValidateCode without purpose = true; want false")
+	}
+}
@@ -1,56 +1,130 @@
-// service/smsc_service_alt.go
+// service/smsc_service.go
 package service
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	logger "github.com/NlightN22/OTPSMSProvider/pkg"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 	"go.uber.org/zap"
 )
 
+// DefaultSMSCBaseURL is used when SMSCConfig.BaseURL is empty.
+const DefaultSMSCBaseURL = "https://smsc.ru"
+
+// SMSCConfig holds connection settings for the SMSC.ru HTTP API.
+type SMSCConfig struct {
+	Login    string
+	Password string
+	BaseURL  string        // API root, e.g. https://smsc.ru
+	Sender   string        // registered sender name, empty for the account default
+	CAFile   string        // optional PEM bundle used instead of system roots
+	Timeout  time.Duration // HTTP client timeout, 10s when zero
+}
+
 type SMSCService struct {
-	login         string
-	password      string
-	prefixMessage string
-	client        *http.Client
-	log           *zap.SugaredLogger
+	login    string
+	password string
+	sender   string
+	endpoint string
+	balance  string
+	client   *http.Client
+	log      *zap.SugaredLogger
 }
 
-func NewSMSCService(login, password, prefixMessage string) *SMSCService {
+func NewSMSCService(cfg SMSCConfig) (*SMSCService, error) {
 	svcLog := logger.New("SMSCService")
 
-	return &SMSCService{
-		login:         login,
-		password:      password,
-		prefixMessage: prefixMessage,
-		client:        &http.Client{Timeout: 10 * time.Second},
-		log:           svcLog,
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultSMSCBaseURL
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("smsc base url %q: %w", baseURL, err)
+	}
+	if u.Scheme != "https" {
+		svcLog.Warnw("smsc: base url is not https, credentials are sent in clear text", "url", baseURL)
+	}
+
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("smsc ca file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("smsc ca file %q: no certificates found", cfg.CAFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
 	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsCfg
+
+	return &SMSCService{
+		login:    cfg.Login,
+		password: cfg.Password,
+		sender:   cfg.Sender,
+		endpoint: strings.TrimRight(baseURL, "/") + "/sys/send.php",
+		balance:  strings.TrimRight(baseURL, "/") + "/sys/balance.php",
+		client:   &http.Client{Timeout: timeout, Transport: transport},
+		log:      svcLog,
+	}, nil
 }
 
-func (s *SMSCService) Send(phone, code string) error {
-	message := s.prefixMessage + code
-	s.log.Infow("smsc: message", message)
+func (s *SMSCService) Send(ctx context.Context, phone, message string) (SendResult, error) {
+	log := logger.For(ctx, s.log)
 	params := url.Values{
-		"login":  {s.login},
-		"psw":    {s.password},
-		"phones": {phone},
-		"mes":    {message},
-		"fmt":    {"3"},
-	}
-	endpoint := "https://smsc.ru/sys/send.php"
-	request := endpoint + "?" + params.Encode()
-	s.log.Debug("smsc: request: ", request)
-	resp, err := s.client.Get(request)
-	s.log.Debug("smsc: response: ", resp)
+		"login":   {s.login},
+		"psw":     {s.password},
+		"phones":  {phone},
+		"mes":     {message},
+		"fmt":     {"3"},
+		"charset": {"utf-8"},
+	}
+	if s.sender != "" {
+		params.Set("sender", s.sender)
+	}
+	log.Debugw("smsc: request", "endpoint", s.endpoint, "phone", phone, "sender", s.sender)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, strings.NewReader(params.Encode()))
 	if err != nil {
-		return fmt.Errorf("smsc send request error: %w", err)
+		return SendResult{}, &ProviderError{Provider: "smsc", Message: err.Error(), Kind: ErrInvalidRequest}
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// Forward the W3C trace context so a trace can be matched with the provider's request logs.
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	resp, err := s.client.Do(req)
+	if err != nil {
+		// url.Error repeats the request URL only, the form body with credentials is never part of it.
+		return SendResult{}, &ProviderError{Provider: "smsc", Message: err.Error(), Kind: ErrProviderUnavailable, Retryable: true}
 	}
 	defer resp.Body.Close()
+	log.Debugw("smsc: response", "status", resp.StatusCode)
+
+	if resp.StatusCode >= 500 {
+		return SendResult{}, &ProviderError{Provider: "smsc", Message: resp.Status, Kind: ErrProviderUnavailable, Retryable: true}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return SendResult{}, &ProviderError{Provider: "smsc", Message: resp.Status, Kind: ErrInvalidRequest}
+	}
 
 	var apiResp struct {
 		ID        int    `json:"id"`
@@ -59,11 +133,83 @@ func (s *SMSCService) Send(phone, code string) error {
 		Error     string `json:"error"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
-		return fmt.Errorf("smsc response parse error: %w", err)
+		return SendResult{}, &ProviderError{Provider: "smsc", Message: "response parse error: " + err.Error(), Kind: ErrProviderResponse}
 	}
 	if apiResp.ErrorCode != 0 {
-		return fmt.Errorf("smsc API error %d: %s", apiResp.ErrorCode, apiResp.Error)
+		return SendResult{}, smscError(apiResp.ErrorCode, apiResp.Error)
 	}
-	s.log.Infof("smsc: sent id=%d, parts=%d", apiResp.ID, apiResp.Cnt)
+	log.Infof("smsc: sent id=%d, parts=%d", apiResp.ID, apiResp.Cnt)
+	return SendResult{MessageID: strconv.Itoa(apiResp.ID), Segments: apiResp.Cnt}, nil
+}
+
+// smscError maps an SMSC error_code to a classified ProviderError.
+// See https://smsc.ru/api/http/send/sms/#errors for the list of codes.
+func smscError(code int, msg string) *ProviderError {
+	e := &ProviderError{Provider: "smsc", Code: code, Message: msg}
+	switch code {
+	case 1:
+		e.Kind = ErrInvalidRequest
+	case 2:
+		e.Kind = ErrProviderAuth
+	case 3:
+		e.Kind = ErrInsufficientFunds
+	case 4:
+		// IP temporarily blocked after too many failed requests.
+		e.Kind, e.Retryable = ErrProviderThrottled, true
+	case 5:
+		e.Kind = ErrInvalidRequest
+	case 6:
+		e.Kind = ErrMessageRejected
+	case 7:
+		e.Kind = ErrInvalidPhone
+	case 8:
+		e.Kind = ErrUndeliverable
+	case 9:
+		// Too many concurrent or identical requests.
+		e.Kind, e.Retryable = ErrProviderThrottled, true
+	default:
+		e.Kind = ErrProviderResponse
+	}
+	return e
+}
+
+// Balance returns the account balance. It doubles as a reachability and credentials check.
+func (s *SMSCService) Balance(ctx context.Context) (float64, error) {
+	params := url.Values{
+		"login": {s.login},
+		"psw":   {s.password},
+		"fmt":   {"3"},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.balance, strings.NewReader(params.Encode()))
+	if err != nil {
+		return 0, &ProviderError{Provider: "smsc", Message: err.Error(), Kind: ErrInvalidRequest}
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, &ProviderError{Provider: "smsc", Message: err.Error(), Kind: ErrProviderUnavailable, Retryable: true}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, &ProviderError{Provider: "smsc", Message: resp.Status, Kind: ErrProviderUnavailable, Retryable: resp.StatusCode >= 500}
+	}
+
+	var apiResp struct {
+		Balance   json.Number `json:"balance"`
+		ErrorCode int         `json:"error_code"`
+		Error     string      `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return 0, &ProviderError{Provider: "smsc", Message: "balance parse error: " + err.Error(), Kind: ErrProviderResponse}
+	}
+	if apiResp.ErrorCode != 0 {
+		return 0, smscError(apiResp.ErrorCode, apiResp.Error)
+	}
+	return apiResp.Balance.Float64()
+}
+
+// Close drops idle connections to the SMSC API.
+func (s *SMSCService) Close() error {
+	s.client.CloseIdleConnections()
 	return nil
 }
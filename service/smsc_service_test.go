@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestSMSC(t *testing.T, handler http.HandlerFunc) *SMSCService {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	s, err := NewSMSCService(SMSCConfig{
+		Login:    "user",
+		Password: "secret",
+		BaseURL:  srv.URL,
+		Sender:   "ACME",
+	})
+	if err != nil {
+		t.Fatalf("NewSMSCService error: %v", err)
+	}
+	return s
+}
+
+func TestSMSCSend_PostsForm(t *testing.T) {
+	s := newTestSMSC(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("method = %s; want POST", r.Method)
+		}
+		if r.URL.Path != "/sys/send.php" {
+			t.Errorf("path = %s; want /sys/send.php", r.URL.Path)
+		}
+		if r.URL.RawQuery != "" {
+			t.Errorf("query = %q; want empty", r.URL.RawQuery)
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Fatalf("ParseForm: %v", err)
+		}
+		want := map[string]string{
+			"login":  "user",
+			"psw":    "secret",
+			"phones": "+79990000000",
+			"mes":    "Code: 123456",
+			"sender": "ACME",
+		}
+		for k, v := range want {
+			if got := r.PostForm.Get(k); got != v {
+				t.Errorf("form %s = %q; want %q", k, got, v)
+			}
+		}
+		fmt.Fprint(w, `{"id":42,"cnt":1}`)
+	})
+
+	res, err := s.Send(context.Background(), "+79990000000", "Code: 123456")
+	if err != nil {
+		t.Fatalf("Send error: %v", err)
+	}
+	if res.MessageID != "42" || res.Segments != 1 {
+		t.Errorf("SendResult = %+v; want {42 1}", res)
+	}
+}
+
+func TestSMSCSend_ClassifiesErrors(t *testing.T) {
+	cases := []struct {
+		code      int
+		kind      error
+		retryable bool
+	}{
+		{2, ErrProviderAuth, false},
+		{3, ErrInsufficientFunds, false},
+		{7, ErrInvalidPhone, false},
+		{8, ErrUndeliverable, false},
+		{9, ErrProviderThrottled, true},
+		{42, ErrProviderResponse, false},
+	}
+	for _, tc := range cases {
+		s := newTestSMSC(t, func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, `{"error":"fail","error_code":%d}`, tc.code)
+		})
+		_, err := s.Send(context.Background(), "+79990000000", "123456")
+		if !errors.Is(err, tc.kind) {
+			t.Errorf("code %d: err = %v; want %v", tc.code, err, tc.kind)
+		}
+		if IsRetryable(err) != tc.retryable {
+			t.Errorf("code %d: IsRetryable = %v; want %v", tc.code, IsRetryable(err), tc.retryable)
+		}
+		var perr *ProviderError
+		if !errors.As(err, &perr) || perr.Code != tc.code {
+			t.Errorf("code %d: ProviderError = %+v", tc.code, perr)
+		}
+	}
+}
+
+func TestSMSCSend_ServerErrorIsRetryable(t *testing.T) {
+	s := newTestSMSC(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	_, err := s.Send(context.Background(), "+79990000000", "123456")
+	if !IsRetryable(err) {
+		t.Errorf("IsRetryable(%v) = false; want true", err)
+	}
+}
+
+func TestSMSCSend_UnreadableResponse(t *testing.T) {
+	s := newTestSMSC(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "OK - 1 SMS")
+	})
+	_, err := s.Send(context.Background(), "+79990000000", "123456")
+	var perr *ProviderError
+	if !errors.As(err, &perr) || !errors.Is(err, ErrProviderResponse) || IsRetryable(err) {
+		t.Errorf("err = %v; want a permanent ErrProviderResponse", err)
+	}
+}
+
+func TestSMSCBalance(t *testing.T) {
+	s := newTestSMSC(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/sys/balance.php" {
+			t.Errorf("path = %s; want /sys/balance.php", r.URL.Path)
+		}
+		if r.PostFormValue("psw") == "secret" {
+			fmt.Fprint(w, `{"balance":"125.50"}`)
+			return
+		}
+		fmt.Fprint(w, `{"error":"authorise error","error_code":2}`)
+	})
+
+	bal, err := s.Balance(context.Background())
+	if err != nil || bal != 125.5 {
+		t.Fatalf("Balance = %v, %v; want 125.5", bal, err)
+	}
+	s.password = "wrong"
+	if _, err := s.Balance(context.Background()); !errors.Is(err, ErrProviderAuth) {
+		t.Errorf("Balance error = %v; want ErrProviderAuth", err)
+	}
+}
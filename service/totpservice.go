@@ -1,13 +1,20 @@
 package service
 
 import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/NlightN22/OTPSMSProvider/message"
 	logger "github.com/NlightN22/OTPSMSProvider/pkg"
 	storage "github.com/NlightN22/OTPSMSProvider/storage"
 
 	"github.com/pquerna/otp"
 	"github.com/pquerna/otp/totp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -22,6 +29,22 @@ type TotpService struct {
 	interval time.Duration
 	log      *zap.SugaredLogger
 	notifier Notifier
+	renderer *message.Renderer
+	purposes map[string]Purpose
+	handlers []EventHandler
+	lockout  Lockout
+	inflight sync.WaitGroup
+	pending  atomic.Int64
+}
+
+// Option configures optional TotpService dependencies.
+type Option func(*TotpService)
+
+// WithRenderer sets the renderer used to build SMS texts.
+func WithRenderer(r *message.Renderer) Option {
+	return func(s *TotpService) {
+		s.renderer = r
+	}
 }
 
 // NewTotpService constructs TotpService with its own logger.
@@ -34,11 +57,12 @@ func NewTotpService(
 	skew uint,
 	interval time.Duration,
 	notifier Notifier,
+	opts ...Option,
 ) *TotpService {
 
 	svcLog := logger.New("TotpService")
 
-	return &TotpService{
+	s := &TotpService{
 		store:    store,
 		issuer:   issuer,
 		period:   period,
@@ -49,70 +73,220 @@ func NewTotpService(
 		log:      svcLog,
 		notifier: notifier,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.renderer == nil {
+		// Built-in templates always parse, so the error is impossible here.
+		s.renderer, _ = message.NewRenderer(message.Config{})
+	}
+	return s
+}
+
+// codeTTL is the longest time a code with the given period stays valid, including the allowed skew.
+func (s *TotpService) codeTTL(period uint) time.Duration {
+	return time.Duration(period*(s.skew+1)) * time.Second
 }
 
-func (s *TotpService) CanSend(key string) (bool, time.Duration) {
-	s.log.Infow("CanSend called", "phone", key)
-	if last, ok := s.store.GetLastSend(key); ok {
+func (s *TotpService) CanSend(ctx context.Context, key string) (bool, time.Duration) {
+	log := logger.For(ctx, s.log)
+	log.Infow("CanSend called", "phone", key)
+	sp := storeSpan(ctx, "GetLastSend")
+	last, ok := s.store.GetLastSend(key)
+	sp.End()
+	if ok {
 		since := time.Since(last)
 		if since < s.interval {
-			s.log.Infow("Rate limit", "since", since, "interval", s.interval)
+			log.Infow("Rate limit", "since", since, "interval", s.interval)
 			return false, s.interval - since
 		}
 	}
 	return true, 0
 }
 
-func (s *TotpService) GenerateCode(key string) (string, error) {
-	s.log.Infow("GenerateCode called", "phone", key)
-	secret, ok := s.store.GetSecret(key)
+func (s *TotpService) GenerateCode(ctx context.Context, key string, opts SendOptions) (code string, err error) {
+	s.inflight.Add(1)
+	s.pending.Add(1)
+	defer func() {
+		s.pending.Add(-1)
+		s.inflight.Done()
+	}()
+	ctx, span := tracer.Start(ctx, "TotpService.GenerateCode", trace.WithAttributes(
+		attribute.String("otp.purpose", opts.Purpose),
+		attribute.String("otp.template", opts.TemplateID),
+		attribute.String("otp.client_reference", opts.ClientReference),
+	))
+	defer func() {
+		endSpan(span, err)
+		if err != nil {
+			s.emit(ctx, Event{Type: EventSendFailed, Phone: key, Purpose: opts.Purpose, Err: err})
+		}
+	}()
+	log := logger.For(ctx, s.log)
+	log.Infow("GenerateCode called", "phone", key, "locale", opts.Locale,
+		"purpose", opts.Purpose, "template", opts.TemplateID, "reference", opts.ClientReference)
+	p, err := s.purpose(opts.Purpose)
+	if err != nil {
+		return "", fmt.Errorf("%w: %q", err, opts.Purpose)
+	}
+	skey := secretKey(key, opts.Purpose)
+	sp := storeSpan(ctx, "GetSecret")
+	secret, ok := s.store.GetSecret(skey)
+	sp.End()
 	if !ok {
 		opt := totp.GenerateOpts{
 			Issuer:      s.issuer,
-			AccountName: key,
-			Period:      s.period,
-			Digits:      s.digits,
+			AccountName: skey,
+			Period:      p.Period,
+			Digits:      p.Digits,
 			Algorithm:   s.algo,
 		}
 		token, err := totp.Generate(opt)
 		if err != nil {
-			s.log.Errorw("TOTP.Generate error", "err", err)
+			log.Errorw("TOTP.Generate error", "err", err)
 			return "", err
 		}
 		secret = token.Secret()
-		s.store.SaveSecret(key, secret)
+		sp := storeSpan(ctx, "SaveSecret")
+		s.store.SaveSecret(skey, secret)
+		sp.End()
 	}
-	s.store.SaveLastSend(key, time.Now())
 
-	code, err := totp.GenerateCodeCustom(secret, time.Now(),
-		totp.ValidateOpts{Period: s.period, Skew: s.skew, Digits: s.digits, Algorithm: s.algo})
+	code, err = totp.GenerateCodeCustom(linkSecret(secret, opts.Payload), time.Now(),
+		totp.ValidateOpts{Period: p.Period, Skew: s.skew, Digits: p.Digits, Algorithm: s.algo})
 	if err != nil {
-		s.log.Errorw("GenerateCodeCustom error", "err", err)
+		log.Errorw("GenerateCodeCustom error", "err", err)
 		return "", err
 	}
-	s.log.Debugw("Code generated", "code", code)
+	log.Debugw("Code generated")
+
+	templateID := opts.TemplateID
+	if templateID == "" {
+		templateID = p.TemplateID
+	}
+	text, err := s.renderer.RenderTemplate(templateID, opts.Locale, code, s.codeTTL(p.Period))
+	if err != nil {
+		log.Errorw("Render message error", "err", err)
+		return "", fmt.Errorf("render message: %w", err)
+	}
+	// Record the send only once the message is known to render, so a bad template ID does not start the interval.
+	sp = storeSpan(ctx, "SaveLastSend")
+	s.store.SaveLastSend(key, time.Now())
+	sp.End()
 
-	s.log.Debugw("Starting send code", "phone", key)
+	log.Debugw("Starting send code", "phone", key)
 
-	err = s.notifier.Send(key, code)
+	sendCtx, sendSpan := tracer.Start(ctx, "Notifier.Send", trace.WithSpanKind(trace.SpanKindClient))
+	res, err := s.notifier.Send(sendCtx, key, text)
+	if err == nil {
+		sendSpan.SetAttributes(attribute.String("otp.message_id", res.MessageID), attribute.Int("otp.segments", res.Segments))
+	}
+	endSpan(sendSpan, err)
 	if err != nil {
-		s.log.Errorw("Send code error", "err", err)
+		log.Errorw("Send code error", "err", err)
 		return "", err
 	}
 
-	s.log.Infow("Code generated and sended", "code", code)
+	analysis := message.Analyze(text)
+	if res.Segments == 0 {
+		res.Segments = analysis.Segments
+	}
+	sp = storeSpan(ctx, "SaveDelivery")
+	s.store.SaveDelivery(key, storage.Delivery{
+		MessageID: res.MessageID,
+		Segments:  res.Segments,
+		Encoding:  string(analysis.Encoding),
+		SentAt:    time.Now(),
+		Metadata:  opts.Metadata,
+	})
+	sp.End()
+	log.Infow("Message delivered to provider", "id", res.MessageID, "segments", res.Segments, "encoding", analysis.Encoding)
+
+	log.Infow("Code generated and sent", "phone", key)
+	s.emit(ctx, Event{Type: EventCodeSent, Phone: key, Purpose: opts.Purpose, MessageID: res.MessageID, Segments: res.Segments})
 	return code, nil
 }
 
-func (s *TotpService) ValidateCode(key, code string) bool {
-	s.log.Infow("ValidateCode called", "phone", key, "code", code)
-	secret, ok := s.store.GetSecret(key)
+// ValidateCode reports whether code is valid for key and consumes it, so each
+// code verifies at most once. With a lockout, a locked phone fails without
+// checking the code, so a caller cannot tell a locked phone from a wrong code.
+func (s *TotpService) ValidateCode(ctx context.Context, key, code string, opts VerifyOptions) (valid bool) {
+	ctx, span := tracer.Start(ctx, "TotpService.ValidateCode",
+		trace.WithAttributes(attribute.String("otp.purpose", opts.Purpose)))
+	defer span.End()
+	var (
+		lockErr  error
+		reserved bool // the attempt was counted towards the lockout
+	)
+	defer func() {
+		e := Event{Type: EventVerificationFailed, Phone: key, Purpose: opts.Purpose, Err: lockErr}
+		if valid {
+			e.Type = EventVerificationSucceeded
+		}
+		s.emit(ctx, e)
+		if !reserved {
+			return
+		}
+		if until := s.settleAttempt(ctx, secretKey(key, opts.Purpose), valid); !until.IsZero() {
+			logger.For(ctx, s.log).Warnw("Phone locked after too many wrong codes", "phone", key, "until", until)
+			s.emit(ctx, Event{Type: EventLockout, Phone: key, Purpose: opts.Purpose, LockedUntil: until})
+		}
+	}()
+	log := logger.For(ctx, s.log)
+	log.Infow("ValidateCode called", "phone", key, "purpose", opts.Purpose)
+	p, err := s.purpose(opts.Purpose)
+	if err != nil {
+		log.Warnw("Unknown purpose", "purpose", opts.Purpose)
+		return false
+	}
+	if until, ok := s.reserveAttempt(ctx, secretKey(key, opts.Purpose)); !ok {
+		log.Warnw("Phone locked out", "phone", key, "purpose", opts.Purpose, "until", until)
+		span.SetAttributes(attribute.Bool("otp.locked", true))
+		lockErr = ErrLocked
+		return false
+	}
+	reserved = true
+	sp := storeSpan(ctx, "GetSecret")
+	secret, ok := s.store.GetSecret(secretKey(key, opts.Purpose))
+	sp.End()
 	if !ok {
-		s.log.Warnw("No secret for phone", "phone", key)
+		log.Warnw("No secret for phone", "phone", key, "purpose", opts.Purpose)
 		return false
 	}
-	valid, _ := totp.ValidateCustom(code, secret, time.Now(),
-		totp.ValidateOpts{Period: s.period, Skew: s.skew, Digits: s.digits, Algorithm: s.algo})
-	s.log.Infow("Validation result", "valid", valid)
+	valid, _ = totp.ValidateCustom(code, linkSecret(secret, opts.Payload), time.Now(),
+		totp.ValidateOpts{Period: p.Period, Skew: s.skew, Digits: p.Digits, Algorithm: s.algo})
+	if valid {
+		// A code verifies once; replaying it must not mint another token.
+		sp = storeSpan(ctx, "UseNonce")
+		fresh := s.store.UseNonce(usedCodeKey(secretKey(key, opts.Purpose), code), time.Now().Add(s.codeTTL(p.Period)))
+		sp.End()
+		if !fresh {
+			log.Warnw("Code already used", "phone", key, "purpose", opts.Purpose)
+			valid = false
+		}
+	}
+	log.Infow("Validation result", "valid", valid)
+	span.SetAttributes(attribute.Bool("otp.valid", valid))
 	return valid
 }
+
+// Pending returns the number of GenerateCode calls in progress.
+func (s *TotpService) Pending() int {
+	return int(s.pending.Load())
+}
+
+// Drain waits until every GenerateCode call in progress has handed its message
+// to the notifier, or ctx is done.
+func (s *TotpService) Drain(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -1,18 +1,28 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/NlightN22/OTPSMSProvider/message"
+	"github.com/NlightN22/OTPSMSProvider/storage"
 	"github.com/pquerna/otp"
 )
 
 // stubStorage implements storage.Storage
 type stubStorage struct {
-	secret    string
-	hasSecret bool
-	lastSend  time.Time
-	hasLast   bool
+	secret     string
+	hasSecret  bool
+	lastSend   time.Time
+	hasLast    bool
+	deliveries []storage.Delivery
+	attempts   storage.Attempts
+	nonces     map[string]bool
 }
 
 func (s *stubStorage) GetSecret(key string) (string, bool) {
@@ -22,6 +32,17 @@ func (s *stubStorage) SaveSecret(key, secret string) {
 	s.secret = secret
 	s.hasSecret = true
 }
+func (s *stubStorage) DeleteSecret(key string) bool {
+	ok := s.hasSecret
+	s.secret, s.hasSecret = "", false
+	return ok
+}
+func (s *stubStorage) SecretKeys(prefix string) []string {
+	if !s.hasSecret {
+		return nil
+	}
+	return []string{prefix}
+}
 func (s *stubStorage) GetLastSend(key string) (time.Time, bool) {
 	return s.lastSend, s.hasLast
 }
@@ -29,18 +50,64 @@ func (s *stubStorage) SaveLastSend(key string, t time.Time) {
 	s.lastSend = t
 	s.hasLast = true
 }
+func (s *stubStorage) DeleteLastSend(key string) bool {
+	ok := s.hasLast
+	s.lastSend, s.hasLast = time.Time{}, false
+	return ok
+}
+func (s *stubStorage) SaveDelivery(key string, d storage.Delivery) {
+	s.deliveries = append(s.deliveries, d)
+}
+func (s *stubStorage) GetDeliveries(key string) []storage.Delivery {
+	return s.deliveries
+}
+func (s *stubStorage) AddSpend(period string, amount float64) float64 { return amount }
+func (s *stubStorage) GetSpend(period string) float64                 { return 0 }
+func (s *stubStorage) AddListEntry(list, entry string)                {}
+func (s *stubStorage) DeleteListEntry(list, entry string) bool        { return false }
+func (s *stubStorage) GetListEntries(list string) []string            { return nil }
+func (s *stubStorage) UpdateBucket(key string, fn func(storage.Bucket, bool) storage.Bucket) storage.Bucket {
+	return fn(storage.Bucket{}, false)
+}
+func (s *stubStorage) UpdateAttempts(key string, fn func(storage.Attempts, bool) storage.Attempts) storage.Attempts {
+	s.attempts = fn(s.attempts, s.attempts != storage.Attempts{})
+	return s.attempts
+}
+func (s *stubStorage) GetAttempts(key string) (storage.Attempts, bool) {
+	return s.attempts, s.attempts != storage.Attempts{}
+}
+func (s *stubStorage) SaveAPIKey(k storage.APIKey)                {}
+func (s *stubStorage) GetAPIKey(id string) (storage.APIKey, bool) { return storage.APIKey{}, false }
+func (s *stubStorage) DeleteAPIKey(id string) bool                { return false }
+func (s *stubStorage) ListAPIKeys() []storage.APIKey              { return nil }
+func (s *stubStorage) UseNonce(nonce string, expires time.Time) bool {
+	if s.nonces[nonce] {
+		return false
+	}
+	if s.nonces == nil {
+		s.nonces = make(map[string]bool)
+	}
+	s.nonces[nonce] = true
+	return true
+}
+func (s *stubStorage) AppendAudit(r storage.AuditRecord)                       {}
+func (s *stubStorage) LastAudit() (storage.AuditRecord, bool)                  { return storage.AuditRecord{}, false }
+func (s *stubStorage) ListAudit(after uint64, limit int) []storage.AuditRecord { return nil }
+func (s *stubStorage) Ping(ctx context.Context) error                          { return nil }
+func (s *stubStorage) Close() error                                            { return nil }
 
 // stubNotifier implements Notifier
 type stubNotifier struct {
-	sentTo   string
-	sentCode string
-	err      error
+	sentTo  string
+	sentMsg string
+	result  SendResult
+	err     error
 }
 
-func (n *stubNotifier) Send(to, code string) error {
+func (n *stubNotifier) Send(ctx context.Context, to, msg string) (SendResult, error) {
 	n.sentTo = to
-	n.sentCode = code
-	return n.err
+	n.sentMsg = msg
+	return n.result, n.err
 }
 
 func TestGenerateAndValidate(t *testing.T) {
@@ -48,25 +115,29 @@ func TestGenerateAndValidate(t *testing.T) {
 	notifier := &stubNotifier{}
 	svc := NewTotpService(store, "test", 30, otp.DigitsSix, otp.AlgorithmSHA1, 1, time.Second, notifier)
 
-	code, err := svc.GenerateCode("123")
+	code, err := svc.GenerateCode(context.Background(), "123", SendOptions{})
 	if err != nil {
 		t.Fatalf("GenerateCode error: %v", err)
 	}
 	if notifier.sentTo != "123" {
 		t.Errorf("Notifier sent to = %s; want %s", notifier.sentTo, "123")
 	}
-	if notifier.sentCode != code {
-		t.Errorf("Notifier code = %s; want %s", notifier.sentCode, code)
+	if !strings.Contains(notifier.sentMsg, code) {
+		t.Errorf("Notifier message = %q; want it to contain %s", notifier.sentMsg, code)
 	}
 
 	// Validate correct code
-	if !svc.ValidateCode("123", code) {
+	if !svc.ValidateCode(context.Background(), "123", code, VerifyOptions{}) {
 		t.Errorf("ValidateCode returned false for correct code")
 	}
 	// Validate wrong code
-	if svc.ValidateCode("123", "000000") {
+	if svc.ValidateCode(context.Background(), "123", "000000", VerifyOptions{}) {
 		t.Errorf("ValidateCode returned true for wrong code")
 	}
+	// Replay the correct code
+	if svc.ValidateCode(context.Background(), "123", code, VerifyOptions{}) {
+		t.Errorf("ValidateCode returned true for a code already used")
+	}
 }
 
 func TestCanSend(t *testing.T) {
@@ -75,7 +146,7 @@ func TestCanSend(t *testing.T) {
 	notifier := &stubNotifier{}
 	svc := NewTotpService(store, "test", 30, otp.DigitsSix, otp.AlgorithmSHA1, 1, 1*time.Second, notifier)
 
-	ok, wait := svc.CanSend("any")
+	ok, wait := svc.CanSend(context.Background(), "any")
 	if ok {
 		t.Errorf("CanSend = true; want false due to rate limit")
 	}
@@ -86,8 +157,297 @@ func TestCanSend(t *testing.T) {
 	// No last send
 	store2 := &stubStorage{hasLast: false}
 	svc2 := NewTotpService(store2, "test", 30, otp.DigitsSix, otp.AlgorithmSHA1, 1, 1*time.Second, notifier)
-	ok2, wait2 := svc2.CanSend("any")
+	ok2, wait2 := svc2.CanSend(context.Background(), "any")
 	if !ok2 || wait2 != 0 {
 		t.Errorf("CanSend = %v, wait = %v; want true,0", ok2, wait2)
 	}
 }
+
+func TestGenerateCode_Locale(t *testing.T) {
+	renderer, err := message.NewRenderer(message.Config{
+		Templates: map[string]string{"ru": "Код {{.Code}}, {{.ExpiryMinutes}} мин"},
+	})
+	if err != nil {
+		t.Fatalf("NewRenderer error: %v", err)
+	}
+	notifier := &stubNotifier{}
+	svc := NewTotpService(&stubStorage{}, "test", 60, otp.DigitsSix, otp.AlgorithmSHA1, 1, time.Second, notifier,
+		WithRenderer(renderer))
+
+	code, err := svc.GenerateCode(context.Background(), "123", SendOptions{Locale: "ru-RU"})
+	if err != nil {
+		t.Fatalf("GenerateCode error: %v", err)
+	}
+	if want := "Код " + code + ", 2 мин"; notifier.sentMsg != want {
+		t.Errorf("Notifier message = %q; want %q", notifier.sentMsg, want)
+	}
+}
+
+func TestGenerateCode_RecordsSegments(t *testing.T) {
+	store := &stubStorage{}
+	notifier := &stubNotifier{result: SendResult{MessageID: "42", Segments: 2}}
+	svc := NewTotpService(store, "test", 30, otp.DigitsSix, otp.AlgorithmSHA1, 1, time.Second, notifier)
+
+	if _, err := svc.GenerateCode(context.Background(), "123", SendOptions{Locale: "ru"}); err != nil {
+		t.Fatalf("GenerateCode error: %v", err)
+	}
+	if len(store.deliveries) != 1 {
+		t.Fatalf("deliveries = %d; want 1", len(store.deliveries))
+	}
+	d := store.deliveries[0]
+	if d.MessageID != "42" || d.Segments != 2 || d.Encoding != string(message.UCS2) {
+		t.Errorf("delivery = %+v; want id 42, 2 segments, UCS-2", d)
+	}
+}
+
+func TestGenerateCode_UnknownTemplate(t *testing.T) {
+	store := &stubStorage{}
+	notifier := &stubNotifier{}
+	svc := NewTotpService(store, "test", 60, otp.DigitsSix, otp.AlgorithmSHA1, 1, time.Minute, notifier)
+
+	if _, err := svc.GenerateCode(context.Background(), "123", SendOptions{TemplateID: "missing"}); !errors.Is(err, message.ErrUnknownTemplate) {
+		t.Fatalf("GenerateCode error = %v; want ErrUnknownTemplate", err)
+	}
+	if notifier.sentTo != "" {
+		t.Errorf("Notifier called for unknown template")
+	}
+	if ok, _ := svc.CanSend(context.Background(), "123"); !ok {
+		t.Errorf("CanSend = false after failed render; want true")
+	}
+}
+
+// blockingNotifier holds Send until release is closed.
+type blockingNotifier struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (n *blockingNotifier) Send(ctx context.Context, to, msg string) (SendResult, error) {
+	close(n.started)
+	<-n.release
+	return SendResult{}, nil
+}
+
+func TestDrain_WaitsForInFlightSends(t *testing.T) {
+	notifier := &blockingNotifier{started: make(chan struct{}), release: make(chan struct{})}
+	svc := NewTotpService(&stubStorage{}, "test", 30, otp.DigitsSix, otp.AlgorithmSHA1, 1, time.Second, notifier)
+
+	go svc.GenerateCode(context.Background(), "123", SendOptions{})
+	<-notifier.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := svc.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Drain error = %v; want DeadlineExceeded while a send is in flight", err)
+	}
+
+	if n := svc.Pending(); n != 1 {
+		t.Errorf("Pending = %d; want 1", n)
+	}
+
+	close(notifier.release)
+	if err := svc.Drain(context.Background()); err != nil {
+		t.Errorf("Drain error = %v; want nil", err)
+	}
+	if n := svc.Pending(); n != 0 {
+		t.Errorf("Pending after drain = %d; want 0", n)
+	}
+}
+
+func TestEventHandler(t *testing.T) {
+	var events []Event
+	record := func(ctx context.Context, e Event) { events = append(events, e) }
+	notifier := &stubNotifier{result: SendResult{MessageID: "m1", Segments: 1}}
+	svc := NewTotpService(&stubStorage{}, "test", 30, otp.DigitsSix, otp.AlgorithmSHA1, 1, time.Second, notifier,
+		WithEventHandler(record), WithPurposes(map[string]Purpose{"login": {}}))
+
+	code, err := svc.GenerateCode(context.Background(), "123", SendOptions{Purpose: "login"})
+	if err != nil {
+		t.Fatalf("GenerateCode error: %v", err)
+	}
+	svc.ValidateCode(context.Background(), "123", code, VerifyOptions{Purpose: "login"})
+	svc.ValidateCode(context.Background(), "123", "000000", VerifyOptions{Purpose: "login"})
+	notifier.err = errors.New("down")
+	svc.GenerateCode(context.Background(), "123", SendOptions{Purpose: "login"})
+
+	want := []string{EventCodeSent, EventVerificationSucceeded, EventVerificationFailed, EventSendFailed}
+	if len(events) != len(want) {
+		t.Fatalf("events = %+v; want %v", events, want)
+	}
+	for i, typ := range want {
+		if events[i].Type != typ || events[i].Phone != "123" || events[i].Purpose != "login" {
+			t.Errorf("event %d = %+v; want %s for 123/login", i, events[i], typ)
+		}
+	}
+	if events[0].MessageID != "m1" || events[3].Err == nil {
+		t.Errorf("events = %+v; want message ID on send and error on failure", events)
+	}
+}
+
+func TestLockout(t *testing.T) {
+	var events []Event
+	store := &stubStorage{}
+	svc := NewTotpService(store, "test", 30, otp.DigitsSix, otp.AlgorithmSHA1, 1, time.Second, &stubNotifier{},
+		WithLockout(Lockout{MaxFailures: 2, Window: time.Minute, Duration: time.Minute}),
+		WithEventHandler(func(ctx context.Context, e Event) { events = append(events, e) }))
+
+	code, err := svc.GenerateCode(context.Background(), "123", SendOptions{})
+	if err != nil {
+		t.Fatalf("GenerateCode error: %v", err)
+	}
+	svc.ValidateCode(context.Background(), "123", "000000", VerifyOptions{})
+	if store.attempts.Failures != 1 {
+		t.Fatalf("attempts = %+v; want 1 failure", store.attempts)
+	}
+	svc.ValidateCode(context.Background(), "123", "000000", VerifyOptions{})
+	if svc.ValidateCode(context.Background(), "123", code, VerifyOptions{}) {
+		t.Errorf("ValidateCode = true while locked; want false")
+	}
+
+	want := []string{EventCodeSent, EventVerificationFailed, EventVerificationFailed, EventLockout, EventVerificationFailed}
+	if len(events) != len(want) {
+		t.Fatalf("events = %+v; want %v", events, want)
+	}
+	for i, typ := range want {
+		if events[i].Type != typ {
+			t.Errorf("event %d = %s; want %s", i, events[i].Type, typ)
+		}
+	}
+	if events[3].LockedUntil.IsZero() || !errors.Is(events[4].Err, ErrLocked) {
+		t.Errorf("events = %+v; want the lock end and ErrLocked", events[3:])
+	}
+
+	// Once the lock expires a correct code succeeds and clears the record.
+	store.attempts.LockedUntil = time.Now().Add(-time.Second)
+	if !svc.ValidateCode(context.Background(), "123", code, VerifyOptions{}) {
+		t.Errorf("ValidateCode = false after the lock expired; want true")
+	}
+	if store.attempts != (storage.Attempts{}) {
+		t.Errorf("attempts = %+v; want cleared after success", store.attempts)
+	}
+}
+
+func TestLockout_Concurrent(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		checked int // wrong codes that reached the secret
+	)
+	svc := NewTotpService(storage.NewMemoryStorage(), "test", 30, otp.DigitsSix, otp.AlgorithmSHA1, 1, time.Second, &stubNotifier{},
+		WithLockout(Lockout{MaxFailures: 3, Window: time.Minute, Duration: time.Minute}),
+		WithEventHandler(func(ctx context.Context, e Event) {
+			if e.Type == EventVerificationFailed && e.Err == nil {
+				mu.Lock()
+				checked++
+				mu.Unlock()
+			}
+		}))
+	ctx := context.Background()
+	code, err := svc.GenerateCode(ctx, "123", SendOptions{})
+	if err != nil {
+		t.Fatalf("GenerateCode error: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			svc.ValidateCode(ctx, "123", "000000", VerifyOptions{})
+		}()
+	}
+	wg.Wait()
+	if checked != 3 {
+		t.Errorf("wrong codes checked = %d; want 3", checked)
+	}
+	if svc.ValidateCode(ctx, "123", code, VerifyOptions{}) {
+		t.Errorf("ValidateCode = true while locked; want false")
+	}
+}
+
+func TestLockout_PerPurpose(t *testing.T) {
+	svc := NewTotpService(storage.NewMemoryStorage(), "test", 30, otp.DigitsSix, otp.AlgorithmSHA1, 1, 0, &stubNotifier{},
+		WithPurposes(map[string]Purpose{"login": {}, "payment": {}}),
+		WithLockout(Lockout{MaxFailures: 1, Window: time.Minute, Duration: time.Minute}))
+	ctx := context.Background()
+	code, err := svc.GenerateCode(ctx, "123", SendOptions{Purpose: "payment"})
+	if err != nil {
+		t.Fatalf("GenerateCode error: %v", err)
+	}
+	svc.ValidateCode(ctx, "123", "000000", VerifyOptions{Purpose: "login"})
+	if !svc.ValidateCode(ctx, "123", code, VerifyOptions{Purpose: "payment"}) {
+		t.Errorf("ValidateCode = false for payment after a login lockout; want true")
+	}
+	if st := svc.State(ctx, "123"); st.LockedUntil == nil {
+		t.Errorf("State = %+v; want the login lock", st)
+	}
+}
+
+func TestReportDelivery(t *testing.T) {
+	var events []Event
+	svc := NewTotpService(&stubStorage{}, "test", 30, otp.DigitsSix, otp.AlgorithmSHA1, 1, time.Second, &stubNotifier{},
+		WithEventHandler(func(ctx context.Context, e Event) { events = append(events, e) }))
+
+	for _, form := range []url.Values{
+		{"id": {"7"}, "phone": {"79991234567"}, "status": {"1"}},
+		{"id": {"8"}, "phone": {"79991234567"}, "status": {"0"}},
+		{"id": {"9"}, "phone": {"79991234567"}, "status": {"20"}},
+	} {
+		if r, ok := ParseSMSCStatus(form); ok {
+			svc.ReportDelivery(context.Background(), r)
+		}
+	}
+	if len(events) != 2 {
+		t.Fatalf("events = %+v; want delivered and failed only", events)
+	}
+	if e := events[0]; e.Type != EventCodeDelivered || e.MessageID != "7" || e.Phone != "+79991234567" {
+		t.Errorf("first event = %+v; want delivery of 7 to +79991234567", e)
+	}
+	if e := events[1]; e.Type != EventDeliveryFailed || e.Err == nil || e.Err.Error() != "undeliverable" {
+		t.Errorf("second event = %+v; want undeliverable failure", e)
+	}
+}
+
+func TestPhoneAdmin(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	svc := NewTotpService(store, "test", 30, otp.DigitsSix, otp.AlgorithmSHA1, 1, time.Minute, &stubNotifier{result: SendResult{MessageID: "1"}},
+		WithPurposes(map[string]Purpose{"login": {}}),
+		WithLockout(Lockout{MaxFailures: 1, Window: time.Minute, Duration: time.Minute}))
+	ctx := context.Background()
+
+	code, err := svc.GenerateCode(ctx, "+7999", SendOptions{Purpose: "login", Metadata: map[string]string{"order": "42"}})
+	if err != nil {
+		t.Fatalf("GenerateCode error: %v", err)
+	}
+	store.SaveSecret("+79991", "longer number")
+	svc.ValidateCode(ctx, "+7999", "000000", VerifyOptions{Purpose: "login"})
+
+	st := svc.State(ctx, "+7999")
+	if st.LastSend == nil || st.NextSendIn == 0 || st.LockedUntil == nil || len(st.Deliveries) != 1 {
+		t.Errorf("State = %+v; want last send, interval, lock and one delivery", st)
+	} else if st.Deliveries[0].Metadata["order"] != "42" {
+		t.Errorf("delivery metadata = %v; want the send metadata", st.Deliveries[0].Metadata)
+	}
+	if len(st.Purposes) != 1 || st.Purposes[0] != "login" {
+		t.Errorf("Purposes = %v; want [login]", st.Purposes)
+	}
+
+	if !svc.ResetLimits(ctx, "+7999") {
+		t.Errorf("ResetLimits = false; want true")
+	}
+	if ok, _ := svc.CanSend(ctx, "+7999"); !ok {
+		t.Errorf("CanSend = false after reset")
+	}
+	if !svc.ValidateCode(ctx, "+7999", code, VerifyOptions{Purpose: "login"}) {
+		t.Errorf("ValidateCode = false after reset; want the lock cleared")
+	}
+
+	if n := svc.RevokeSecrets(ctx, "+7999"); n != 1 {
+		t.Errorf("RevokeSecrets = %d; want 1", n)
+	}
+	if svc.ValidateCode(ctx, "+7999", code, VerifyOptions{Purpose: "login"}) {
+		t.Errorf("ValidateCode = true after revoke")
+	}
+	if _, ok := store.GetSecret("+79991"); !ok {
+		t.Errorf("secret of a longer number was revoked")
+	}
+}
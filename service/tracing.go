@@ -0,0 +1,27 @@
+package service
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/NlightN22/OTPSMSProvider/service")
+
+// storeSpan starts a span around one storage call. Storage takes no context,
+// so the span is started here and ended by the caller right after the call.
+func storeSpan(ctx context.Context, op string) trace.Span {
+	_, span := tracer.Start(ctx, "storage."+op, trace.WithSpanKind(trace.SpanKindClient))
+	return span
+}
+
+// endSpan records err, if any, and ends span.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	spanRecorderOnce sync.Once
+	spanRecorder     *tracetest.SpanRecorder
+)
+
+// recordSpans installs a recording tracer provider for the package tests.
+// The package tracer is bound to the global provider, which can be set only once.
+func recordSpans() *tracetest.SpanRecorder {
+	spanRecorderOnce.Do(func() {
+		spanRecorder = tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder)))
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+	})
+	return spanRecorder
+}
+
+// spansOf returns the ended spans of trace id by name.
+func spansOf(rec *tracetest.SpanRecorder, id trace.TraceID) map[string]sdktrace.ReadOnlySpan {
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range rec.Ended() {
+		if s.SpanContext().TraceID() == id {
+			spans[s.Name()] = s
+		}
+	}
+	return spans
+}
+
+func TestGenerateCode_Spans(t *testing.T) {
+	rec := recordSpans()
+	svc := NewTotpService(&stubStorage{}, "test", 30, otp.DigitsSix, otp.AlgorithmSHA1, 1, time.Second,
+		&stubNotifier{result: SendResult{MessageID: "7"}}, WithPurposes(map[string]Purpose{"login": {}}))
+
+	ctx, root := otel.Tracer("test").Start(context.Background(), "request")
+	if _, err := svc.GenerateCode(ctx, "123", SendOptions{Purpose: "login"}); err != nil {
+		t.Fatalf("GenerateCode error: %v", err)
+	}
+	root.End()
+
+	spans := spansOf(rec, root.SpanContext().TraceID())
+	gen, ok := spans["TotpService.GenerateCode"]
+	if !ok {
+		t.Fatalf("spans = %v; want TotpService.GenerateCode", spans)
+	}
+	if gen.Parent().SpanID() != root.SpanContext().SpanID() {
+		t.Errorf("GenerateCode parent = %s; want the request span", gen.Parent().SpanID())
+	}
+	for _, name := range []string{"storage.GetSecret", "storage.SaveSecret", "storage.SaveLastSend", "Notifier.Send", "storage.SaveDelivery"} {
+		s, ok := spans[name]
+		if !ok {
+			t.Errorf("missing span %s", name)
+			continue
+		}
+		if s.Parent().SpanID() != gen.SpanContext().SpanID() {
+			t.Errorf("%s parent = %s; want GenerateCode", name, s.Parent().SpanID())
+		}
+	}
+}
+
+func TestSMSCSend_PropagatesTraceContext(t *testing.T) {
+	recordSpans()
+	var got string
+	s := newTestSMSC(t, func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("traceparent")
+		fmt.Fprint(w, `{"id":1,"cnt":1}`)
+	})
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "send")
+	defer span.End()
+	if _, err := s.Send(ctx, "+79990000000", "123456"); err != nil {
+		t.Fatalf("Send error: %v", err)
+	}
+	sc := span.SpanContext()
+	if want := fmt.Sprintf("00-%s-%s-01", sc.TraceID(), sc.SpanID()); got != want {
+		t.Errorf("traceparent = %q; want %q", got, want)
+	}
+}
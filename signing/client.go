@@ -0,0 +1,79 @@
+package signing
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Signer adds signature headers to outgoing requests.
+type Signer struct {
+	KeyID  string
+	Secret string
+	now    func() time.Time
+}
+
+// NewSigner creates a Signer for the key ID and shared secret given to the client.
+func NewSigner(keyID, secret string) *Signer {
+	return &Signer{KeyID: keyID, Secret: secret, now: time.Now}
+}
+
+// Sign reads and restores the request body and sets the signature headers.
+func (s *Signer) Sign(req *http.Request) error {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return fmt.Errorf("signing: read body: %w", err)
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("signing: nonce: %w", err)
+	}
+	h := Headers{
+		KeyID:     s.KeyID,
+		Timestamp: strconv.FormatInt(s.now().Unix(), 10),
+		Nonce:     hex.EncodeToString(nonce),
+		Digest:    Digest(body),
+	}
+	h.Signature = Sign(s.Secret, Canonical(req.Method, req.URL.RequestURI(), h.Timestamp, h.Nonce, h.Digest))
+
+	req.Header.Set(HeaderKeyID, h.KeyID)
+	req.Header.Set(HeaderTimestamp, h.Timestamp)
+	req.Header.Set(HeaderNonce, h.Nonce)
+	req.Header.Set(HeaderDigest, h.Digest)
+	req.Header.Set(HeaderSignature, h.Signature)
+	return nil
+}
+
+// Transport signs every request before passing it to Base, or to http.DefaultTransport when Base is nil.
+//
+//	client := &http.Client{Transport: &signing.Transport{Signer: signing.NewSigner("shop", secret)}}
+type Transport struct {
+	Signer *Signer
+	Base   http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrippers must not modify the caller's request.
+	req = req.Clone(req.Context())
+	if err := t.Signer.Sign(req); err != nil {
+		return nil, err
+	}
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
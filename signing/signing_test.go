@@ -0,0 +1,87 @@
+package signing
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	storage "github.com/NlightN22/OTPSMSProvider/storage"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func signedRequest(t *testing.T, s *Signer, body string) (*http.Request, Headers) {
+	t.Helper()
+	req, err := http.NewRequest("POST", "http://otp.local/v1/send?x=1", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Sign(req); err != nil {
+		t.Fatalf("Sign error: %v", err)
+	}
+	return req, Headers{
+		KeyID:     req.Header.Get(HeaderKeyID),
+		Timestamp: req.Header.Get(HeaderTimestamp),
+		Nonce:     req.Header.Get(HeaderNonce),
+		Digest:    req.Header.Get(HeaderDigest),
+		Signature: req.Header.Get(HeaderSignature),
+	}
+}
+
+func TestVerifier(t *testing.T) {
+	v, err := NewVerifier(storage.NewMemoryStorage(), map[string]string{"shop": testSecret}, time.Minute)
+	if err != nil {
+		t.Fatalf("NewVerifier error: %v", err)
+	}
+	body := `{"phone":"+79991234567"}`
+	_, h := signedRequest(t, NewSigner("shop", testSecret), body)
+
+	if err := v.Verify("POST", "/v1/send?x=1", []byte(body), h); err != nil {
+		t.Fatalf("Verify error: %v", err)
+	}
+	if err := v.Verify("POST", "/v1/send?x=1", []byte(body), h); !errors.Is(err, ErrReplay) {
+		t.Errorf("replayed Verify error = %v; want ErrReplay", err)
+	}
+
+	_, h = signedRequest(t, NewSigner("shop", testSecret), body)
+	cases := []struct {
+		name   string
+		path   string
+		body   string
+		modify func(*Headers)
+		want   error
+	}{
+		{"tampered body", "/v1/send?x=1", `{"phone":"+15551234567"}`, nil, ErrDigest},
+		{"other path", "/v1/verify?x=1", body, nil, ErrSignature},
+		{"missing", "/v1/send?x=1", body, func(h *Headers) { h.Nonce = "" }, ErrMissing},
+		{"unknown key", "/v1/send?x=1", body, func(h *Headers) { h.KeyID = "crm" }, ErrUnknownKey},
+		{"forged", "/v1/send?x=1", body, func(h *Headers) { h.Signature = Sign("wrong", "x") }, ErrSignature},
+	}
+	for _, tc := range cases {
+		hh := h
+		if tc.modify != nil {
+			tc.modify(&hh)
+		}
+		if err := v.Verify("POST", tc.path, []byte(tc.body), hh); !errors.Is(err, tc.want) {
+			t.Errorf("%s: Verify error = %v; want %v", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestVerifier_Window(t *testing.T) {
+	v, err := NewVerifier(storage.NewMemoryStorage(), map[string]string{"shop": testSecret}, time.Minute)
+	if err != nil {
+		t.Fatalf("NewVerifier error: %v", err)
+	}
+	s := NewSigner("shop", testSecret)
+	s.now = func() time.Time { return time.Now().Add(-2 * time.Minute) }
+	_, h := signedRequest(t, s, "")
+	if err := v.Verify("POST", "/v1/send?x=1", nil, h); !errors.Is(err, ErrExpired) {
+		t.Errorf("Verify of old request error = %v; want ErrExpired", err)
+	}
+	if _, err := NewVerifier(storage.NewMemoryStorage(), map[string]string{"shop": "short"}, 0); err == nil {
+		t.Errorf("NewVerifier with short secret error = nil; want error")
+	}
+}
@@ -1,39 +1,332 @@
 package storage
 
-import "time"
+import (
+	"context"
+	"maps"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxDeliveries is how many recent deliveries are kept per key.
+const maxDeliveries = 20
+
+// maxAuditRecords bounds the in-memory audit trail; the file sink keeps the full history.
+const maxAuditRecords = 100000
 
 // MemoryStorage is an in-memory implementation of Storage.
+// It is safe for concurrent use.
 type MemoryStorage struct {
-	secrets  map[string]string
-	lastSend map[string]time.Time
+	mu          sync.RWMutex
+	secrets     map[string]string
+	lastSend    map[string]time.Time
+	deliveries  map[string][]Delivery
+	buckets     map[string]Bucket
+	bucketExp   map[string]time.Time
+	bucketPurge time.Time
+	attempts    map[string]Attempts
+	spend       map[string]float64
+	lists       map[string]map[string]struct{}
+	apiKeys     map[string]APIKey
+	nonces      map[string]time.Time
+	noncePurge  time.Time
+	audit       []AuditRecord
 }
 
 // NewMemoryStorage creates a new MemoryStorage.
 func NewMemoryStorage() *MemoryStorage {
 	return &MemoryStorage{
-		secrets:  make(map[string]string),
-		lastSend: make(map[string]time.Time),
+		secrets:    make(map[string]string),
+		lastSend:   make(map[string]time.Time),
+		deliveries: make(map[string][]Delivery),
+		buckets:    make(map[string]Bucket),
+		bucketExp:  make(map[string]time.Time),
+		attempts:   make(map[string]Attempts),
+		spend:      make(map[string]float64),
+		lists:      make(map[string]map[string]struct{}),
+		apiKeys:    make(map[string]APIKey),
+		nonces:     make(map[string]time.Time),
 	}
 }
 
 // GetSecret returns saved secret.
 func (m *MemoryStorage) GetSecret(key string) (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	s, ok := m.secrets[key]
 	return s, ok
 }
 
 // SaveSecret stores secret for key.
 func (m *MemoryStorage) SaveSecret(key, secret string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.secrets[key] = secret
 }
 
+// DeleteSecret removes the secret of key.
+func (m *MemoryStorage) DeleteSecret(key string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.secrets[key]
+	delete(m.secrets, key)
+	return ok
+}
+
+// SecretKeys returns the keys with a secret that start with prefix.
+func (m *MemoryStorage) SecretKeys(prefix string) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var keys []string
+	for k := range m.secrets {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // GetLastSend returns last send time.
 func (m *MemoryStorage) GetLastSend(key string) (time.Time, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	t, ok := m.lastSend[key]
 	return t, ok
 }
 
 // SaveLastSend stores send timestamp.
 func (m *MemoryStorage) SaveLastSend(key string, t time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.lastSend[key] = t
 }
+
+// DeleteLastSend removes the last send time of key.
+func (m *MemoryStorage) DeleteLastSend(key string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.lastSend[key]
+	delete(m.lastSend, key)
+	return ok
+}
+
+// SaveDelivery appends a delivery record, keeping only the most recent ones.
+func (m *MemoryStorage) SaveDelivery(key string, d Delivery) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d.Metadata = maps.Clone(d.Metadata)
+	list := append(m.deliveries[key], d)
+	if len(list) > maxDeliveries {
+		list = list[len(list)-maxDeliveries:]
+	}
+	m.deliveries[key] = list
+}
+
+// GetDeliveries returns recent deliveries, oldest first.
+func (m *MemoryStorage) GetDeliveries(key string) []Delivery {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]Delivery(nil), m.deliveries[key]...)
+}
+
+// UpdateBucket applies fn to the bucket under key while holding the lock.
+// Buckets untouched for longer than their TTL are dropped along the way.
+func (m *MemoryStorage) UpdateBucket(key string, fn func(b Bucket, ok bool) Bucket) Bucket {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if now.Sub(m.bucketPurge) >= purgeInterval {
+		for k, exp := range m.bucketExp {
+			if now.After(exp) {
+				delete(m.buckets, k)
+				delete(m.bucketExp, k)
+			}
+		}
+		m.bucketPurge = now
+	}
+	b, ok := m.buckets[key]
+	b = fn(b, ok)
+	m.buckets[key] = b
+	if b.TTL > 0 {
+		m.bucketExp[key] = now.Add(b.TTL)
+	} else {
+		delete(m.bucketExp, key)
+	}
+	return b
+}
+
+// UpdateAttempts applies fn to the attempts under key while holding the lock.
+func (m *MemoryStorage) UpdateAttempts(key string, fn func(a Attempts, ok bool) Attempts) Attempts {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.attempts[key]
+	a = fn(a, ok)
+	if a == (Attempts{}) {
+		delete(m.attempts, key)
+	} else {
+		m.attempts[key] = a
+	}
+	return a
+}
+
+// GetAttempts returns the attempts under key.
+func (m *MemoryStorage) GetAttempts(key string) (Attempts, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	a, ok := m.attempts[key]
+	return a, ok
+}
+
+// AddSpend increments the spend counter of period.
+func (m *MemoryStorage) AddSpend(period string, amount float64) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.spend[period] += amount
+	return m.spend[period]
+}
+
+// GetSpend returns the spend counter of period.
+func (m *MemoryStorage) GetSpend(period string) float64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.spend[period]
+}
+
+// AddListEntry adds entry to the named set.
+func (m *MemoryStorage) AddListEntry(list, entry string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.lists[list] == nil {
+		m.lists[list] = make(map[string]struct{})
+	}
+	m.lists[list][entry] = struct{}{}
+}
+
+// DeleteListEntry removes entry from the named set.
+func (m *MemoryStorage) DeleteListEntry(list, entry string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.lists[list][entry]; !ok {
+		return false
+	}
+	delete(m.lists[list], entry)
+	return true
+}
+
+// GetListEntries returns the entries of the named set, sorted.
+func (m *MemoryStorage) GetListEntries(list string) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]string, 0, len(m.lists[list]))
+	for e := range m.lists[list] {
+		out = append(out, e)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// SaveAPIKey stores k under its ID.
+func (m *MemoryStorage) SaveAPIKey(k APIKey) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.apiKeys[k.ID] = k
+}
+
+// GetAPIKey returns the key with the given ID.
+func (m *MemoryStorage) GetAPIKey(id string) (APIKey, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	k, ok := m.apiKeys[id]
+	return k, ok
+}
+
+// DeleteAPIKey removes the key with the given ID.
+func (m *MemoryStorage) DeleteAPIKey(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.apiKeys[id]; !ok {
+		return false
+	}
+	delete(m.apiKeys, id)
+	return true
+}
+
+// ListAPIKeys returns all stored keys sorted by ID.
+func (m *MemoryStorage) ListAPIKeys() []APIKey {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]APIKey, 0, len(m.apiKeys))
+	for _, k := range m.apiKeys {
+		out = append(out, k)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// purgeInterval bounds how often expired nonces and idle buckets are dropped.
+const purgeInterval = time.Minute
+
+// UseNonce records nonce until expires and reports whether it was unused.
+func (m *MemoryStorage) UseNonce(nonce string, expires time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if now.Sub(m.noncePurge) >= purgeInterval {
+		for n, exp := range m.nonces {
+			if now.After(exp) {
+				delete(m.nonces, n)
+			}
+		}
+		m.noncePurge = now
+	}
+	if exp, ok := m.nonces[nonce]; ok && !now.After(exp) {
+		return false
+	}
+	m.nonces[nonce] = expires
+	return true
+}
+
+// AppendAudit adds r after the last record, dropping the oldest beyond maxAuditRecords.
+func (m *MemoryStorage) AppendAudit(r AuditRecord) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r.Data = append([]byte(nil), r.Data...)
+	m.audit = append(m.audit, r)
+	if len(m.audit) > maxAuditRecords {
+		m.audit = append([]AuditRecord(nil), m.audit[len(m.audit)-maxAuditRecords:]...)
+	}
+}
+
+// LastAudit returns the newest audit record.
+func (m *MemoryStorage) LastAudit() (AuditRecord, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if len(m.audit) == 0 {
+		return AuditRecord{}, false
+	}
+	return m.audit[len(m.audit)-1], true
+}
+
+// ListAudit returns up to limit records with Seq above after.
+func (m *MemoryStorage) ListAudit(after uint64, limit int) []AuditRecord {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	i := sort.Search(len(m.audit), func(i int) bool { return m.audit[i].Seq > after })
+	end := len(m.audit)
+	if limit > 0 && i+limit < end {
+		end = i + limit
+	}
+	return append([]AuditRecord(nil), m.audit[i:end]...)
+}
+
+// Ping always succeeds: memory storage is in-process.
+func (m *MemoryStorage) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Close is a no-op: memory storage holds no external resources.
+func (m *MemoryStorage) Close() error {
+	return nil
+}
@@ -17,6 +17,14 @@ func TestMemoryStorage_Secret(t *testing.T) {
 	if !ok || got != "s" {
 		t.Errorf("GetSecret = %v,%v; want \"s\",true", got, ok)
 	}
+	m.SaveSecret(key+"|login", "t")
+	m.SaveSecret("other", "u")
+	if keys := m.SecretKeys(key); len(keys) != 2 || keys[0] != key || keys[1] != key+"|login" {
+		t.Errorf("SecretKeys = %v; want [key key|login]", keys)
+	}
+	if !m.DeleteSecret(key) || m.DeleteSecret(key) {
+		t.Errorf("DeleteSecret = false,true; want true then false")
+	}
 }
 
 func TestMemoryStorage_LastSend(t *testing.T) {
@@ -32,4 +40,168 @@ func TestMemoryStorage_LastSend(t *testing.T) {
 	if !ok || !got.Equal(now) {
 		t.Errorf("GetLastSend = %v,%v; want %v,true", got, ok, now)
 	}
+	if !m.DeleteLastSend(key) {
+		t.Errorf("DeleteLastSend = false; want true")
+	}
+	if _, ok := m.GetLastSend(key); ok {
+		t.Errorf("GetLastSend ok = true after delete")
+	}
+}
+
+func TestMemoryStorage_Deliveries(t *testing.T) {
+	m := NewMemoryStorage()
+	key := "key"
+	if got := m.GetDeliveries(key); len(got) != 0 {
+		t.Errorf("GetDeliveries = %v; want empty", got)
+	}
+	for i := 0; i < maxDeliveries+5; i++ {
+		m.SaveDelivery(key, Delivery{Segments: i})
+	}
+	got := m.GetDeliveries(key)
+	if len(got) != maxDeliveries {
+		t.Fatalf("len(GetDeliveries) = %d; want %d", len(got), maxDeliveries)
+	}
+	if got[0].Segments != 5 || got[len(got)-1].Segments != maxDeliveries+4 {
+		t.Errorf("GetDeliveries kept %d..%d; want 5..%d", got[0].Segments, got[len(got)-1].Segments, maxDeliveries+4)
+	}
+}
+
+func TestMemoryStorage_UpdateBucket(t *testing.T) {
+	m := NewMemoryStorage()
+	got := m.UpdateBucket("b", func(b Bucket, ok bool) Bucket {
+		if ok {
+			t.Errorf("UpdateBucket ok = true for new bucket")
+		}
+		return Bucket{Tokens: 3}
+	})
+	if got.Tokens != 3 {
+		t.Errorf("UpdateBucket = %v; want 3 tokens", got)
+	}
+	m.UpdateBucket("b", func(b Bucket, ok bool) Bucket {
+		if !ok || b.Tokens != 3 {
+			t.Errorf("UpdateBucket got %v,%v; want 3 tokens,true", b, ok)
+		}
+		b.Tokens--
+		return b
+	})
+}
+
+func TestMemoryStorage_UpdateBucketDropsIdle(t *testing.T) {
+	m := NewMemoryStorage()
+	m.UpdateBucket("idle", func(Bucket, bool) Bucket { return Bucket{Tokens: 1, TTL: time.Nanosecond} })
+	m.UpdateBucket("busy", func(Bucket, bool) Bucket { return Bucket{Tokens: 1, TTL: time.Hour} })
+	m.UpdateBucket("kept", func(Bucket, bool) Bucket { return Bucket{Tokens: 1} })
+	time.Sleep(time.Millisecond)
+	m.bucketPurge = time.Time{}
+
+	m.UpdateBucket("other", func(b Bucket, _ bool) Bucket { return b })
+	for key, want := range map[string]bool{"idle": false, "busy": true, "kept": true} {
+		m.UpdateBucket(key, func(b Bucket, ok bool) Bucket {
+			if ok != want {
+				t.Errorf("bucket %q present = %v; want %v", key, ok, want)
+			}
+			return b
+		})
+	}
+}
+
+func TestMemoryStorage_Attempts(t *testing.T) {
+	m := NewMemoryStorage()
+	m.UpdateAttempts("p", func(a Attempts, ok bool) Attempts {
+		if ok {
+			t.Errorf("UpdateAttempts ok = true for new record")
+		}
+		a.Failures++
+		return a
+	})
+	if a, ok := m.GetAttempts("p"); !ok || a.Failures != 1 {
+		t.Errorf("GetAttempts = %v,%v; want 1 failure", a, ok)
+	}
+	m.UpdateAttempts("p", func(Attempts, bool) Attempts { return Attempts{} })
+	if _, ok := m.GetAttempts("p"); ok {
+		t.Errorf("GetAttempts ok = true after reset; want the record removed")
+	}
+}
+
+func TestMemoryStorage_Spend(t *testing.T) {
+	m := NewMemoryStorage()
+	if got := m.GetSpend("day:2024-01-01"); got != 0 {
+		t.Errorf("GetSpend = %v; want 0", got)
+	}
+	m.AddSpend("day:2024-01-01", 1.5)
+	if got := m.AddSpend("day:2024-01-01", 2); got != 3.5 {
+		t.Errorf("AddSpend = %v; want 3.5", got)
+	}
+	if got := m.GetSpend("day:2024-01-02"); got != 0 {
+		t.Errorf("GetSpend other period = %v; want 0", got)
+	}
+}
+
+func TestMemoryStorage_Lists(t *testing.T) {
+	m := NewMemoryStorage()
+	m.AddListEntry("block", "+7999*")
+	m.AddListEntry("block", "+15550000000")
+	m.AddListEntry("other", "x")
+	got := m.GetListEntries("block")
+	if len(got) != 2 || got[0] != "+15550000000" || got[1] != "+7999*" {
+		t.Errorf("GetListEntries = %v; want sorted two entries", got)
+	}
+	if !m.DeleteListEntry("block", "+7999*") {
+		t.Errorf("DeleteListEntry = false; want true")
+	}
+	if m.DeleteListEntry("block", "+7999*") {
+		t.Errorf("second DeleteListEntry = true; want false")
+	}
+	if got := m.GetListEntries("missing"); len(got) != 0 {
+		t.Errorf("GetListEntries(missing) = %v; want empty", got)
+	}
+}
+
+func TestMemoryStorage_APIKeys(t *testing.T) {
+	m := NewMemoryStorage()
+	m.SaveAPIKey(APIKey{ID: "b", Name: "second"})
+	m.SaveAPIKey(APIKey{ID: "a", Name: "first"})
+	if k, ok := m.GetAPIKey("a"); !ok || k.Name != "first" {
+		t.Errorf("GetAPIKey = %+v, %v; want first", k, ok)
+	}
+	if got := m.ListAPIKeys(); len(got) != 2 || got[0].ID != "a" {
+		t.Errorf("ListAPIKeys = %+v; want sorted two keys", got)
+	}
+	if !m.DeleteAPIKey("a") || m.DeleteAPIKey("a") {
+		t.Errorf("DeleteAPIKey did not report presence correctly")
+	}
+}
+
+func TestMemoryStorage_UseNonce(t *testing.T) {
+	m := NewMemoryStorage()
+	if !m.UseNonce("n1", time.Now().Add(time.Minute)) {
+		t.Fatalf("first UseNonce = false; want true")
+	}
+	if m.UseNonce("n1", time.Now().Add(time.Minute)) {
+		t.Errorf("repeated UseNonce = true; want false")
+	}
+	m.UseNonce("n2", time.Now().Add(-time.Second))
+	if !m.UseNonce("n2", time.Now().Add(time.Minute)) {
+		t.Errorf("UseNonce after expiry = false; want true")
+	}
+}
+
+func TestMemoryStorage_Audit(t *testing.T) {
+	m := NewMemoryStorage()
+	if _, ok := m.LastAudit(); ok {
+		t.Fatalf("LastAudit on empty storage = ok; want none")
+	}
+	for seq := uint64(1); seq <= 5; seq++ {
+		m.AppendAudit(AuditRecord{Seq: seq, Data: []byte{byte(seq)}})
+	}
+	if last, ok := m.LastAudit(); !ok || last.Seq != 5 {
+		t.Errorf("LastAudit = %+v, %v; want seq 5", last, ok)
+	}
+	got := m.ListAudit(2, 2)
+	if len(got) != 2 || got[0].Seq != 3 || got[1].Seq != 4 {
+		t.Errorf("ListAudit(2, 2) = %+v; want seqs 3 and 4", got)
+	}
+	if got := m.ListAudit(0, 0); len(got) != 5 {
+		t.Errorf("ListAudit(0, 0) = %d records; want all 5", len(got))
+	}
 }
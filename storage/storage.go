@@ -1,11 +1,96 @@
 package storage
 
-import "time"
+import (
+	"context"
+	"time"
+)
+
+// Delivery records a single message handed to a provider.
+type Delivery struct {
+	MessageID string
+	Segments  int
+	Encoding  string
+	SentAt    time.Time
+	Metadata  map[string]string `json:",omitempty"` // caller data of the send request
+}
+
+// Bucket is the persisted state of a token bucket rate limiter.
+type Bucket struct {
+	Tokens  float64
+	Updated time.Time
+	// TTL is how long the bucket may stay untouched before it is full again
+	// and can be dropped. Zero keeps it.
+	TTL time.Duration
+}
+
+// Attempts counts failed verifications of a phone for the lockout.
+type Attempts struct {
+	Failures     int
+	FirstFailure time.Time
+	LockedUntil  time.Time
+}
+
+// APIKey is a client key minted at runtime. Only the hash of the token is kept.
+type APIKey struct {
+	ID            string
+	Name          string
+	Hash          string
+	Scopes        []string
+	PerMinute     float64
+	Burst         int
+	PhonePrefixes []string
+	CreatedAt     time.Time
+}
+
+// AuditRecord is one serialized audit event. Seq orders the records.
+type AuditRecord struct {
+	Seq  uint64
+	Data []byte
+}
 
 // Storage defines methods to persist secrets and timestamps.
 type Storage interface {
 	GetSecret(key string) (string, bool)
 	SaveSecret(key, secret string)
+	// DeleteSecret reports whether key had a secret.
+	DeleteSecret(key string) bool
+	// SecretKeys returns the keys starting with prefix that have a secret, sorted.
+	SecretKeys(prefix string) []string
 	GetLastSend(key string) (time.Time, bool)
 	SaveLastSend(key string, t time.Time)
+	// DeleteLastSend reports whether key had a send time.
+	DeleteLastSend(key string) bool
+	SaveDelivery(key string, d Delivery)
+	GetDeliveries(key string) []Delivery
+	// UpdateBucket atomically replaces the bucket under key with fn's result and returns it.
+	// ok is false when the bucket does not exist yet.
+	UpdateBucket(key string, fn func(b Bucket, ok bool) Bucket) Bucket
+	// UpdateAttempts atomically replaces the attempts under key with fn's result
+	// and returns it. A zero result removes the record.
+	UpdateAttempts(key string, fn func(a Attempts, ok bool) Attempts) Attempts
+	GetAttempts(key string) (Attempts, bool)
+	// AddSpend adds amount to the spend counter of period and returns the new total.
+	AddSpend(period string, amount float64) float64
+	GetSpend(period string) float64
+	// AddListEntry adds entry to the named set, DeleteListEntry reports whether it was present.
+	AddListEntry(list, entry string)
+	DeleteListEntry(list, entry string) bool
+	GetListEntries(list string) []string
+	// SaveAPIKey stores k under its ID, DeleteAPIKey reports whether it was present.
+	SaveAPIKey(k APIKey)
+	GetAPIKey(id string) (APIKey, bool)
+	DeleteAPIKey(id string) bool
+	// ListAPIKeys returns all stored keys sorted by ID.
+	ListAPIKeys() []APIKey
+	// UseNonce records nonce until expires and reports whether it was unused.
+	UseNonce(nonce string, expires time.Time) bool
+	// AppendAudit stores r, LastAudit returns the record with the highest Seq.
+	AppendAudit(r AuditRecord)
+	LastAudit() (AuditRecord, bool)
+	// ListAudit returns up to limit records with Seq above after, oldest first.
+	ListAudit(after uint64, limit int) []AuditRecord
+	// Ping reports whether the backend is reachable.
+	Ping(ctx context.Context) error
+	// Close releases the backend; the storage must not be used afterwards.
+	Close() error
 }
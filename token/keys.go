@@ -0,0 +1,112 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Signing algorithms.
+const (
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// ErrUnsupportedKey is returned for keys other than RSA (2048 bits or more) and Ed25519.
+var ErrUnsupportedKey = errors.New("unsupported signing key")
+
+// Key is a private signing key with its key ID.
+type Key struct {
+	ID     string
+	Alg    string
+	Signer crypto.Signer
+}
+
+// NewKey wraps signer, deriving the algorithm from its type. An empty id is
+// replaced by the key's thumbprint.
+func NewKey(id string, signer crypto.Signer) (Key, error) {
+	var k Key
+	switch s := signer.(type) {
+	case *rsa.PrivateKey:
+		if s.N.BitLen() < 2048 {
+			return Key{}, fmt.Errorf("%w: RSA key %q has %d bits", ErrUnsupportedKey, id, s.N.BitLen())
+		}
+		k = Key{ID: id, Alg: AlgRS256, Signer: s}
+	case ed25519.PrivateKey:
+		k = Key{ID: id, Alg: AlgEdDSA, Signer: s}
+	default:
+		return Key{}, fmt.Errorf("%w: %q is %T", ErrUnsupportedKey, id, signer)
+	}
+	if k.ID == "" {
+		k.ID = Thumbprint(publicJWK(k))
+	}
+	return k, nil
+}
+
+// Thumbprint returns the RFC 7638 SHA-256 thumbprint of jwk: the hash of its
+// required members in lexicographic order.
+func Thumbprint(jwk JWK) string {
+	var members any
+	switch jwk.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	default:
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	}
+	b, _ := json.Marshal(members) // plain strings, Marshal cannot fail
+	sum := sha256.Sum256(b)
+	return b64(sum[:])
+}
+
+// LoadKey reads a PEM-encoded PKCS#8, PKCS#1 RSA or raw Ed25519 private key.
+// Its key ID is the thumbprint of the public key.
+func LoadKey(path string) (Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Key{}, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return Key{}, fmt.Errorf("%s: no PEM block", path)
+	}
+	var parsed any
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return Key{}, fmt.Errorf("%s: %w", path, err)
+	}
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return Key{}, fmt.Errorf("%w: %s", ErrUnsupportedKey, path)
+	}
+	return NewKey("", signer)
+}
+
+// GenerateKey creates an Ed25519 key, for development setups without key files.
+func GenerateKey(id string) (Key, error) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return Key{}, err
+	}
+	return NewKey(id, priv)
+}
@@ -0,0 +1,272 @@
+// Package token issues signed JWTs that prove a phone number was verified.
+package token
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	logger "github.com/NlightN22/OTPSMSProvider/pkg"
+	"go.uber.org/zap"
+)
+
+// Optional claims selectable in Config.Claims.
+const (
+	ClaimPhone          = "phone"           // "sub" and "phone_number"
+	ClaimPurpose        = "purpose"         // "purpose"
+	ClaimVerificationID = "verification_id" // "jti"
+	ClaimAMR            = "amr"             // "amr": ["sms"]
+)
+
+// DefaultClaims are included when Config.Claims is empty.
+var DefaultClaims = []string{ClaimPhone, ClaimPurpose, ClaimVerificationID, ClaimAMR}
+
+// ErrNoKeys is returned when the issuer has no signing key.
+var ErrNoKeys = errors.New("no signing keys")
+
+// Config configures an Issuer.
+type Config struct {
+	Issuer   string
+	Audience []string
+	TTL      time.Duration
+	Claims   []string
+	// Files are PEM private keys loaded in order; the first key signs, the rest
+	// stay in the JWKS so tokens signed before a rotation keep validating. Their
+	// key IDs are derived from the keys, so a key keeps its ID across files.
+	Files []string
+}
+
+// Verification describes a successful code check.
+type Verification struct {
+	Phone   string
+	Purpose string
+}
+
+// Issued is a signed token.
+type Issued struct {
+	Token          string    `json:"token"`
+	VerificationID string    `json:"verification_id"`
+	ExpiresAt      time.Time `json:"expires_at"`
+}
+
+// Issuer signs tokens with the active key and publishes all keys as a JWKS.
+type Issuer struct {
+	cfg    Config
+	claims map[string]bool
+	now    func() time.Time
+	log    *zap.SugaredLogger
+
+	mu      sync.RWMutex
+	keys    []Key
+	modTime time.Time
+}
+
+// NewIssuer loads cfg.Files. extra keys are appended after them, e.g. a generated
+// development key when no files are configured.
+func NewIssuer(cfg Config, extra ...Key) (*Issuer, error) {
+	if cfg.TTL <= 0 {
+		cfg.TTL = 5 * time.Minute
+	}
+	claims := cfg.Claims
+	if len(claims) == 0 {
+		claims = DefaultClaims
+	}
+	iss := &Issuer{cfg: cfg, claims: make(map[string]bool), now: time.Now, log: logger.New("Token")}
+	for _, c := range claims {
+		switch c {
+		case ClaimPhone, ClaimPurpose, ClaimVerificationID, ClaimAMR:
+			iss.claims[c] = true
+		default:
+			return nil, fmt.Errorf("unknown claim %q", c)
+		}
+	}
+	keys, mod, err := loadFiles(cfg.Files)
+	if err != nil {
+		return nil, err
+	}
+	keys = append(keys, extra...)
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+	iss.keys, iss.modTime = keys, mod
+	return iss, nil
+}
+
+func loadFiles(files []string) ([]Key, time.Time, error) {
+	var keys []Key
+	var latest time.Time
+	seen := make(map[string]bool)
+	for _, path := range files {
+		k, err := LoadKey(path)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		if seen[k.ID] {
+			return nil, time.Time{}, fmt.Errorf("%s: key is loaded twice", path)
+		}
+		seen[k.ID] = true
+		if info, err := os.Stat(path); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+		keys = append(keys, k)
+	}
+	return keys, latest, nil
+}
+
+// Issue signs a token for v.
+func (i *Issuer) Issue(v Verification) (Issued, error) {
+	i.mu.RLock()
+	if len(i.keys) == 0 {
+		i.mu.RUnlock()
+		return Issued{}, ErrNoKeys
+	}
+	key := i.keys[0]
+	i.mu.RUnlock()
+
+	id, err := newID()
+	if err != nil {
+		return Issued{}, err
+	}
+	now := i.now()
+	exp := now.Add(i.cfg.TTL)
+	claims := map[string]any{
+		"iat": now.Unix(),
+		"nbf": now.Unix(),
+		"exp": exp.Unix(),
+	}
+	if i.cfg.Issuer != "" {
+		claims["iss"] = i.cfg.Issuer
+	}
+	switch len(i.cfg.Audience) {
+	case 0:
+	case 1:
+		claims["aud"] = i.cfg.Audience[0]
+	default:
+		claims["aud"] = i.cfg.Audience
+	}
+	if i.claims[ClaimPhone] {
+		claims["sub"] = v.Phone
+		claims["phone_number"] = v.Phone
+		claims["phone_number_verified"] = true
+	}
+	if i.claims[ClaimPurpose] && v.Purpose != "" {
+		claims["purpose"] = v.Purpose
+	}
+	if i.claims[ClaimVerificationID] {
+		claims["jti"] = id
+	}
+	if i.claims[ClaimAMR] {
+		claims["amr"] = []string{"sms"}
+	}
+
+	tok, err := sign(key, claims)
+	if err != nil {
+		return Issued{}, err
+	}
+	return Issued{Token: tok, VerificationID: id, ExpiresAt: exp}, nil
+}
+
+// JWKS returns the public keys of all loaded signing keys.
+func (i *Issuer) JWKS() JWKS {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	out := JWKS{Keys: make([]JWK, 0, len(i.keys))}
+	for _, k := range i.keys {
+		out.Keys = append(out.Keys, publicJWK(k))
+	}
+	return out
+}
+
+// Watch reloads the key files when they change, until ctx is done.
+// To rotate, write the new key to the first file and move the previous one to
+// the second, keeping it there until the tokens it signed have expired; key IDs
+// follow the keys, so those tokens still find theirs in the JWKS.
+func (i *Issuer) Watch(ctx context.Context, interval time.Duration) {
+	if len(i.cfg.Files) == 0 || interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := i.reload(); err != nil {
+				i.log.Errorw("Signing key reload failed", "err", err)
+			}
+		}
+	}
+}
+
+func (i *Issuer) reload() error {
+	var latest time.Time
+	for _, path := range i.cfg.Files {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	i.mu.RLock()
+	unchanged := !latest.After(i.modTime)
+	i.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+	keys, mod, err := loadFiles(i.cfg.Files)
+	if err != nil {
+		return err
+	}
+	i.mu.Lock()
+	i.keys, i.modTime = keys, mod
+	i.mu.Unlock()
+	i.log.Infow("Signing keys reloaded", "active", keys[0].ID, "keys", len(keys))
+	return nil
+}
+
+func sign(k Key, claims map[string]any) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": k.Alg, "typ": "JWT", "kid": k.ID})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	input := b64(header) + "." + b64(payload)
+
+	var sig []byte
+	switch k.Alg {
+	case AlgRS256:
+		sum := sha256.Sum256([]byte(input))
+		sig, err = k.Signer.Sign(rand.Reader, sum[:], crypto.SHA256)
+	case AlgEdDSA:
+		sig, err = k.Signer.Sign(rand.Reader, []byte(input), crypto.Hash(0))
+	default:
+		err = fmt.Errorf("%w: %s", ErrUnsupportedKey, k.Alg)
+	}
+	if err != nil {
+		return "", fmt.Errorf("sign token: %w", err)
+	}
+	return strings.Join([]string{input, b64(sig)}, "."), nil
+}
+
+func newID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("verification id: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,194 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeKey(t *testing.T, dir, name string, key any) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// verifyWithJWKS checks tok against the published key with its kid, as a consumer would.
+func verifyWithJWKS(t *testing.T, tok string, set JWKS) map[string]any {
+	t.Helper()
+	parts := strings.Split(tok, ".")
+	if len(parts) != 3 {
+		t.Fatalf("token has %d parts", len(parts))
+	}
+	var header map[string]string
+	raw, _ := base64.RawURLEncoding.DecodeString(parts[0])
+	if err := json.Unmarshal(raw, &header); err != nil {
+		t.Fatal(err)
+	}
+	sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	input := []byte(parts[0] + "." + parts[1])
+
+	var jwk *JWK
+	for i := range set.Keys {
+		if set.Keys[i].Kid == header["kid"] {
+			jwk = &set.Keys[i]
+		}
+	}
+	if jwk == nil {
+		t.Fatalf("kid %q not in JWKS", header["kid"])
+	}
+	switch jwk.Kty {
+	case "OKP":
+		x, _ := base64.RawURLEncoding.DecodeString(jwk.X)
+		if !ed25519.Verify(ed25519.PublicKey(x), input, sig) {
+			t.Fatalf("EdDSA signature invalid")
+		}
+	case "RSA":
+		n, _ := base64.RawURLEncoding.DecodeString(jwk.N)
+		e, _ := base64.RawURLEncoding.DecodeString(jwk.E)
+		pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		sum := sha256.Sum256(input)
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], sig); err != nil {
+			t.Fatalf("RS256 signature invalid: %v", err)
+		}
+	}
+	var claims map[string]any
+	raw, _ = base64.RawURLEncoding.DecodeString(parts[1])
+	if err := json.Unmarshal(raw, &claims); err != nil {
+		t.Fatal(err)
+	}
+	return claims
+}
+
+func TestIssuer_RS256AndClaims(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := writeKey(t, t.TempDir(), "rsa.pem", rsaKey)
+	iss, err := NewIssuer(Config{
+		Issuer:   "otp",
+		Audience: []string{"shop"},
+		TTL:      time.Minute,
+		Files:    []string{path},
+	})
+	if err != nil {
+		t.Fatalf("NewIssuer error: %v", err)
+	}
+	issued, err := iss.Issue(Verification{Phone: "+79991234567", Purpose: "login"})
+	if err != nil {
+		t.Fatalf("Issue error: %v", err)
+	}
+	claims := verifyWithJWKS(t, issued.Token, iss.JWKS())
+	if claims["sub"] != "+79991234567" || claims["purpose"] != "login" || claims["jti"] != issued.VerificationID ||
+		claims["aud"] != "shop" || claims["iss"] != "otp" {
+		t.Errorf("claims = %v", claims)
+	}
+	if amr, _ := claims["amr"].([]any); len(amr) != 1 || amr[0] != "sms" {
+		t.Errorf("amr = %v; want [sms]", claims["amr"])
+	}
+}
+
+func TestIssuer_SelectedClaims(t *testing.T) {
+	key, err := GenerateKey("dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	iss, err := NewIssuer(Config{Claims: []string{ClaimAMR}}, key)
+	if err != nil {
+		t.Fatalf("NewIssuer error: %v", err)
+	}
+	issued, err := iss.Issue(Verification{Phone: "+79991234567", Purpose: "login"})
+	if err != nil {
+		t.Fatalf("Issue error: %v", err)
+	}
+	claims := verifyWithJWKS(t, issued.Token, iss.JWKS())
+	for _, c := range []string{"sub", "phone_number", "purpose", "jti"} {
+		if _, ok := claims[c]; ok {
+			t.Errorf("claim %q present; want omitted", c)
+		}
+	}
+	if _, err := NewIssuer(Config{Claims: []string{"email"}}, key); err == nil {
+		t.Errorf("NewIssuer with unknown claim error = nil; want error")
+	}
+	if _, err := NewIssuer(Config{}); err != ErrNoKeys {
+		t.Errorf("NewIssuer without keys error = %v; want ErrNoKeys", err)
+	}
+}
+
+func TestThumbprint(t *testing.T) {
+	// RFC 8037, appendix A.3.
+	jwk := JWK{Kty: "OKP", Crv: "Ed25519", X: "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}
+	if got := Thumbprint(jwk); got != "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k" {
+		t.Errorf("Thumbprint = %s; want the RFC 8037 value", got)
+	}
+}
+
+func TestIssuer_Rotation(t *testing.T) {
+	dir := t.TempDir()
+	_, older, _ := ed25519.GenerateKey(rand.Reader)
+	_, old, _ := ed25519.GenerateKey(rand.Reader)
+	current := writeKey(t, dir, "current.pem", old)
+	previous := writeKey(t, dir, "previous.pem", older)
+	iss, err := NewIssuer(Config{Files: []string{current, previous}})
+	if err != nil {
+		t.Fatalf("NewIssuer error: %v", err)
+	}
+	before, err := iss.Issue(Verification{Phone: "+79991234567"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldKid := iss.JWKS().Keys[0].Kid
+
+	// Rotate as documented: the previous key moves to the second file and the
+	// new one takes the first.
+	_, next, _ := ed25519.GenerateKey(rand.Reader)
+	writeKey(t, dir, "previous.pem", old)
+	writeKey(t, dir, "current.pem", next)
+	future := time.Now().Add(time.Minute)
+	for _, p := range []string{current, previous} {
+		if err := os.Chtimes(p, future, future); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := iss.reload(); err != nil {
+		t.Fatalf("reload error: %v", err)
+	}
+	after, err := iss.Issue(Verification{Phone: "+79991234567"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := iss.JWKS()
+	if len(set.Keys) != 2 || set.Keys[1].Kid != oldKid || set.Keys[0].Kid == oldKid {
+		t.Fatalf("JWKS = %+v; want the new key, then the old one under its kid %s", set, oldKid)
+	}
+	verifyWithJWKS(t, before.Token, set)
+	verifyWithJWKS(t, after.Token, set)
+	if !strings.Contains(after.Token, ".") || after.Token == before.Token {
+		t.Errorf("token not re-issued after rotation")
+	}
+
+	// The same key in both files is a configuration mistake.
+	writeKey(t, dir, "previous.pem", next)
+	if _, err := NewIssuer(Config{Files: []string{current, previous}}); err == nil {
+		t.Errorf("NewIssuer with a duplicated key error = nil; want error")
+	}
+}
@@ -7,12 +7,28 @@ import (
 	"github.com/go-playground/validator/v10"
 )
 
-var e164Regexp = regexp.MustCompile(`^\+[1-9]\d{1,14}$`)
+var (
+	e164Regexp       = regexp.MustCompile(`^\+[1-9]\d{1,14}$`)
+	e164PrefixRegexp = regexp.MustCompile(`^\+[1-9]\d{0,14}$`)
+)
+
+// IsE164 reports whether s is a phone number in strict E.164 form.
+func IsE164(s string) bool {
+	return e164Regexp.MatchString(s)
+}
+
+// IsE164Prefix reports whether s is the leading part of an E.164 number, e.g. "+7".
+func IsE164Prefix(s string) bool {
+	return e164PrefixRegexp.MatchString(s)
+}
 
 func RegisterCustomValidations() {
 	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
 		v.RegisterValidation("e164", func(fl validator.FieldLevel) bool {
-			return e164Regexp.MatchString(fl.Field().String())
+			return IsE164(fl.Field().String())
+		})
+		v.RegisterValidation("e164_prefix", func(fl validator.FieldLevel) bool {
+			return IsE164Prefix(fl.Field().String())
 		})
 	}
 }
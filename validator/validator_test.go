@@ -24,3 +24,16 @@ func TestE164Regexp(t *testing.T) {
 		}
 	}
 }
+
+func TestIsE164Prefix(t *testing.T) {
+	for _, s := range []string{"+7", "+7999", "+1234567890"} {
+		if !IsE164Prefix(s) {
+			t.Errorf("IsE164Prefix(%q) = false; want true", s)
+		}
+	}
+	for _, s := range []string{"7", "+", "+0", "+7*", ""} {
+		if IsE164Prefix(s) {
+			t.Errorf("IsE164Prefix(%q) = true; want false", s)
+		}
+	}
+}
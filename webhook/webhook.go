@@ -0,0 +1,372 @@
+// Package webhook delivers service events to subscribed HTTP endpoints with
+// HMAC signatures, retries with exponential backoff and a log of recent
+// deliveries.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	logger "github.com/NlightN22/OTPSMSProvider/pkg"
+	"github.com/NlightN22/OTPSMSProvider/ring"
+	service "github.com/NlightN22/OTPSMSProvider/service"
+	"go.uber.org/zap"
+)
+
+// Headers sent with every delivery besides SignatureHeader.
+const (
+	EventHeader    = "X-Webhook-Event"
+	DeliveryHeader = "X-Webhook-ID" // the same on every retry, for deduplication
+)
+
+// historySize bounds the delivery log.
+const historySize = 500
+
+// Subscription is one endpoint and the events it receives.
+type Subscription struct {
+	Name      string
+	URL       string
+	Secret    string
+	Events    []string // empty subscribes to every event
+	MaskPhone bool     // send a masked phone instead of the full number
+}
+
+// Config controls delivery.
+type Config struct {
+	Workers        int           // concurrent deliveries, 4 when zero
+	QueueSize      int           // events waiting for a worker, 1000 when zero
+	MaxAttempts    int           // attempts per delivery, 5 when zero
+	Timeout        time.Duration // per attempt, 5s when zero
+	InitialBackoff time.Duration // wait before the first retry, doubled after each one; 1s when zero
+	MaxBackoff     time.Duration // cap of the wait, 1m when zero
+}
+
+// Payload is the JSON body of a delivery.
+type Payload struct {
+	ID          string     `json:"id"`
+	Type        string     `json:"type"`
+	Time        time.Time  `json:"time"`
+	Phone       string     `json:"phone,omitempty"`
+	Purpose     string     `json:"purpose,omitempty"`
+	MessageID   string     `json:"message_id,omitempty"`
+	Segments    int        `json:"segments,omitempty"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	RequestID   string     `json:"request_id,omitempty"`
+}
+
+// Delivery is the outcome of sending one event to one subscription.
+type Delivery struct {
+	ID           string    `json:"id"`
+	Subscription string    `json:"subscription"`
+	Event        string    `json:"event"`
+	Time         time.Time `json:"time"` // end of the last attempt
+	Attempts     int       `json:"attempts"`
+	Status       int       `json:"status,omitempty"` // HTTP status of the last attempt
+	Error        string    `json:"error,omitempty"`
+	Delivered    bool      `json:"delivered"`
+}
+
+type job struct {
+	sub     *Subscription
+	id      string
+	event   string
+	body    []byte
+	rec     Delivery      // attempts so far
+	backoff time.Duration // wait before the next retry
+}
+
+// Dispatcher queues events and delivers them in the background.
+type Dispatcher struct {
+	cfg    Config
+	subs   []Subscription
+	client *http.Client
+	log    *zap.SugaredLogger
+
+	ctx     context.Context // cancelled when Close gives up waiting
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup // workers
+	pending sync.WaitGroup // jobs queued, in progress or waiting for a retry
+
+	mu      sync.Mutex
+	queue   chan job
+	closed  bool
+	history *ring.Buffer[Delivery]
+}
+
+// New validates subs and starts the workers.
+func New(cfg Config, subs []Subscription) (*Dispatcher, error) {
+	for i, s := range subs {
+		u, err := url.Parse(s.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("webhook %q: invalid URL %q", s.Name, s.URL)
+		}
+		if s.Secret == "" {
+			return nil, fmt.Errorf("webhook %q: secret is required", s.Name)
+		}
+		for _, ev := range s.Events {
+			if !slices.Contains(service.EventTypes, ev) {
+				return nil, fmt.Errorf("webhook %q: unknown event %q", s.Name, ev)
+			}
+		}
+		if s.Name == "" {
+			subs[i].Name = u.Host
+		}
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 4
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1000
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = time.Second
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = time.Minute
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		cfg:     cfg,
+		subs:    subs,
+		client:  &http.Client{Timeout: cfg.Timeout},
+		log:     logger.New("Webhook"),
+		ctx:     ctx,
+		cancel:  cancel,
+		queue:   make(chan job, cfg.QueueSize),
+		history: ring.New[Delivery](historySize),
+	}
+	for i := 0; i < cfg.Workers; i++ {
+		d.wg.Add(1)
+		go d.work()
+	}
+	return d, nil
+}
+
+// Handle queues e for every matching subscription; pass it to
+// service.WithEventHandler. It never blocks: when the queue is full the
+// delivery is dropped and logged.
+func (d *Dispatcher) Handle(ctx context.Context, e service.Event) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return
+	}
+	for i := range d.subs {
+		sub := &d.subs[i]
+		if len(sub.Events) > 0 && !slices.Contains(sub.Events, e.Type) {
+			continue
+		}
+		j := job{sub: sub, id: newID(), event: e.Type, backoff: d.cfg.InitialBackoff}
+		j.rec = Delivery{ID: j.id, Subscription: sub.Name, Event: e.Type}
+		j.body, _ = json.Marshal(payload(ctx, j.id, e, sub.MaskPhone)) // Payload has only plain fields
+		d.pending.Add(1)
+		select {
+		case d.queue <- j:
+		default:
+			d.pending.Done()
+			logger.For(ctx, d.log).Warnw("Webhook queue full, delivery dropped", "subscription", sub.Name, "event", e.Type)
+			d.record(Delivery{ID: j.id, Subscription: sub.Name, Event: e.Type, Time: time.Now(), Error: "queue full"})
+		}
+	}
+}
+
+func payload(ctx context.Context, id string, e service.Event, mask bool) Payload {
+	p := Payload{
+		ID:        id,
+		Type:      e.Type,
+		Time:      e.Time.UTC(),
+		Phone:     e.Phone,
+		Purpose:   e.Purpose,
+		MessageID: e.MessageID,
+		Segments:  e.Segments,
+		RequestID: logger.RequestID(ctx),
+	}
+	if mask && p.Phone != "" {
+		p.Phone = logger.MaskPhone(p.Phone)
+	}
+	if !e.LockedUntil.IsZero() {
+		until := e.LockedUntil.UTC()
+		p.LockedUntil = &until
+	}
+	if e.Err != nil {
+		p.Error = e.Err.Error()
+	}
+	return p
+}
+
+func (d *Dispatcher) work() {
+	defer d.wg.Done()
+	for j := range d.queue {
+		d.attempt(j)
+	}
+}
+
+// attempt posts j once. A retryable failure schedules the next attempt, so
+// workers never wait out a backoff; otherwise the delivery is finished.
+func (d *Dispatcher) attempt(j job) {
+	if d.ctx.Err() != nil {
+		d.abort(j)
+		return
+	}
+	j.rec.Attempts++
+	status, err := d.post(j)
+	j.rec.Status, j.rec.Error = status, ""
+	if err == nil {
+		j.rec.Delivered = true
+		d.finish(j.rec)
+		return
+	}
+	j.rec.Error = err.Error()
+	d.log.Warnw("Webhook attempt failed", "subscription", j.sub.Name, "event", j.event,
+		"id", j.id, "attempt", j.rec.Attempts, "status", status, "err", err)
+	if !retryable(status) || j.rec.Attempts >= d.cfg.MaxAttempts {
+		d.finish(j.rec)
+		return
+	}
+	go d.retry(j)
+}
+
+// retry queues j again once its backoff has passed.
+func (d *Dispatcher) retry(j job) {
+	t := time.NewTimer(j.backoff)
+	defer t.Stop()
+	j.backoff = min(j.backoff*2, d.cfg.MaxBackoff)
+	select {
+	case <-t.C:
+	case <-d.ctx.Done():
+		d.abort(j)
+		return
+	}
+	select {
+	case d.queue <- j:
+	case <-d.ctx.Done():
+		d.abort(j)
+	}
+}
+
+// abort finishes j at shutdown without further attempts.
+func (d *Dispatcher) abort(j job) {
+	j.rec.Error = "aborted at shutdown: " + j.rec.Error
+	d.finish(j.rec)
+}
+
+// finish logs and records the outcome of a delivery.
+func (d *Dispatcher) finish(rec Delivery) {
+	defer d.pending.Done()
+	rec.Time = time.Now()
+	if rec.Delivered {
+		d.log.Debugw("Webhook delivered", "subscription", rec.Subscription, "event", rec.Event, "id", rec.ID, "attempts", rec.Attempts)
+	} else {
+		d.log.Errorw("Webhook delivery failed", "subscription", rec.Subscription, "event", rec.Event, "id", rec.ID, "attempts", rec.Attempts, "err", rec.Error)
+	}
+	d.mu.Lock()
+	d.record(rec)
+	d.mu.Unlock()
+}
+
+// errStatus reports a non-2xx reply.
+var errStatus = errors.New("unexpected status")
+
+func (d *Dispatcher) post(j job) (int, error) {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, j.sub.URL, bytes.NewReader(j.body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "otp-sms-provider-webhook")
+	req.Header.Set(EventHeader, j.event)
+	req.Header.Set(DeliveryHeader, j.id)
+	req.Header.Set(SignatureHeader, Sign(j.sub.Secret, time.Now(), j.body))
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10)) // lets the connection be reused
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("%w %d", errStatus, resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// retryable reports whether an attempt ending with status may succeed later:
+// network errors, throttling and server errors.
+func retryable(status int) bool {
+	return status == 0 || status == http.StatusTooManyRequests || status >= 500
+}
+
+// record appends rec to the delivery log; d.mu must be held.
+func (d *Dispatcher) record(rec Delivery) {
+	d.history.Add(rec)
+}
+
+// Deliveries returns up to limit finished deliveries, newest first.
+func (d *Dispatcher) Deliveries(limit int) []Delivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if n := d.history.Len(); limit <= 0 || limit > n {
+		limit = n
+	}
+	out := make([]Delivery, 0, limit)
+	d.history.Newest(func(rec Delivery) bool {
+		out = append(out, rec)
+		return len(out) < limit
+	})
+	return out
+}
+
+// Close stops accepting events and waits for queued deliveries, including
+// their retries, until ctx is done; then pending retries are abandoned.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return nil
+	}
+	d.closed = true
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.pending.Wait()
+		close(done)
+	}()
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+		d.cancel()
+		<-done
+	}
+	d.cancel()
+	// No retry can send any more, so the workers may stop.
+	close(d.queue)
+	d.wg.Wait()
+	return err
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
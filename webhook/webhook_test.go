@@ -0,0 +1,157 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	service "github.com/NlightN22/OTPSMSProvider/service"
+)
+
+func TestSignVerify(t *testing.T) {
+	now := time.Now()
+	body := []byte(`{"type":"code.sent"}`)
+	h := Sign("s3cret", now, body)
+	if err := Verify("s3cret", h, body, time.Minute, now); err != nil {
+		t.Fatalf("Verify error: %v", err)
+	}
+	for name, err := range map[string]error{
+		"wrong secret": Verify("other", h, body, time.Minute, now),
+		"changed body": Verify("s3cret", h, []byte(`{}`), time.Minute, now),
+		"stale":        Verify("s3cret", h, body, time.Minute, now.Add(2*time.Minute)),
+		"malformed":    Verify("s3cret", "v1=abc", body, time.Minute, now),
+	} {
+		if !errors.Is(err, ErrSignature) {
+			t.Errorf("%s: error = %v; want ErrSignature", name, err)
+		}
+	}
+}
+
+func TestDispatcher_RetriesAndSigns(t *testing.T) {
+	var (
+		calls atomic.Int32
+		mu    sync.Mutex
+		got   Payload
+		ids   []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := Verify("s3cret", r.Header.Get(SignatureHeader), body, time.Minute, time.Now()); err != nil {
+			t.Errorf("receiver: %v", err)
+		}
+		mu.Lock()
+		ids = append(ids, r.Header.Get(DeliveryHeader))
+		json.Unmarshal(body, &got)
+		mu.Unlock()
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+	}))
+	defer srv.Close()
+
+	d, err := New(Config{InitialBackoff: time.Millisecond}, []Subscription{
+		{Name: "crm", URL: srv.URL, Secret: "s3cret", Events: []string{service.EventLockout}, MaskPhone: true},
+	})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	until := time.Now().Add(time.Minute)
+	d.Handle(context.Background(), service.Event{Type: service.EventCodeSent, Phone: "+79991234567"})
+	d.Handle(context.Background(), service.Event{Type: service.EventLockout, Phone: "+79991234567", LockedUntil: until})
+	if err := d.Close(context.Background()); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+
+	if n := calls.Load(); n != 3 {
+		t.Fatalf("receiver calls = %d; want 3 (two retries, unsubscribed event skipped)", n)
+	}
+	if ids[0] != ids[2] {
+		t.Errorf("delivery IDs = %v; want the same ID on retries", ids)
+	}
+	if got.Type != service.EventLockout || got.Phone != "+799******67" || got.LockedUntil == nil {
+		t.Errorf("payload = %+v; want masked lockout with its end", got)
+	}
+	log := d.Deliveries(10)
+	if len(log) != 1 || !log[0].Delivered || log[0].Attempts != 3 || log[0].Subscription != "crm" {
+		t.Errorf("deliveries = %+v; want one delivered after 3 attempts", log)
+	}
+}
+
+func TestDispatcher_StopsOnClientError(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer srv.Close()
+
+	d, err := New(Config{InitialBackoff: time.Millisecond}, []Subscription{{URL: srv.URL, Secret: "s"}})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	d.Handle(context.Background(), service.Event{Type: service.EventVerificationFailed})
+	d.Close(context.Background())
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("receiver calls = %d; want 1, a 410 is not retried", n)
+	}
+	if log := d.Deliveries(0); len(log) != 1 || log[0].Delivered || log[0].Status != http.StatusGone {
+		t.Errorf("deliveries = %+v; want one failed with 410", log)
+	}
+}
+
+func TestDispatcher_RetryDoesNotBlockWorkers(t *testing.T) {
+	dead := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer dead.Close()
+	delivered := make(chan struct{})
+	live := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(delivered)
+	}))
+	defer live.Close()
+
+	d, err := New(Config{Workers: 1, InitialBackoff: time.Hour}, []Subscription{
+		{Name: "dead", URL: dead.URL, Secret: "s"},
+		{Name: "live", URL: live.URL, Secret: "s"},
+	})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	d.Handle(context.Background(), service.Event{Type: service.EventCodeSent})
+	select {
+	case <-delivered:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("live subscriber not reached while the dead one waits for a retry")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := d.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Close error = %v; want the deadline", err)
+	}
+	log := d.Deliveries(0)
+	if len(log) != 2 || log[0].Subscription != "dead" || log[0].Delivered || log[0].Attempts != 1 {
+		t.Errorf("deliveries = %+v; want the dead one aborted after 1 attempt", log)
+	}
+}
+
+func TestNew_Validates(t *testing.T) {
+	for name, sub := range map[string]Subscription{
+		"url":    {URL: "ftp://x", Secret: "s"},
+		"secret": {URL: "https://x"},
+		"event":  {URL: "https://x", Secret: "s", Events: []string{"code.lost"}},
+	} {
+		if _, err := New(Config{}, []Subscription{sub}); err == nil {
+			t.Errorf("%s: New error = nil; want an error", name)
+		}
+	}
+}